@@ -1,14 +1,20 @@
 // Package main demonstrates a TCP server implementation using the Kinetica protocol.
-// This example shows how to start a TCP server, accept client connections,
-// and handle different types of sensor messages.
+// This example shows how to start a TCP server with the server package and route
+// different types of sensor messages to typed handlers.
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/registry"
+	"kinetica-protocol/server"
 	"kinetica-protocol/transport"
 	"kinetica-protocol/transport/net"
 	"log"
+	"os"
+	"os/signal"
 	"time"
 )
 
@@ -20,63 +26,72 @@ func main() {
 		ReadTimeout:  0, // No read timeout for server
 	}
 
-	// Create TCP transport
-	transport := net.NewTCP(config)
-	defer transport.Close()
+	// Track known sensors and report their lifecycle
+	sensors := registry.New(registry.Config{
+		HeartbeatInterval: 5 * time.Second,
+		OnEvent: func(e registry.Event) {
+			fmt.Printf("Sensor %d %v\n", e.Sensor.SensorID, e.Type)
+		},
+	})
+	go sensors.Run(context.Background())
 
-	// Start listening for connections
-	connections, err := transport.Listen()
-	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	// Route each message type to its handler
+	router := server.NewRouter()
+	router.Use(server.Recover(), sensors.Middleware())
 
-	fmt.Println("Server listening on :8081")
+	router.OnRegistration(func(w server.ReplyWriter, r *server.Request, m *message.Registration) error {
+		fmt.Printf("Sensor %d registered (type: %d, capabilities: 0x%02x)\n",
+			m.SensorID, m.DeviceType, m.Capabilities)
 
-	// Handle each connection in a separate goroutine
-	for conn := range connections {
-		go handleConnection(conn)
-	}
-}
+		// Acknowledge the registration packet by its PacketID
+		return w.Ack(message.AckOK)
+	})
 
-// handleConnection processes messages from a single client connection.
-// It handles sensor registration, data messages, and heartbeats,
-// sending appropriate acknowledgments back to the client.
-func handleConnection(conn transport.Connection) {
-	defer conn.Close()
-	fmt.Println("Client connected")
+	router.OnSensorData(func(w server.ReplyWriter, r *server.Request, m *message.SensorData) error {
+		fmt.Printf("Sensor %d data: %v\n", m.SensorID, m.Data.Values)
+		return nil
+	})
 
-	for {
-		// Receive message from client
-		msg, err := conn.Receive()
-		if err != nil {
-			fmt.Printf("Client disconnected: %v\n", err)
-			return
-		}
+	router.OnHeartbeat(func(w server.ReplyWriter, r *server.Request, m *message.SensorHeartbeat) error {
+		fmt.Printf("Sensor %d heartbeat: %d%% battery\n", m.SensorID, m.Battery)
+		return nil
+	})
 
-		// Handle different message types
-		switch m := msg.(type) {
-		case *message.Registration:
-			fmt.Printf("Sensor %d registered (type: %d, capabilities: 0x%02x)\n", 
-				m.SensorID, m.DeviceType, m.Capabilities)
-			
-			// Send acknowledgment
-			ack := &message.Ack{
-				SensorID:  m.SensorID,
-				MessageID: 1,
-				Status:    message.AckOK,
-			}
-			if err := conn.Send(ack, message.MsgTypeAck); err != nil {
-				fmt.Printf("Failed to send ACK: %v\n", err)
-			}
+	router.HandleDefault(func(w server.ReplyWriter, r *server.Request) error {
+		fmt.Printf("Unknown message type: %T\n", r.Message())
+		return nil
+	})
 
-		case *message.SensorData:
-			fmt.Printf("Sensor %d data: %v\n", m.SensorID, m.Data.Values)
+	// Serve every client connection in its own goroutine
+	srv := server.NewServer(net.NewTCP(config), router, server.Config{
+		OnConnect: func(conn transport.Connection) {
+			fmt.Println("Client connected")
+		},
+		OnDisconnect: func(conn transport.Connection, err error) {
+			fmt.Printf("Client disconnected: %v\n", err)
+			sensors.Detach(conn)
+		},
+		OnError: func(conn transport.Connection, r *server.Request, err error) {
+			fmt.Printf("Error: %v\n", err)
+		},
+	})
 
-		case *message.SensorHeartbeat:
-			fmt.Printf("Sensor %d heartbeat: %d%% battery\n", m.SensorID, m.Battery)
+	// Shut down gracefully on interrupt
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt)
+		<-stop
 
-		default:
-			fmt.Printf("Unknown message type: %T\n", msg)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Printf("Shutdown error: %v\n", err)
 		}
+	}()
+
+	fmt.Println("Server listening on :8081")
+
+	if err := srv.Serve(); err != nil && !errors.Is(err, server.ErrServerClosed) {
+		log.Fatalf("Failed to start server: %v", err)
 	}
 }
@@ -0,0 +1,75 @@
+// Package main demonstrates UDP discovery of sensors using the Kinetica protocol.
+// This example multicasts discovery probes, lists the sensors that answer with a
+// Registration, and opens a connection to each new sensor to synchronize its clock.
+package main
+
+import (
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport/net"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	// Configure UDP transport used for connections to discovered sensors
+	config := net.Config{
+		WriteTimeout: 5 * time.Second,
+		ReadTimeout:  10 * time.Second,
+	}
+
+	transport := net.NewUDP(config)
+	defer transport.Close()
+
+	// Probe the multicast group every few seconds
+	discovery, err := transport.Discover(net.DiscoveryConfig{
+		Address:  "239.0.0.77:8090",
+		Interval: 3 * time.Second,
+		OnFound: func(e net.Endpoint) {
+			fmt.Printf("Found sensor %d at %v (type: %d, fw: 0x%04x)\n",
+				e.Registration.SensorID, e.Addr, e.Registration.DeviceType, e.Registration.FWVersion)
+		},
+		OnLost: func(e net.Endpoint) {
+			fmt.Printf("Lost sensor %d at %v\n", e.Registration.SensorID, e.Addr)
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to start discovery: %v", err)
+	}
+	defer discovery.Close()
+
+	fmt.Println("Discovering sensors on 239.0.0.77:8090, press Ctrl+C to stop")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// Synchronize the clock of every known sensor
+			for _, e := range discovery.Endpoints() {
+				conn, err := discovery.Connection(e.Registration.SensorID)
+				if err != nil {
+					log.Printf("Failed to connect to sensor %d: %v", e.Registration.SensorID, err)
+					continue
+				}
+
+				sync := &message.TimeSync{
+					SensorID:   e.Registration.SensorID,
+					ServerTime: uint32(time.Now().Unix()),
+				}
+				if err := conn.Send(sync, message.MsgTypeTimeSync); err != nil {
+					log.Printf("Failed to sync sensor %d: %v", e.Registration.SensorID, err)
+				}
+				conn.Close()
+			}
+		}
+	}
+}
@@ -1,10 +1,13 @@
 // Package main demonstrates a UDP server implementation using the Kinetica protocol.
-// This example shows how to receive and process UDP datagrams from sensor clients.
+// This example shows how to receive and process UDP datagrams from sensor clients
+// with the server package.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/server"
 	"kinetica-protocol/transport"
 	"kinetica-protocol/transport/net"
 	"log"
@@ -19,49 +22,41 @@ func main() {
 		ReadTimeout:  0,
 	}
 
-	// Create UDP transport
-	transport := net.NewUDP(config)
-	defer transport.Close()
-
-	// Start listening for connections
-	connections, err := transport.Listen()
-	if err != nil {
-		log.Fatalf("Failed to start UDP server: %v", err)
-	}
+	// Route each message type to its handler
+	router := server.NewRouter()
+
+	router.OnRegistration(func(w server.ReplyWriter, r *server.Request, m *message.Registration) error {
+		fmt.Printf("UDP Sensor %d registered (type: %d)\n", m.SensorID, m.DeviceType)
+		return w.Ack(message.AckOK)
+	})
+
+	router.OnSensorData(func(w server.ReplyWriter, r *server.Request, m *message.SensorData) error {
+		fmt.Printf("UDP Sensor %d data: %v\n", m.SensorID, m.Data.Values)
+		return nil
+	})
+
+	router.OnHeartbeat(func(w server.ReplyWriter, r *server.Request, m *message.SensorHeartbeat) error {
+		fmt.Printf("UDP Sensor %d heartbeat: %d%% battery\n", m.SensorID, m.Battery)
+		return nil
+	})
+
+	router.HandleDefault(func(w server.ReplyWriter, r *server.Request) error {
+		fmt.Printf("UDP unknown message: %T\n", r.Message())
+		return nil
+	})
+
+	srv := server.NewServer(net.NewUDP(config), router, server.Config{
+		OnConnect: func(conn transport.Connection) {
+			fmt.Printf("UDP client connected: %v\n", conn.(*net.Connection).RemoteAddr())
+		},
+		OnDisconnect: func(conn transport.Connection, err error) {
+			fmt.Printf("UDP client %v disconnected: %v\n", conn.(*net.Connection).RemoteAddr(), err)
+		},
+	})
 
 	fmt.Println("UDP server listening on :8082")
 
-	// Handle each connection
-	for conn := range connections {
-		go handleConnection(conn)
+	if err := srv.Serve(); err != nil && !errors.Is(err, server.ErrServerClosed) {
+		log.Fatalf("Failed to start UDP server: %v", err)
 	}
 }
-
-func handleConnection(conn transport.Connection) {
-	defer conn.Close()
-	fmt.Println("UDP client connected")
-
-	for {
-		// Receive message from client
-		msg, err := conn.Receive()
-		if err != nil {
-			fmt.Printf("UDP client disconnected: %v\n", err)
-			return
-		}
-
-		// Handle different message types
-		switch m := msg.(type) {
-		case *message.Registration:
-			fmt.Printf("UDP Sensor %d registered (type: %d)\n", m.SensorID, m.DeviceType)
-
-		case *message.SensorData:
-			fmt.Printf("UDP Sensor %d data: %v\n", m.SensorID, m.Data.Values)
-
-		case *message.SensorHeartbeat:
-			fmt.Printf("UDP Sensor %d heartbeat: %d%% battery\n", m.SensorID, m.Battery)
-
-		default:
-			fmt.Printf("UDP unknown message: %T\n", msg)
-		}
-	}
-}
\ No newline at end of file
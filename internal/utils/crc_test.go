@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Bitwise reference implementations of the original algorithms.
+
+func referenceCRC8(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = (crc << 1) ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func referenceCRC16(data []byte) uint16 {
+	var crc uint16 = 0xFFFF
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func referenceCRC32(data []byte) uint32 {
+	var crc uint32 = 0xFFFFFFFF
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = (crc << 1) ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+func TestCRC_GoldenVectors(t *testing.T) {
+	tests := []struct {
+		input string
+		crc8  uint8
+		crc16 uint16
+		crc32 uint32
+	}{
+		{"", 0x00, 0xFFFF, 0x00000000},
+		{"123456789", 0xF4, 0x29B1, 0xFC891918},
+		{"A", 0xC0, 0xB915, 0x81B02D8B},
+		{"KN", 0x21, 0x6533, 0x4EA53B32},
+	}
+
+	for _, tt := range tests {
+		data := []byte(tt.input)
+		if got := CRC8(data); got != tt.crc8 {
+			t.Errorf("CRC8(%q) = 0x%02X, want 0x%02X", tt.input, got, tt.crc8)
+		}
+		if got := CRC16(data); got != tt.crc16 {
+			t.Errorf("CRC16(%q) = 0x%04X, want 0x%04X", tt.input, got, tt.crc16)
+		}
+		if got := CRC32(data); got != tt.crc32 {
+			t.Errorf("CRC32(%q) = 0x%08X, want 0x%08X", tt.input, got, tt.crc32)
+		}
+	}
+}
+
+func TestCRC_MatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 1024)
+	rng.Read(data)
+
+	for n := 0; n <= len(data); n += 1 + n/8 {
+		chunk := data[:n]
+		if got, want := CRC8(chunk), referenceCRC8(chunk); got != want {
+			t.Fatalf("CRC8 of %d bytes = 0x%02X, want 0x%02X", n, got, want)
+		}
+		if got, want := CRC16(chunk), referenceCRC16(chunk); got != want {
+			t.Fatalf("CRC16 of %d bytes = 0x%04X, want 0x%04X", n, got, want)
+		}
+		if got, want := CRC32(chunk), referenceCRC32(chunk); got != want {
+			t.Fatalf("CRC32 of %d bytes = 0x%08X, want 0x%08X", n, got, want)
+		}
+	}
+}
+
+func benchmarkCRC(b *testing.B, size int, crc func([]byte) uint32) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(3)).Read(data)
+
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		crc(data)
+	}
+}
+
+func BenchmarkCRC8_64(b *testing.B) {
+	benchmarkCRC(b, 64, func(d []byte) uint32 { return uint32(CRC8(d)) })
+}
+
+func BenchmarkCRC8Reference_64(b *testing.B) {
+	benchmarkCRC(b, 64, func(d []byte) uint32 { return uint32(referenceCRC8(d)) })
+}
+
+func BenchmarkCRC16_64(b *testing.B) {
+	benchmarkCRC(b, 64, func(d []byte) uint32 { return uint32(CRC16(d)) })
+}
+
+func BenchmarkCRC16Reference_64(b *testing.B) {
+	benchmarkCRC(b, 64, func(d []byte) uint32 { return uint32(referenceCRC16(d)) })
+}
+
+func BenchmarkCRC32_64(b *testing.B) {
+	benchmarkCRC(b, 64, CRC32)
+}
+
+func BenchmarkCRC32Reference_64(b *testing.B) {
+	benchmarkCRC(b, 64, referenceCRC32)
+}
+
+func BenchmarkCRC32_4K(b *testing.B) {
+	benchmarkCRC(b, 4096, CRC32)
+}
+
+func BenchmarkCRC32Reference_4K(b *testing.B) {
+	benchmarkCRC(b, 4096, referenceCRC32)
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"errors"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"time"
+)
+
+// Marshal encodes a message with codec.Marshal and records the encode latency.
+func (c *Collector) Marshal(msg message.Message, packetID uint8, msgType message.MsgType, transportType message.TransportCRC) ([]byte, error) {
+	start := time.Now()
+	data, err := codec.Marshal(msg, packetID, msgType, transportType)
+	d := time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.encodeLatency.observe(d)
+
+	return data, err
+}
+
+// Unmarshal decodes a packet with codec.Unmarshal, records the decode latency, and
+// counts footer (CRC) validation failures separately from other decode errors.
+func (c *Collector) Unmarshal(data []byte, transportType message.TransportCRC) (message.Message, error) {
+	start := time.Now()
+	msg, err := codec.Unmarshal(data, transportType)
+	d := time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observeDecode(d, err)
+
+	if err == nil {
+		if id, ok := message.SensorIDOf(msg); ok {
+			c.lastSeen[id] = c.now()
+		}
+	}
+
+	return msg, err
+}
+
+// observeCodec records an encode or decode performed by an instrumented connection.
+func (c *Collector) observeCodec(e transport.CodecEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e.Op == transport.CodecEncode {
+		c.encodeLatency.observe(e.Duration)
+		return
+	}
+	c.observeDecode(e.Duration, e.Err)
+}
+
+// observeDecode records the latency of a decode and classifies its error.
+// The caller holds c.mu.
+func (c *Collector) observeDecode(d time.Duration, err error) {
+	c.decodeLatency.observe(d)
+
+	switch {
+	case err == nil:
+	case errors.Is(err, codec.ErrInvalidFooter), errors.Is(err, codec.ErrInvalidCRC):
+		c.codecCRCErrors++
+	case errors.Is(err, codec.ErrInvalidMAC):
+		c.codecMACErrors++
+	default:
+		c.codecDecodeErrors++
+	}
+}
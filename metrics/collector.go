@@ -0,0 +1,354 @@
+// Package metrics exports protocol and transport metrics in the Prometheus text
+// exposition format without any third-party dependency. It is opt-in: wrap a
+// transport.Transport or transport.Connection with a Collector to record message
+// throughput, send latency, connection counts, per-sensor activity, and the codec
+// latency and failures of the wrapped connections. The Collector's Marshal and
+// Unmarshal record codec calls made outside any connection the same way.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Direction label values.
+const (
+	directionSent     = "sent"
+	directionReceived = "received"
+)
+
+// messageKey identifies a message counter.
+type messageKey struct {
+	transport string          // Transport label
+	direction string          // "sent" or "received"
+	msgType   message.MsgType // Message type
+}
+
+// errorKey identifies an error counter.
+type errorKey struct {
+	transport string // Transport label
+	direction string // "sent" or "received"
+}
+
+// Collector records metrics from instrumented transports, connections, and codec calls
+// and writes them in the Prometheus text format. It is safe for concurrent use.
+type Collector struct {
+	mu                sync.Mutex                 // Guards all fields below
+	messages          map[messageKey]uint64      // Messages by transport, direction, and type
+	errors            map[errorKey]uint64        // Failed sends and receives by transport and direction
+	sendLatency       map[string]*histogram      // Send durations (encode and write) by transport
+	encodeLatency     *histogram                 // Encode durations of connections and Marshal calls
+	decodeLatency     *histogram                 // Decode durations of connections and Unmarshal calls
+	codecCRCErrors    uint64                     // Decodes failing footer validation
+	codecMACErrors    uint64                     // Decodes failing HMAC footer authentication
+	codecDecodeErrors uint64                     // Decodes failing for any other reason
+	active            map[string]int64           // Open connections by transport
+	conns             map[*Connection]struct{}   // Open instrumented connections
+	closedStats       map[string]transport.Stats // Accumulated statistics of closed connections by transport
+	lastSeen          map[uint8]time.Time        // Last message time by SensorID
+	now               func() time.Time           // Clock used for last-seen timestamps
+}
+
+// NewCollector creates an empty collector.
+func NewCollector() *Collector {
+	return &Collector{
+		messages:      make(map[messageKey]uint64),
+		errors:        make(map[errorKey]uint64),
+		sendLatency:   make(map[string]*histogram),
+		encodeLatency: newHistogram(DefaultBuckets),
+		decodeLatency: newHistogram(DefaultBuckets),
+		active:        make(map[string]int64),
+		conns:         make(map[*Connection]struct{}),
+		closedStats:   make(map[string]transport.Stats),
+		lastSeen:      make(map[uint8]time.Time),
+		now:           time.Now,
+	}
+}
+
+// observeSend records a send attempt on a connection.
+func (c *Collector) observeSend(name string, msgType message.MsgType, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.errors[errorKey{name, directionSent}]++
+		return
+	}
+
+	c.messages[messageKey{name, directionSent, msgType}]++
+
+	h, ok := c.sendLatency[name]
+	if !ok {
+		h = newHistogram(DefaultBuckets)
+		c.sendLatency[name] = h
+	}
+	h.observe(d)
+}
+
+// observeReceive records a receive result on a connection. msg is nil for a packet the
+// connection passed on without decoding it; it is counted under msgType alone.
+func (c *Collector) observeReceive(name string, msgType message.MsgType, msg message.Message, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.errors[errorKey{name, directionReceived}]++
+		return
+	}
+
+	c.messages[messageKey{name, directionReceived, msgType}]++
+
+	if id, ok := message.SensorIDOf(msg); ok {
+		c.lastSeen[id] = c.now()
+	}
+}
+
+// attach registers an open connection.
+func (c *Collector) attach(conn *Connection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conns[conn] = struct{}{}
+	c.active[conn.name]++
+}
+
+// detach unregisters a closed connection and keeps its final statistics.
+func (c *Collector) detach(conn *Connection) {
+	stats := conn.Stats()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.conns[conn]; !ok {
+		return
+	}
+	delete(c.conns, conn)
+	c.active[conn.name]--
+	c.closedStats[conn.name] = c.closedStats[conn.name].Add(stats)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	c.write(&b)
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler returns an HTTP handler serving the metrics for Prometheus scrapes.
+func (c *Collector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = c.WriteTo(w)
+	})
+}
+
+// write renders all metric families.
+func (c *Collector) write(b *strings.Builder) {
+	conns := c.openConns()
+	stats := make(map[string]transport.Stats)
+	for _, conn := range conns {
+		stats[conn.name] = stats[conn.name].Add(conn.Stats())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, s := range c.closedStats {
+		stats[name] = stats[name].Add(s)
+	}
+
+	var samples []sample
+	for k, v := range c.messages {
+		samples = append(samples, sample{labels(
+			"transport", k.transport, "direction", k.direction, "type", typeName(k.msgType)), float64(v)})
+	}
+	writeFamily(b, "kinetica_messages_total", "Protocol messages sent and received.", "counter", samples)
+
+	samples = nil
+	for k, v := range c.errors {
+		samples = append(samples, sample{labels("transport", k.transport, "direction", k.direction), float64(v)})
+	}
+	writeFamily(b, "kinetica_errors_total", "Failed send and receive operations.", "counter", samples)
+
+	samples = nil
+	for name, v := range c.active {
+		samples = append(samples, sample{labels("transport", name), float64(v)})
+	}
+	writeFamily(b, "kinetica_connections_active", "Open connections.", "gauge", samples)
+
+	writeStats(b, stats)
+
+	writeHeader(b, "kinetica_send_duration_seconds", "Time to encode and write a message.", "histogram")
+	names := make([]string, 0, len(c.sendLatency))
+	for name := range c.sendLatency {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeHistogram(b, "kinetica_send_duration_seconds", labels("transport", name), c.sendLatency[name])
+	}
+
+	writeHeader(b, "kinetica_encode_duration_seconds", "Time spent encoding messages.", "histogram")
+	writeHistogram(b, "kinetica_encode_duration_seconds", "", c.encodeLatency)
+	writeHeader(b, "kinetica_decode_duration_seconds", "Time spent decoding messages.", "histogram")
+	writeHistogram(b, "kinetica_decode_duration_seconds", "", c.decodeLatency)
+
+	// Connections validate footers while scanning for frames, before decoding, so
+	// their footer failures come from the transport statistics.
+	crcErrors := c.codecCRCErrors
+	for _, s := range stats {
+		crcErrors += s.CRCErrors
+	}
+	writeFamily(b, "kinetica_codec_crc_errors_total", "Frames and Unmarshal calls failing footer validation.", "counter",
+		[]sample{{"", float64(crcErrors)}})
+	writeFamily(b, "kinetica_codec_mac_errors_total", "Decodes failing HMAC footer authentication.", "counter",
+		[]sample{{"", float64(c.codecMACErrors)}})
+	writeFamily(b, "kinetica_codec_decode_errors_total", "Decodes failing for other reasons.", "counter",
+		[]sample{{"", float64(c.codecDecodeErrors)}})
+
+	samples = nil
+	for id, t := range c.lastSeen {
+		samples = append(samples, sample{labels("sensor_id", strconv.Itoa(int(id))), float64(t.UnixNano()) / 1e9})
+	}
+	writeFamily(b, "kinetica_sensor_last_seen_timestamp_seconds", "Unix time of the last message from each sensor.", "gauge", samples)
+}
+
+// openConns returns the currently open instrumented connections.
+func (c *Collector) openConns() []*Connection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conns := make([]*Connection, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// writeStats renders transport statistics aggregated per transport.
+func writeStats(b *strings.Builder, stats map[string]transport.Stats) {
+	var bytes, crc, decode, timeouts, skipped, gaps []sample
+	for name, s := range stats {
+		l := labels("transport", name)
+		bytes = append(bytes,
+			sample{labels("transport", name, "direction", directionSent), float64(s.BytesSent)},
+			sample{labels("transport", name, "direction", directionReceived), float64(s.BytesReceived)})
+		crc = append(crc, sample{l, float64(s.CRCErrors)})
+		decode = append(decode, sample{l, float64(s.DecodeErrors)})
+		timeouts = append(timeouts, sample{l, float64(s.Timeouts)})
+		skipped = append(skipped, sample{l, float64(s.SkippedBytes)})
+		gaps = append(gaps, sample{l, float64(s.PacketIDGaps)})
+	}
+
+	writeFamily(b, "kinetica_transport_bytes_total", "Bytes sent and received on the wire.", "counter", bytes)
+	writeFamily(b, "kinetica_transport_crc_errors_total", "Received frames discarded because of a CRC mismatch.", "counter", crc)
+	writeFamily(b, "kinetica_transport_decode_errors_total", "Received frames that failed to decode.", "counter", decode)
+	writeFamily(b, "kinetica_transport_timeouts_total", "Read and write timeouts.", "counter", timeouts)
+	writeFamily(b, "kinetica_transport_skipped_bytes_total", "Bytes discarded while resynchronizing streams.", "counter", skipped)
+	writeFamily(b, "kinetica_transport_packet_loss_total", "Packets missing from peer PacketID sequences.", "counter", gaps)
+}
+
+// sample is a single labeled metric value.
+type sample struct {
+	labels string  // Rendered label set including braces, or empty
+	value  float64 // Metric value
+}
+
+// writeFamily renders a metric family with samples sorted by label set.
+func writeFamily(b *strings.Builder, name, help, typ string, samples []sample) {
+	writeHeader(b, name, help, typ)
+	sort.Slice(samples, func(i, j int) bool { return samples[i].labels < samples[j].labels })
+	for _, s := range samples {
+		fmt.Fprintf(b, "%s%s %s\n", name, s.labels, formatFloat(s.value))
+	}
+}
+
+// writeHeader renders the HELP and TYPE lines of a metric family.
+func writeHeader(b *strings.Builder, name, help, typ string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeHistogram renders the buckets, sum, and count of a histogram.
+func writeHistogram(b *strings.Builder, name, labelSet string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket%s %d\n", name, withLabel(labelSet, "le", formatFloat(bound)), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket%s %d\n", name, withLabel(labelSet, "le", "+Inf"), h.count)
+	fmt.Fprintf(b, "%s_sum%s %s\n", name, labelSet, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count%s %d\n", name, labelSet, h.count)
+}
+
+// labels renders name/value pairs as a Prometheus label set.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", pairs[i], escapeLabel(pairs[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel appends one label to a rendered label set.
+func withLabel(labelSet, name, value string) string {
+	if labelSet == "" {
+		return labels(name, value)
+	}
+	return labelSet[:len(labelSet)-1] + "," + labels(name, value)[1:]
+}
+
+// escapeLabel escapes a label value for the text format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatFloat renders a sample value.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// typeName returns the metric label for a message type.
+func typeName(t message.MsgType) string {
+	switch t {
+	case message.MsgTypeCommand:
+		return "command"
+	case message.MsgTypeConfig:
+		return "config"
+	case message.MsgTypeHeartbeat:
+		return "heartbeat"
+	case message.MsgTypeSensorData:
+		return "sensor_data"
+	case message.MsgTypeCustom:
+		return "custom"
+	case message.MsgTypeTimeSync:
+		return "time_sync"
+	case message.MsgTypeAck:
+		return "ack"
+	case message.MsgTypeRegister:
+		return "registration"
+	case message.MsgTypeFragment:
+		return "fragment"
+	case message.MsgTypeRelayed:
+		return "relayed"
+	case message.MsgTypeSensorDataMulti:
+		return "sensor_data_multi"
+	case message.MsgTypeSecure:
+		return "secure"
+	default:
+		return fmt.Sprintf("0x%02x", uint8(t))
+	}
+}
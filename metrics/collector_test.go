@@ -0,0 +1,324 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockConnection replays queued messages and counts sends.
+type mockConnection struct {
+	incoming  []message.Message
+	sendErr   error
+	stats     transport.Stats
+	closed    bool
+	observers transport.CodecObservers
+}
+
+func (m *mockConnection) Send(msg message.Message, msgType message.MsgType) error {
+	m.observers.Notify(transport.CodecEncode, time.Now(), m.sendErr)
+	if m.sendErr != nil {
+		return m.sendErr
+	}
+	m.stats.MessagesSent++
+	m.stats.BytesSent += 10
+	return nil
+}
+
+func (m *mockConnection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	return m.Send(msg, msgType)
+}
+
+func (m *mockConnection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return m.Send(msg, msgType)
+}
+
+func (m *mockConnection) Receive() (message.Message, error) {
+	if len(m.incoming) == 0 {
+		return nil, transport.ErrConnectionClosed
+	}
+	msg := m.incoming[0]
+	m.incoming = m.incoming[1:]
+	m.observers.Notify(transport.CodecDecode, time.Now(), nil)
+	m.stats.MessagesReceived++
+	m.stats.BytesReceived += 12
+	return msg, nil
+}
+
+func (m *mockConnection) ReceiveEnvelope() (*message.Envelope, error) {
+	msg, err := m.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		// A packet the connection passed on without decoding it.
+		return &message.Envelope{Header: message.NewHeader(1, message.MsgTypeVendorMax, 0)}, nil
+	}
+	return &message.Envelope{Header: message.NewHeader(1, msg.MessageType(), 0), Payload: msg}, nil
+}
+
+func (m *mockConnection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	return m.Receive()
+}
+
+func (m *mockConnection) State() transport.ConnectionState {
+	if m.closed {
+		return transport.StateDisconnected
+	}
+	return transport.StateConnected
+}
+
+func (m *mockConnection) Stats() transport.Stats { return m.stats }
+
+func (m *mockConnection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	return m.observers.Observe(fn)
+}
+
+func (m *mockConnection) Close() error {
+	m.closed = true
+	return nil
+}
+
+// mockTransport delivers a fixed set of connections from Listen.
+type mockTransport struct {
+	conns []transport.Connection
+}
+
+func (m *mockTransport) Connection() (transport.Connection, error) {
+	return &mockConnection{}, nil
+}
+
+func (m *mockTransport) Listen() (<-chan transport.Connection, error) {
+	ch := make(chan transport.Connection, len(m.conns))
+	for _, conn := range m.conns {
+		ch <- conn
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (m *mockTransport) Close() error { return nil }
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := c.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	return b.String()
+}
+
+func assertContains(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in output:\n%s", line, out)
+		}
+	}
+}
+
+func TestCollector_Connection(t *testing.T) {
+	c := NewCollector()
+	c.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	mock := &mockConnection{incoming: []message.Message{
+		&message.SensorHeartbeat{SensorID: 7, Battery: 90},
+		&message.SensorHeartbeat{SensorID: 7, Battery: 89},
+	}}
+	conn := c.WrapConnection("tcp", mock)
+
+	for range 2 {
+		if _, err := conn.Receive(); err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+	}
+	if _, err := conn.Receive(); err == nil {
+		t.Fatal("expected error from exhausted connection")
+	}
+	if err := conn.Send(&message.SensorCommand{SensorID: 7, Command: 0x01}, message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	mock.sendErr = errors.New("write failed")
+	_ = conn.Send(&message.SensorCommand{SensorID: 7, Command: 0x01}, message.MsgTypeCommand)
+
+	out := scrape(t, c)
+	assertContains(t, out,
+		`kinetica_messages_total{transport="tcp",direction="received",type="heartbeat"} 2`,
+		`kinetica_messages_total{transport="tcp",direction="sent",type="command"} 1`,
+		`kinetica_errors_total{transport="tcp",direction="received"} 1`,
+		`kinetica_errors_total{transport="tcp",direction="sent"} 1`,
+		`kinetica_connections_active{transport="tcp"} 1`,
+		`kinetica_transport_bytes_total{transport="tcp",direction="received"} 24`,
+		`kinetica_send_duration_seconds_count{transport="tcp"} 1`,
+		`kinetica_sensor_last_seen_timestamp_seconds{sensor_id="7"} 1.7e+09`,
+	)
+}
+
+func TestCollector_UndecodedMessage(t *testing.T) {
+	c := NewCollector()
+	conn := c.WrapConnection("udp", &mockConnection{incoming: []message.Message{nil, nil}})
+
+	if envelope, err := conn.ReceiveEnvelope(); err != nil || envelope.Payload != nil {
+		t.Fatalf("ReceiveEnvelope = %+v, %v; want an envelope without payload", envelope, err)
+	}
+	if msg, err := conn.Receive(); err != nil || msg != nil {
+		t.Fatalf("Receive = %v, %v; want no message", msg, err)
+	}
+
+	out := scrape(t, c)
+	assertContains(t, out, `kinetica_messages_total{transport="udp",direction="received",type="0x7f"} 1`)
+	if strings.Contains(out, "kinetica_sensor_last_seen_timestamp_seconds{") {
+		t.Error("expected no sensor activity from an undecoded packet")
+	}
+}
+
+func TestCollector_CloseKeepsStats(t *testing.T) {
+	c := NewCollector()
+	conn := c.WrapConnection("serial", &mockConnection{})
+
+	if err := conn.Send(&message.SensorCommand{SensorID: 1, Command: 0x01}, message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	_ = conn.Close()
+	_ = conn.Close()
+
+	assertContains(t, scrape(t, c),
+		`kinetica_connections_active{transport="serial"} 0`,
+		`kinetica_transport_bytes_total{transport="serial",direction="sent"} 10`,
+	)
+}
+
+func TestCollector_Transport(t *testing.T) {
+	c := NewCollector()
+	tr := c.WrapTransport("udp", &mockTransport{
+		conns: []transport.Connection{&mockConnection{}, &mockConnection{}},
+	})
+
+	conns, err := tr.Listen()
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	var accepted []transport.Connection
+	for conn := range conns {
+		accepted = append(accepted, conn)
+	}
+	if len(accepted) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(accepted))
+	}
+	assertContains(t, scrape(t, c), `kinetica_connections_active{transport="udp"} 2`)
+
+	_ = accepted[0].Close()
+	assertContains(t, scrape(t, c), `kinetica_connections_active{transport="udp"} 1`)
+}
+
+func TestCollector_TransportCloseStopsListen(t *testing.T) {
+	c := NewCollector()
+	pending := &mockConnection{}
+	tr := c.WrapTransport("tcp", &mockTransport{conns: []transport.Connection{pending}})
+
+	conns, err := tr.Listen()
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	timeout := time.After(time.Second)
+	for open := true; open; {
+		select {
+		case conn, ok := <-conns:
+			if ok {
+				_ = conn.Close()
+			}
+			open = ok
+		case <-timeout:
+			t.Fatal("expected Listen to stop after Close")
+		}
+	}
+	if out := scrape(t, c); strings.Contains(out, `kinetica_connections_active{transport="tcp"} 1`) {
+		t.Errorf("expected no active connections after Close:\n%s", out)
+	}
+}
+
+func TestCollector_Codec(t *testing.T) {
+	c := NewCollector()
+	msg := &message.SensorHeartbeat{SensorID: 3, Battery: 50}
+
+	data, err := c.Marshal(msg, 1, message.MsgTypeHeartbeat, message.TransportCRC16)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if _, err := c.Unmarshal(data, message.TransportCRC16); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 0xFF
+	if _, err := c.Unmarshal(corrupt, message.TransportCRC16); err == nil {
+		t.Fatal("expected CRC error")
+	}
+	if _, err := c.Unmarshal(data[:2], message.TransportCRC16); err == nil {
+		t.Fatal("expected decode error")
+	}
+
+	assertContains(t, scrape(t, c),
+		`kinetica_encode_duration_seconds_count 1`,
+		`kinetica_decode_duration_seconds_count 3`,
+		`kinetica_decode_duration_seconds_bucket{le="+Inf"} 3`,
+		`kinetica_codec_crc_errors_total 1`,
+		`kinetica_codec_decode_errors_total 1`,
+	)
+}
+
+func TestCollector_ConnectionCodec(t *testing.T) {
+	c := NewCollector()
+	mock := &mockConnection{
+		incoming: []message.Message{&message.SensorHeartbeat{SensorID: 1}},
+		stats:    transport.Stats{CRCErrors: 2},
+	}
+	conn := c.WrapConnection("serial", mock)
+
+	if err := conn.Send(&message.SensorCommand{SensorID: 1}, message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if _, err := conn.Receive(); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	mock.observers.Notify(transport.CodecDecode, time.Now(), codec.ErrDecodingFailed)
+
+	assertContains(t, scrape(t, c),
+		`kinetica_encode_duration_seconds_count 1`,
+		`kinetica_decode_duration_seconds_count 2`,
+		`kinetica_codec_crc_errors_total 2`,
+		`kinetica_codec_decode_errors_total 1`,
+	)
+
+	_ = conn.Close()
+	mock.observers.Notify(transport.CodecEncode, time.Now(), nil)
+	assertContains(t, scrape(t, c), `kinetica_encode_duration_seconds_count 1`)
+}
+
+func TestCollector_Handler(t *testing.T) {
+	c := NewCollector()
+	c.WrapConnection(`a"b`, &mockConnection{})
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	assertContains(t, string(body),
+		`# TYPE kinetica_connections_active gauge`,
+		`kinetica_connections_active{transport="a\"b"} 1`,
+	)
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import "time"
+
+// DefaultBuckets are the latency histogram bucket upper bounds in seconds,
+// spanning encode/decode times of microseconds up to slow network writes.
+var DefaultBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1,
+}
+
+// histogram accumulates observations into cumulative buckets. The caller holds the collector lock.
+type histogram struct {
+	bounds []float64 // Bucket upper bounds in seconds
+	counts []uint64  // Observations per bucket (not cumulative)
+	sum    float64   // Sum of all observations in seconds
+	count  uint64    // Number of observations
+}
+
+// newHistogram creates a histogram with the given bucket bounds.
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// observe records a duration.
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	h.sum += v
+	h.count++
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
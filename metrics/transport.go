@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"context"
+	"crypto/x509"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"sync"
+	"time"
+)
+
+// Transport wraps a transport.Transport so every connection it establishes or accepts
+// is instrumented and counted as active until closed.
+type Transport struct {
+	transport transport.Transport // Underlying transport
+	name      string              // Transport label value
+	collector *Collector          // Collector receiving the metrics
+	done      chan struct{}       // Closed by Close to stop forwarding accepted connections
+	closeOnce sync.Once           // Ensures done is closed once
+}
+
+// WrapTransport instruments a transport under the given label (e.g. "tcp" or "serial").
+func (c *Collector) WrapTransport(name string, t transport.Transport) *Transport {
+	return &Transport{
+		transport: t,
+		name:      name,
+		collector: c,
+		done:      make(chan struct{}),
+	}
+}
+
+// Connection establishes an instrumented client connection.
+func (t *Transport) Connection() (transport.Connection, error) {
+	conn, err := t.transport.Connection()
+	if err != nil {
+		return nil, err
+	}
+	return t.collector.WrapConnection(t.name, conn), nil
+}
+
+// Listen starts listening and delivers instrumented connections until the underlying
+// transport stops or Close is called. A connection accepted but not yet taken from the
+// channel when Close is called is closed.
+func (t *Transport) Listen() (<-chan transport.Connection, error) {
+	conns, err := t.transport.Listen()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan transport.Connection)
+	go func() {
+		defer close(out)
+		for {
+			var conn transport.Connection
+			var ok bool
+			select {
+			case conn, ok = <-conns:
+				if !ok {
+					return
+				}
+			case <-t.done:
+				return
+			}
+
+			wrapped := t.collector.WrapConnection(t.name, conn)
+			select {
+			case out <- wrapped:
+			case <-t.done:
+				_ = wrapped.Close()
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Close stops delivering connections and closes the underlying transport.
+func (t *Transport) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return t.transport.Close()
+}
+
+// Connection wraps a transport.Connection and records message counts, send latency,
+// errors, and sensor activity, as well as the codec operations of connections that
+// report them (see transport.CodecNotifier). It counts as an active connection until
+// closed.
+type Connection struct {
+	conn      transport.Connection // Underlying connection
+	name      string               // Transport label value
+	collector *Collector           // Collector receiving the metrics
+	unobserve func()               // Stops recording the codec operations of conn
+	closeOnce sync.Once            // Ensures the connection is released once
+}
+
+// WrapConnection instruments a connection under the given transport label.
+func (c *Collector) WrapConnection(name string, conn transport.Connection) *Connection {
+	mc := &Connection{
+		conn:      conn,
+		name:      name,
+		collector: c,
+	}
+	mc.unobserve, _ = transport.ObserveCodec(conn, c.observeCodec)
+	c.attach(mc)
+	return mc
+}
+
+// Send transmits a message and records its type and send latency.
+func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	start := time.Now()
+	err := c.conn.Send(msg, msgType)
+	c.collector.observeSend(c.name, msgType, time.Since(start), err)
+	return err
+}
+
+// SendPacket transmits a message with an explicit PacketID and records it like Send.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	start := time.Now()
+	err := c.conn.SendPacket(msg, msgType, packetID)
+	c.collector.observeSend(c.name, msgType, time.Since(start), err)
+	return err
+}
+
+// SendContext transmits a message honoring ctx and records it like Send.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	start := time.Now()
+	err := c.conn.SendContext(ctx, msg, msgType)
+	c.collector.observeSend(c.name, msgType, time.Since(start), err)
+	return err
+}
+
+// Receive receives a message and records its type and sensor activity.
+func (c *Connection) Receive() (message.Message, error) {
+	msg, err := c.conn.Receive()
+	c.observeMessage(msg, err)
+	return msg, err
+}
+
+// ReceiveEnvelope receives a message with packet metadata and records it like Receive,
+// taking the type from the packet header.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	envelope, err := c.conn.ReceiveEnvelope()
+	if err != nil {
+		c.collector.observeReceive(c.name, 0, nil, err)
+		return nil, err
+	}
+	c.collector.observeReceive(c.name, envelope.Header.Type, envelope.Payload, nil)
+	return envelope, nil
+}
+
+// ReceiveContext receives a message honoring ctx and records it like Receive.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	msg, err := c.conn.ReceiveContext(ctx)
+	c.observeMessage(msg, err)
+	return msg, err
+}
+
+// observeMessage records a receive result without packet header. A nil message has no
+// type to count and is skipped.
+func (c *Connection) observeMessage(msg message.Message, err error) {
+	switch {
+	case err != nil:
+		c.collector.observeReceive(c.name, 0, nil, err)
+	case msg != nil:
+		c.collector.observeReceive(c.name, msg.MessageType(), msg, nil)
+	}
+}
+
+// Flush writes packets buffered by the underlying connection.
+func (c *Connection) Flush() error {
+	return transport.Flush(c.conn)
+}
+
+// State returns the state of the underlying connection.
+func (c *Connection) State() transport.ConnectionState {
+	return c.conn.State()
+}
+
+// Subscribe registers fn for the state transitions of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	unsubscribe, _ = transport.SubscribeState(c.conn, fn)
+	return unsubscribe
+}
+
+// ObserveCodec registers fn for the codec operations of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	remove, _ = transport.ObserveCodec(c.conn, fn)
+	return remove
+}
+
+// PeerIdentity returns the verified certificate of the underlying connection's peer.
+func (c *Connection) PeerIdentity() (*x509.Certificate, bool) {
+	return transport.PeerIdentityOf(c.conn)
+}
+
+// Stats returns the statistics of the underlying connection.
+func (c *Connection) Stats() transport.Stats {
+	return transport.StatsOf(c.conn)
+}
+
+// Close closes the underlying connection and stops counting it as active.
+// Its final statistics remain part of the transport totals.
+func (c *Connection) Close() error {
+	err := c.conn.Close()
+	c.closeOnce.Do(func() {
+		c.unobserve()
+		c.collector.detach(c)
+	})
+	return err
+}
@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"math"
+)
+
+// AppendMarshal encodes a protocol message like Marshal and appends the packet to dst,
+// returning the extended buffer. Fields are written directly without reflection, so
+// encoding does not allocate when dst has enough capacity for the packet. The output is
+// byte-for-byte identical to Marshal.
+//
+// On error dst is returned unchanged with the error.
+func AppendMarshal(dst []byte, msg message.Message, packetID uint8, msgType message.MsgType, transportType message.TransportCRC) ([]byte, error) {
+	return AppendMarshalKeyed(dst, msg, packetID, msgType, transportType, nil)
+}
+
+// AppendMarshalKeyed encodes and appends a protocol message like AppendMarshal, computing
+// HMAC footers like MarshalKeyed. HMAC footers allocate.
+//
+// On error dst is returned unchanged with the error.
+func AppendMarshalKeyed(dst []byte, msg message.Message, packetID uint8, msgType message.MsgType, transportType message.TransportCRC, keys *message.MACKeyring) ([]byte, error) {
+	start := len(dst)
+
+	dst = append(dst, message.MagicBytes[0], message.MagicBytes[1], packetID, uint8(message.V1), uint8(msgType), 0)
+	payloadStart := len(dst)
+
+	dst, err := appendPayload(dst, msg)
+	if err != nil {
+		return dst[:start], err
+	}
+
+	length := len(dst) - payloadStart
+	if length > message.MaxPayloadV2 {
+		return dst[:start], fmt.Errorf("%w: payload length %d exceeds maximum %d", ErrPayloadTooLarge, length, message.MaxPayloadV2)
+	}
+
+	if length > message.MaxPayloadV1 {
+		// Make room for the second length byte of the V2 header.
+		dst = append(dst, 0)
+		copy(dst[payloadStart+1:], dst[payloadStart:len(dst)-1])
+		dst[start+3] = uint8(message.V2)
+		dst[payloadStart] = uint8(length >> 8)
+	}
+	dst[start+5] = uint8(length)
+
+	frameEnd := len(dst)
+	dst = keys.AppendFooter(dst, transportType, dst[start:frameEnd])
+	if len(dst)-frameEnd != message.GetFooterSize(transportType) {
+		return dst[:start], missingFooter(transportType)
+	}
+
+	return dst, nil
+}
+
+// AppendMarshalPayload encodes only the payload section of a message like MarshalPayload
+// and appends it to dst, returning the extended buffer.
+//
+// On error dst is returned unchanged with the error.
+func AppendMarshalPayload(dst []byte, msg message.Message) ([]byte, error) {
+	start := len(dst)
+
+	dst, err := appendPayload(dst, msg)
+	if err != nil {
+		return dst[:start], err
+	}
+
+	return dst, nil
+}
+
+// appendPayload dispatches encoding to the appropriate message-specific appender.
+func appendPayload(dst []byte, msg message.Message) ([]byte, error) {
+	switch m := msg.(type) {
+	case *message.SensorCommand:
+		dst = append(dst, m.SensorID)
+		dst = binary.LittleEndian.AppendUint32(dst, m.TimeStamp)
+		return append(dst, m.Command), nil
+	case *message.SensorConfig:
+		dst = append(dst, m.SensorID)
+		dst = binary.LittleEndian.AppendUint32(dst, m.TimeStamp)
+		return appendItems(dst, m.Config), nil
+	case *message.SensorHeartbeat:
+		dst = append(dst, m.SensorID)
+		dst = binary.LittleEndian.AppendUint32(dst, m.TimeStamp)
+		return append(dst, m.Battery, uint8(m.Status)), nil
+	case *message.SensorData:
+		dst = append(dst, m.SensorID)
+		dst = binary.LittleEndian.AppendUint32(dst, m.TimeStamp)
+		return appendData(dst, m.Data), nil
+	case *message.CustomData:
+		dst = append(dst, m.SensorID)
+		dst = binary.LittleEndian.AppendUint32(dst, m.TimeStamp)
+		dst = append(dst, uint8(m.DataType))
+		return appendItems(dst, m.Data), nil
+	case *message.TimeSync:
+		dst = append(dst, m.SensorID)
+		dst = binary.LittleEndian.AppendUint32(dst, m.ServerTime)
+		return binary.LittleEndian.AppendUint32(dst, m.SensorTime), nil
+	case *message.Ack:
+		dst = append(dst, m.SensorID)
+		dst = binary.LittleEndian.AppendUint16(dst, m.MessageID)
+		return append(dst, uint8(m.Status)), nil
+	case *message.Registration:
+		dst = append(dst, m.SensorID, uint8(m.DeviceType), m.Capabilities)
+		return binary.LittleEndian.AppendUint16(dst, m.FWVersion), nil
+	case *message.Fragment:
+		dst = binary.LittleEndian.AppendUint16(dst, m.MessageID)
+		dst = append(dst, m.FragmentNum, m.TotalFragments)
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(len(m.Data)))
+		return append(dst, m.Data...), nil
+	case *message.RelayedMessage:
+		dst = append(dst, m.RelayID)
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(len(m.OriginalData)))
+		return append(dst, m.OriginalData...), nil
+	case *message.SensorDataMulti:
+		dst = append(dst, m.SensorID)
+		dst = binary.LittleEndian.AppendUint32(dst, m.TimeStamp)
+		dst = append(dst, uint8(len(m.Data)))
+		for _, data := range m.Data {
+			dst = appendData(dst, data)
+		}
+		return dst, nil
+	case *message.SecureMessage:
+		dst = append(dst, m.KeyID)
+		dst = binary.LittleEndian.AppendUint64(dst, m.Session)
+		dst = binary.LittleEndian.AppendUint32(dst, m.Counter)
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(len(m.Ciphertext)))
+		return append(dst, m.Ciphertext...), nil
+	default:
+		return appendRegistered(dst, msg)
+	}
+}
+
+// appendData appends a data type, value count, and little-endian float32 values.
+func appendData(dst []byte, data message.Data) []byte {
+	dst = append(dst, uint8(data.Type), uint8(len(data.Values)))
+	for _, value := range data.Values {
+		dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(value))
+	}
+	return dst
+}
+
+// appendItems appends an item count followed by each item (key-length-value).
+func appendItems(dst []byte, items []message.Item) []byte {
+	dst = append(dst, uint8(len(items)))
+	for _, item := range items {
+		dst = append(dst, uint8(item.Key), item.Length)
+		dst = append(dst, item.Value...)
+	}
+	return dst
+}
@@ -0,0 +1,178 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"reflect"
+	"testing"
+)
+
+// allMessages returns one message of every type with variable-length fields populated.
+func allMessages() []message.Message {
+	return []message.Message{
+		&message.SensorCommand{SensorID: 1, TimeStamp: 12345, Command: 0x02},
+		&message.SensorConfig{SensorID: 2, TimeStamp: 12345, Config: []message.Item{
+			{Key: message.ConfigKeySampleRate, Length: 2, Value: []byte{0xE8, 0x03}},
+			{Key: message.ConfigKeyDeviceName, Length: 3, Value: []byte("imu")},
+		}},
+		&message.SensorHeartbeat{SensorID: 3, TimeStamp: 12345, Battery: 85, Status: message.Ok},
+		&message.SensorData{SensorID: 4, TimeStamp: 12345, Data: message.Data{
+			Type: message.Accelerometer, Values: []float32{1.2, -0.5, 9.8},
+		}},
+		&message.CustomData{SensorID: 5, TimeStamp: 12345, DataType: message.CustomTypeLog, Data: []message.Item{
+			{Key: message.ConfigKeyMode, Length: 1, Value: []byte{0x01}},
+		}},
+		&message.TimeSync{SensorID: 6, ServerTime: 1000, SensorTime: 999},
+		&message.Ack{SensorID: 7, MessageID: 0x1234, Status: message.AckOK},
+		&message.Registration{SensorID: 8, DeviceType: message.DeviceType9Axis, Capabilities: 0x07, FWVersion: 0x0102},
+		&message.Fragment{MessageID: 9, FragmentNum: 1, TotalFragments: 3, Data: []byte{0x01, 0x02, 0x03}},
+		&message.RelayedMessage{RelayID: 10, OriginalData: []byte{0x4B, 0x4E, 0x01, 0x01, 0x01, 0x00}},
+		&message.SensorDataMulti{SensorID: 11, TimeStamp: 12345, Data: []message.Data{
+			{Type: message.Accelerometer, Values: []float32{1, 2, 3}},
+			{Type: message.Quaternion, Values: []float32{0.5, 0.5, 0.5, 0.5}},
+		}},
+		&message.SecureMessage{KeyID: 12, Session: 0xA1B2C3D4E5F60718, Counter: 9, Ciphertext: []byte{0xAA, 0xBB}},
+		largeCustomData(5),
+	}
+}
+
+func TestAppendMarshal_MatchesMarshal(t *testing.T) {
+	transports := []message.TransportCRC{
+		message.TransportNone,
+		message.TransportCRC8,
+		message.TransportCRC16,
+		message.TransportCRC32,
+		message.TransportLength,
+	}
+
+	for _, msg := range allMessages() {
+		for _, transport := range transports {
+			want, err := Marshal(msg, 7, msg.MessageType(), transport)
+			prefix := []byte{0xFF, 0xFE}
+			got, appendErr := AppendMarshal(prefix, msg, 7, msg.MessageType(), transport)
+			if transport == message.TransportLength && errors.Is(err, ErrPayloadTooLarge) {
+				if !errors.Is(appendErr, ErrPayloadTooLarge) || !bytes.Equal(got, prefix) {
+					t.Errorf("%T: expected AppendMarshal to reject the V2 frame like Marshal, got %v", msg, appendErr)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Marshal %T failed: %v", msg, err)
+			}
+			if err := appendErr; err != nil {
+				t.Fatalf("AppendMarshal %T failed: %v", msg, err)
+			}
+			if !bytes.Equal(got[:2], prefix) {
+				t.Errorf("%T: prefix overwritten", msg)
+			}
+			if !bytes.Equal(got[2:], want) {
+				t.Errorf("%T transport %d:\n got %x\nwant %x", msg, transport, got[2:], want)
+			}
+		}
+	}
+}
+
+func TestAppendMarshalPayload_MatchesMarshalPayload(t *testing.T) {
+	for _, msg := range allMessages() {
+		want, err := MarshalPayload(msg)
+		if err != nil {
+			t.Fatalf("MarshalPayload %T failed: %v", msg, err)
+		}
+		got, err := AppendMarshalPayload(nil, msg)
+		if err != nil {
+			t.Fatalf("AppendMarshalPayload %T failed: %v", msg, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%T:\n got %x\nwant %x", msg, got, want)
+		}
+	}
+}
+
+func TestAppendMarshal_Errors(t *testing.T) {
+	dst := []byte{0x01, 0x02}
+
+	got, err := AppendMarshal(dst, nil, 1, message.MsgTypeCommand, message.TransportNone)
+	if !errors.Is(err, ErrInvalidMessageType) {
+		t.Errorf("Expected ErrInvalidMessageType, got %v", err)
+	}
+	if !bytes.Equal(got, dst) {
+		t.Errorf("Expected dst unchanged on error, got %x", got)
+	}
+
+	huge := &message.Fragment{Data: make([]byte, message.MaxPayloadV2)}
+	if _, err := AppendMarshal(dst, huge, 1, message.MsgTypeFragment, message.TransportNone); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+
+	if _, err := AppendMarshal(dst, allMessages()[0], 1, message.MsgTypeCommand, message.TransportHMAC64); !errors.Is(err, ErrEncodingFailed) {
+		t.Errorf("Expected ErrEncodingFailed without MAC key, got %v", err)
+	}
+}
+
+func TestAppendMarshal_ZeroAllocs(t *testing.T) {
+	msg := &message.SensorDataMulti{SensorID: 1, TimeStamp: 12345, Data: []message.Data{
+		{Type: message.Accelerometer, Values: []float32{0.1, 0.2, 9.8}},
+		{Type: message.Gyroscope, Values: []float32{0.01, 0.02, 0.03}},
+	}}
+	buf := make([]byte, 0, 256)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = AppendMarshal(buf[:0], msg, 1, message.MsgTypeSensorDataMulti, message.TransportCRC32)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func BenchmarkMarshal_SensorData(b *testing.B) {
+	msg := &message.SensorData{SensorID: 1, TimeStamp: 12345, Data: message.Data{
+		Type: message.Accelerometer, Values: []float32{0.1, 0.2, 9.8},
+	}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(msg, uint8(i), message.MsgTypeSensorData, message.TransportCRC8); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendMarshal_SensorData(b *testing.B) {
+	msg := &message.SensorData{SensorID: 1, TimeStamp: 12345, Data: message.Data{
+		Type: message.Accelerometer, Values: []float32{0.1, 0.2, 9.8},
+	}}
+	buf := make([]byte, 0, 64)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = AppendMarshal(buf[:0], msg, uint8(i), message.MsgTypeSensorData, message.TransportCRC8); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendMarshal_SensorDataMulti(b *testing.B) {
+	msg := &message.SensorDataMulti{SensorID: 1, TimeStamp: 12345, Data: []message.Data{
+		{Type: message.Accelerometer, Values: []float32{0.1, 0.2, 9.8}},
+		{Type: message.Gyroscope, Values: []float32{0.01, 0.02, 0.03}},
+		{Type: message.Quaternion, Values: []float32{1, 0, 0, 0}},
+	}}
+	buf := make([]byte, 0, 128)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = AppendMarshal(buf[:0], msg, uint8(i), message.MsgTypeSensorDataMulti, message.TransportCRC32); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// equalMessages compares decoded messages, treating nil and empty slices as equal.
+func equalMessages(a, b message.Message) bool {
+	encodedA, errA := MarshalPayload(a)
+	encodedB, errB := MarshalPayload(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB) && reflect.TypeOf(a) == reflect.TypeOf(b)
+}
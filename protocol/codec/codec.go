@@ -56,6 +56,8 @@ func newPacket(data []byte) *packet {
 
 // Marshal encodes a protocol message into binary format with the specified parameters.
 // It creates a complete packet with header, payload, and footer including CRC validation.
+// Payloads up to 255 bytes use the V1 header; larger payloads use the V2 header with
+// a 16-bit length field, so V1-only peers keep working for all small messages.
 //
 // Parameters:
 //   - msg: The message to encode (must implement message.Message interface)
@@ -65,6 +67,15 @@ func newPacket(data []byte) *packet {
 //
 // Returns the complete binary packet or an error if encoding fails.
 func Marshal(msg message.Message, packetID uint8, msgType message.MsgType, transportType message.TransportCRC) ([]byte, error) {
+	return MarshalKeyed(msg, packetID, msgType, transportType, nil)
+}
+
+// MarshalKeyed encodes a protocol message like Marshal, computing HMAC footers with the
+// key of the message's sender in keys. Marshal has no keys, so it fails for HMAC footer
+// types; checksum footers are unaffected by keys.
+//
+// Returns the complete binary packet or an error if encoding fails.
+func MarshalKeyed(msg message.Message, packetID uint8, msgType message.MsgType, transportType message.TransportCRC, keys *message.MACKeyring) ([]byte, error) {
 	buf := newBuffer()
 
 	err := buf.encodePayload(msg)
@@ -72,12 +83,12 @@ func Marshal(msg message.Message, packetID uint8, msgType message.MsgType, trans
 		return nil, err
 	}
 
-	err = buf.encodeHeader(packetID, msgType, uint8(len(buf.bufPayload.Bytes())))
+	err = buf.encodeHeader(packetID, msgType, buf.bufPayload.Len())
 	if err != nil {
 		return nil, err
 	}
 
-	err = buf.encodeFooter(transportType)
+	err = buf.encodeFooter(transportType, keys)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +99,7 @@ func Marshal(msg message.Message, packetID uint8, msgType message.MsgType, trans
 // Unmarshal decodes binary data into a protocol message with CRC validation.
 // It parses the packet header, validates the magic bytes, decodes the payload,
 // and verifies the footer CRC according to the specified transport type.
+// Both V1 and V2 packets are accepted; the header layout is selected by the version byte.
 //
 // Parameters:
 //   - data: The binary packet data to decode
@@ -95,6 +107,31 @@ func Marshal(msg message.Message, packetID uint8, msgType message.MsgType, trans
 //
 // Returns the decoded message or an error if validation or decoding fails.
 func Unmarshal(data []byte, transport message.TransportCRC) (message.Message, error) {
+	envelope, err := UnmarshalFrame(data, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	return envelope.Payload, nil
+}
+
+// UnmarshalFrame decodes binary data like Unmarshal but returns the complete envelope
+// with the decoded header, payload, and footer. Raw references the input data and
+// ReceivedAt is left zero for the caller to fill in. A frame whose type is neither
+// built in nor registered fails with ErrUnknownMessageType, so the payload of a
+// returned envelope is never nil.
+//
+// Returns the decoded envelope or an error if validation or decoding fails.
+func UnmarshalFrame(data []byte, transport message.TransportCRC) (*message.Envelope, error) {
+	return UnmarshalFrameKeyed(data, transport, nil)
+}
+
+// UnmarshalFrameKeyed decodes binary data like UnmarshalFrame, verifying HMAC footers with
+// the key of the frame's sender in keys. UnmarshalFrame has no keys, so it rejects every
+// frame with an HMAC footer.
+//
+// Returns the decoded envelope or an error if validation or decoding fails.
+func UnmarshalFrameKeyed(data []byte, transport message.TransportCRC, keys *message.MACKeyring) (*message.Envelope, error) {
 	if len(data) < message.HeaderSize {
 		return nil, fmt.Errorf("%w: need at least %d bytes", ErrMessageTooShort, message.HeaderSize)
 	}
@@ -104,17 +141,57 @@ func Unmarshal(data []byte, transport message.TransportCRC) (message.Message, er
 		return nil, err
 	}
 
-	if p.header.Magic != message.MagicBytes {
-		return nil, fmt.Errorf("%w: got %x", ErrInvalidMagicBytes, p.header.Magic)
+	if err := p.decodePayload(); err != nil {
+		return nil, err
+	}
+
+	if err := p.validateFooter(transport, keys); err != nil {
+		return nil, err
 	}
 
-	if err := p.decodePayload(); err != nil {
+	return &message.Envelope{
+		Header:  p.header,
+		Payload: p.payload,
+		Footer:  p.footer,
+		Raw:     data,
+	}, nil
+}
+
+// MarshalPayload encodes only the payload section of a message, without header or footer.
+// It is used by layers that carry encoded messages inside other messages, such as
+// fragmentation, where the payload may exceed the size a single packet can describe.
+//
+// Returns the encoded payload bytes or an error if encoding fails.
+func MarshalPayload(msg message.Message) ([]byte, error) {
+	buf := newBuffer()
+
+	if err := buf.encodePayload(msg); err != nil {
 		return nil, err
 	}
 
-	if err := p.validateFooter(transport); err != nil {
+	return buf.bufPayload.Bytes(), nil
+}
+
+// UnmarshalPayload decodes a bare payload previously produced by MarshalPayload.
+// The message type selects the decoder since no header is present.
+//
+// Returns the decoded message or an error if the type is unknown or decoding fails.
+func UnmarshalPayload(msgType message.MsgType, payload []byte) (message.Message, error) {
+	p := newPacket(payload)
+	p.header.Type = msgType
+
+	if err := p.decodeBody(bytes.NewBuffer(payload)); err != nil {
 		return nil, err
 	}
 
 	return p.payload, nil
 }
+
+// ParseHeader decodes the protocol header at the start of data.
+// It validates the magic bytes and protocol version and requires the full header
+// for that version (HeaderSize bytes for V1, HeaderSizeV2 bytes for V2).
+//
+// Returns the decoded header or an error if the header is invalid or incomplete.
+func ParseHeader(data []byte) (message.Header, error) {
+	return readHeader(data)
+}
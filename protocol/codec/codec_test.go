@@ -1,6 +1,7 @@
 package codec
 
 import (
+	"bytes"
 	"errors"
 	"kinetica-protocol/protocol/message"
 	"testing"
@@ -228,6 +229,54 @@ func TestMarshal_AllCRCTypes(t *testing.T) {
 	}
 }
 
+func TestMarshal_CRCVariants(t *testing.T) {
+	msg := &message.SensorHeartbeat{SensorID: 1, TimeStamp: 12345, Battery: 85, Status: message.Ok}
+
+	variants := []message.TransportCRC{
+		message.TransportCRC8Maxim,
+		message.TransportCRC16Modbus,
+		message.TransportCRC16XModem,
+		message.TransportCRC16Kermit,
+		message.TransportCRC32ISOHDLC,
+		message.TransportCRC32C,
+		message.TransportCRC32MPEG2,
+	}
+
+	for _, variant := range variants {
+		table := variant.CRC()
+		t.Run(table.Params().Name, func(t *testing.T) {
+			data, err := Marshal(msg, 1, message.MsgTypeHeartbeat, variant)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			frameSize := len(data) - table.Size()
+			want := table.AppendLittleEndian(nil, data[:frameSize])
+			if !bytes.Equal(data[frameSize:], want) {
+				t.Errorf("footer = %x, want %x", data[frameSize:], want)
+			}
+
+			if _, err := Unmarshal(data, variant); err != nil {
+				t.Errorf("Unmarshal failed: %v", err)
+			}
+
+			data[frameSize-1] ^= 0x01
+			if _, err := Unmarshal(data, variant); !errors.Is(err, ErrInvalidFooter) {
+				t.Errorf("Expected ErrInvalidFooter for corrupted frame, got %v", err)
+			}
+		})
+	}
+
+	// Same width, different algorithm: a CRC-16/CCITT-FALSE frame fails as CRC-16/MODBUS.
+	data, err := Marshal(msg, 1, message.MsgTypeHeartbeat, message.TransportCRC16)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if _, err := Unmarshal(data, message.TransportCRC16Modbus); !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("Expected ErrInvalidFooter across variants, got %v", err)
+	}
+}
+
 func TestUnmarshal_Registration(t *testing.T) {
 	originalMsg := &message.Registration{
 		SensorID:     1,
@@ -584,6 +633,16 @@ func TestMarshal_Unmarshal_RoundTrip(t *testing.T) {
 			},
 			msgType: message.MsgTypeRelayed,
 		},
+		{
+			name: "SecureMessage",
+			msg: &message.SecureMessage{
+				KeyID:      7,
+				Session:    0xA1B2C3D4E5F60718,
+				Counter:    9,
+				Ciphertext: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+			},
+			msgType: message.MsgTypeSecure,
+		},
 	}
 
 	transports := []message.TransportCRC{
@@ -614,3 +673,272 @@ func TestMarshal_Unmarshal_RoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestMarshalPayload_UnmarshalPayload(t *testing.T) {
+	msg := &message.SensorConfig{
+		SensorID:  3,
+		TimeStamp: 12345,
+		Config: []message.Item{
+			{Key: message.ConfigKeySampleRate, Length: 2, Value: []byte{0xE8, 0x03}},
+		},
+	}
+
+	payload, err := MarshalPayload(msg)
+	if err != nil {
+		t.Fatalf("MarshalPayload failed: %v", err)
+	}
+
+	decoded, err := UnmarshalPayload(message.MsgTypeConfig, payload)
+	if err != nil {
+		t.Fatalf("UnmarshalPayload failed: %v", err)
+	}
+
+	cfg, ok := decoded.(*message.SensorConfig)
+	if !ok {
+		t.Fatalf("Expected SensorConfig, got %T", decoded)
+	}
+	if cfg.SensorID != msg.SensorID || len(cfg.Config) != 1 || cfg.Config[0].Value[1] != 0x03 {
+		t.Error("Config mismatch after payload round trip")
+	}
+
+	if _, err := UnmarshalPayload(message.MsgType(0x7F), payload); !errors.Is(err, ErrUnknownMessageType) {
+		t.Errorf("Expected ErrUnknownMessageType, got %v", err)
+	}
+}
+
+func largeCustomData(items int) *message.CustomData {
+	msg := &message.CustomData{
+		SensorID:  5,
+		TimeStamp: 12345,
+		DataType:  message.CustomTypeBinary,
+	}
+	for i := 0; i < items; i++ {
+		value := make([]byte, 100)
+		for j := range value {
+			value[j] = byte(i + j)
+		}
+		msg.Data = append(msg.Data, message.Item{Key: message.ConfigKeyCalibration, Length: 100, Value: value})
+	}
+	return msg
+}
+
+func TestMarshal_VersionSelection(t *testing.T) {
+	small, err := Marshal(&message.Ack{SensorID: 1, MessageID: 1, Status: message.AckOK}, 1, message.MsgTypeAck, message.TransportNone)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if message.Version(small[3]) != message.V1 {
+		t.Errorf("Expected V1 header for small payload, got version %d", small[3])
+	}
+
+	large, err := Marshal(largeCustomData(5), 1, message.MsgTypeCustom, message.TransportCRC16)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if message.Version(large[3]) != message.V2 {
+		t.Fatalf("Expected V2 header for large payload, got version %d", large[3])
+	}
+
+	header, err := ParseHeader(large)
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	expectedLength := len(large) - message.HeaderSizeV2 - message.GetFooterSize(message.TransportCRC16)
+	if int(header.Length) != expectedLength {
+		t.Errorf("Expected length %d, got %d", expectedLength, header.Length)
+	}
+}
+
+func TestUnmarshal_V2RoundTrip(t *testing.T) {
+	original := largeCustomData(20)
+
+	for _, transport := range []message.TransportCRC{message.TransportNone, message.TransportCRC8, message.TransportCRC32} {
+		data, err := Marshal(original, 9, message.MsgTypeCustom, transport)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+
+		decoded, err := Unmarshal(data, transport)
+		if err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+
+		custom, ok := decoded.(*message.CustomData)
+		if !ok {
+			t.Fatalf("Expected CustomData, got %T", decoded)
+		}
+		if len(custom.Data) != 20 || custom.Data[19].Value[99] != original.Data[19].Value[99] {
+			t.Error("CustomData mismatch after V2 round trip")
+		}
+	}
+}
+
+func TestLengthFooter_RejectsV2(t *testing.T) {
+	original := largeCustomData(20)
+
+	if _, err := Marshal(original, 9, message.MsgTypeCustom, message.TransportLength); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+
+	data, err := Marshal(original, 9, message.MsgTypeCustom, message.TransportNone)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	data = append(data, byte(len(data)))
+	if _, err := Unmarshal(data, message.TransportLength); !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("Expected ErrInvalidFooter for a V2 frame, got %v", err)
+	}
+}
+
+func TestUnmarshal_VersionErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		expectedErr error
+	}{
+		{
+			name:        "unsupported version",
+			data:        []byte{0x4B, 0x4E, 0x01, 0x03, 0x07, 0x00},
+			expectedErr: ErrUnsupportedVersion,
+		},
+		{
+			name:        "truncated V2 header",
+			data:        []byte{0x4B, 0x4E, 0x01, 0x02, 0x07, 0x04},
+			expectedErr: ErrMessageTooShort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unmarshal(tt.data, message.TransportNone)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestMarshal_PayloadTooLarge(t *testing.T) {
+	msg := &message.RelayedMessage{RelayID: 1, OriginalData: make([]byte, message.MaxPayloadV2)}
+
+	_, err := Marshal(msg, 1, message.MsgTypeRelayed, message.TransportNone)
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+}
+
+func TestUnmarshalFrame(t *testing.T) {
+	msg := &message.SensorHeartbeat{SensorID: 2, TimeStamp: 99, Battery: 70, Status: message.Collection}
+
+	data, err := Marshal(msg, 42, message.MsgTypeHeartbeat, message.TransportCRC16)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	envelope, err := UnmarshalFrame(data, message.TransportCRC16)
+	if err != nil {
+		t.Fatalf("UnmarshalFrame failed: %v", err)
+	}
+
+	if envelope.Header.PacketID != 42 {
+		t.Errorf("Expected PacketID 42, got %d", envelope.Header.PacketID)
+	}
+	if envelope.Header.Version != message.V1 || envelope.Header.Type != message.MsgTypeHeartbeat {
+		t.Errorf("Unexpected header %+v", envelope.Header)
+	}
+	if len(envelope.Footer.Bytes) != 2 {
+		t.Errorf("Expected 2 footer bytes, got %d", len(envelope.Footer.Bytes))
+	}
+	if len(envelope.Raw) != len(data) {
+		t.Errorf("Expected raw length %d, got %d", len(data), len(envelope.Raw))
+	}
+	if hb, ok := envelope.Payload.(*message.SensorHeartbeat); !ok || hb.Battery != 70 {
+		t.Errorf("Unexpected payload %+v", envelope.Payload)
+	}
+}
+
+func TestMarshal_HMACFooter(t *testing.T) {
+	keys := message.NewMACKeyring()
+	keys.Set(1, []byte("sensor-1-key"))
+	keys.Set(2, []byte("sensor-2-key"))
+
+	for _, crcType := range []message.TransportCRC{message.TransportHMAC64, message.TransportHMAC128} {
+		cmd := &message.SensorCommand{SensorID: 1, TimeStamp: 1000, Command: 0x01}
+		data, err := MarshalKeyed(cmd, 1, message.MsgTypeCommand, crcType, keys)
+		if err != nil {
+			t.Fatalf("MarshalKeyed failed for CRC type %v: %v", crcType, err)
+		}
+
+		size := message.GetFooterSize(crcType)
+		if want := message.HeaderSize + 6 + size; len(data) != want {
+			t.Errorf("Expected %d bytes, got %d", want, len(data))
+		}
+
+		if _, err := UnmarshalFrameKeyed(data, crcType, keys); err != nil {
+			t.Errorf("UnmarshalFrameKeyed failed for CRC type %v: %v", crcType, err)
+		}
+		if _, err := Unmarshal(data, crcType); !errors.Is(err, ErrInvalidMAC) {
+			t.Errorf("Expected ErrInvalidMAC without keys, got %v", err)
+		}
+
+		forged := append([]byte(nil), data...)
+		forged[len(forged)-size-1] ^= 0xFF
+		if _, err := UnmarshalFrameKeyed(forged, crcType, keys); !errors.Is(err, ErrInvalidMAC) || errors.Is(err, ErrInvalidFooter) {
+			t.Errorf("Expected ErrInvalidMAC for modified payload, got %v", err)
+		}
+
+		other, err := MarshalKeyed(&message.SensorCommand{SensorID: 2, TimeStamp: 1000, Command: 0x01}, 1, message.MsgTypeCommand, crcType, keys)
+		if err != nil {
+			t.Fatalf("MarshalKeyed failed for sensor 2: %v", err)
+		}
+		if bytes.Equal(other[len(other)-size:], data[len(data)-size:]) {
+			t.Error("Expected sensors with different keys to produce different footers")
+		}
+	}
+
+	if _, err := Marshal(&message.SensorCommand{SensorID: 1}, 1, message.MsgTypeCommand, message.TransportHMAC64); !errors.Is(err, ErrEncodingFailed) {
+		t.Errorf("Expected ErrEncodingFailed without keys, got %v", err)
+	}
+	if _, err := MarshalKeyed(&message.SensorCommand{SensorID: 3}, 1, message.MsgTypeCommand, message.TransportHMAC64, keys); !errors.Is(err, ErrEncodingFailed) {
+		t.Errorf("Expected ErrEncodingFailed without a key, got %v", err)
+	}
+
+	keys.SetDefault([]byte("default-key"))
+	data, err := MarshalKeyed(&message.SensorCommand{SensorID: 3}, 1, message.MsgTypeCommand, message.TransportHMAC64, keys)
+	if err != nil {
+		t.Fatalf("MarshalKeyed with default key failed: %v", err)
+	}
+
+	keys.SetDefault(nil)
+	if _, err := UnmarshalFrameKeyed(data, message.TransportHMAC64, keys); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("Expected ErrInvalidMAC once the key is removed, got %v", err)
+	}
+}
+
+func TestMarshal_HMACFooterNeedsSender(t *testing.T) {
+	keys := message.NewMACKeyring()
+	keys.SetDefault([]byte("default-key"))
+
+	senderless := []struct {
+		msg     message.Message
+		msgType message.MsgType
+	}{
+		{&message.Fragment{MessageID: 1, FragmentNum: 0, TotalFragments: 1, Data: []byte{0x01}}, message.MsgTypeFragment},
+		{&message.SecureMessage{KeyID: 1, Ciphertext: []byte{0x01}}, message.MsgTypeSecure},
+	}
+
+	for _, tt := range senderless {
+		if _, err := MarshalKeyed(tt.msg, 1, tt.msgType, message.TransportHMAC64, keys); !errors.Is(err, ErrEncodingFailed) {
+			t.Errorf("%T: expected ErrEncodingFailed, got %v", tt.msg, err)
+		}
+
+		data, err := Marshal(tt.msg, 1, tt.msgType, message.TransportNone)
+		if err != nil {
+			t.Fatalf("%T: Marshal failed: %v", tt.msg, err)
+		}
+		data = append(data, make([]byte, message.GetFooterSize(message.TransportHMAC64))...)
+		if _, err := UnmarshalFrameKeyed(data, message.TransportHMAC64, keys); !errors.Is(err, ErrInvalidMAC) {
+			t.Errorf("%T: expected ErrInvalidMAC, got %v", tt.msg, err)
+		}
+	}
+}
@@ -8,10 +8,15 @@ import (
 )
 
 // decodeHeader reads and decodes the protocol header from the packet buffer.
+// It validates the magic bytes first, then selects the header layout by version.
 func (p *packet) decodeHeader() error {
-	if err := binary.Read(p.buf, binary.LittleEndian, &p.header); err != nil {
+	header, err := readHeader(p.buf.Bytes())
+	if err != nil {
 		return err
 	}
+
+	p.header = header
+	p.buf.Next(header.Size())
 	return nil
 }
 
@@ -24,8 +29,12 @@ func (p *packet) decodePayload() error {
 		return err
 	}
 
-	buf := bytes.NewBuffer(payloadBytes)
+	return p.decodeBody(bytes.NewBuffer(payloadBytes))
+}
 
+// decodeBody dispatches payload bytes to the message-specific decoder for the header type.
+// It fails with ErrUnknownMessageType if the type is neither built in nor registered.
+func (p *packet) decodeBody(buf *bytes.Buffer) error {
 	switch p.header.Type {
 	case message.MsgTypeCommand:
 		return p.decodeCommand(buf)
@@ -49,7 +58,18 @@ func (p *packet) decodePayload() error {
 		return p.decodeRelayedMessage(buf)
 	case message.MsgTypeSensorDataMulti:
 		return p.decodeDataMulti(buf)
+	case message.MsgTypeSecure:
+		return p.decodeSecure(buf)
+	}
+
+	msg := newRegistered(p.header.Type)
+	if msg == nil {
+		return fmt.Errorf("%w: 0x%02x", ErrUnknownMessageType, uint8(p.header.Type))
 	}
+	if err := decodeRegistered(buf.Bytes(), msg); err != nil {
+		return err
+	}
+	p.payload = msg
 
 	return nil
 }
@@ -82,34 +102,24 @@ func (p *packet) decodeItem(buf *bytes.Buffer) (*message.Item, error) {
 }
 
 // validateFooter verifies the CRC footer matches the expected value for the transport.
-func (p *packet) validateFooter(transport message.TransportCRC) error {
+// HMAC footers are computed with keys, compared in constant time, and fail with ErrInvalidMAC.
+func (p *packet) validateFooter(transport message.TransportCRC, keys *message.MACKeyring) error {
 	if transport == message.TransportNone {
 		return nil
 	}
 
-	footerSize := message.GetFooterSize(transport)
-	if p.buf.Len() < footerSize {
-		return fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidFooter, footerSize, p.buf.Len())
-	}
-
-	receivedFooter := make([]byte, footerSize)
-	if _, err := p.buf.Read(receivedFooter); err != nil {
-		return fmt.Errorf("%w: failed to read footer", ErrDecodingFailed)
-	}
-
-	totalDataSize := message.HeaderSize + int(p.header.Length)
+	totalDataSize := p.header.Size() + int(p.header.Length)
 	if len(p.originalData) < totalDataSize {
 		return fmt.Errorf("%w: original data too short for validation", ErrInsufficientData)
 	}
 
-	dataForValidation := p.originalData[:totalDataSize]
-
-	expectedFooter := message.NewFooter(transport, dataForValidation)
-
-	if !bytes.Equal(receivedFooter, expectedFooter.Bytes) {
-		return fmt.Errorf("%w: expected %x, got %x", ErrInvalidFooter, expectedFooter.Bytes, receivedFooter)
+	receivedFooter := p.buf.Next(message.GetFooterSize(transport))
+	if err := checkFooter(transport, keys, p.originalData[:totalDataSize], receivedFooter); err != nil {
+		return err
 	}
 
+	p.footer.Bytes = append([]byte(nil), receivedFooter...)
+
 	return nil
 }
 
@@ -390,3 +400,34 @@ func (p *packet) decodeDataMulti(buf *bytes.Buffer) error {
 	p.payload = &data
 	return nil
 }
+
+// decodeSecure decodes a SecureMessage with its key, session, counter, and ciphertext.
+func (p *packet) decodeSecure(buf *bytes.Buffer) error {
+	data := message.SecureMessage{}
+
+	if err := p.readField(buf, &data.KeyID, "key ID"); err != nil {
+		return err
+	}
+	if err := p.readField(buf, &data.Session, "session"); err != nil {
+		return err
+	}
+	if err := p.readField(buf, &data.Counter, "counter"); err != nil {
+		return err
+	}
+
+	var length uint16
+	if err := p.readField(buf, &length, "ciphertext length"); err != nil {
+		return err
+	}
+	if int(length) > buf.Len() {
+		return fmt.Errorf("%w: ciphertext length %d exceeds remaining %d bytes", ErrInsufficientData, length, buf.Len())
+	}
+
+	data.Ciphertext = make([]byte, length)
+	if _, err := buf.Read(data.Ciphertext); err != nil {
+		return fmt.Errorf("%w: failed to read ciphertext", ErrDecodingFailed)
+	}
+
+	p.payload = &data
+	return nil
+}
@@ -31,25 +31,47 @@ func (buf *buffer) encodePayload(msg message.Message) error {
 		return buf.encodeRelayedMessage(m)
 	case *message.SensorDataMulti:
 		return buf.encodeDataMulti(m)
+	case *message.SecureMessage:
+		return buf.encodeSecure(m)
 	default:
-		return ErrInvalidMessageType
+		payload, err := appendRegistered(buf.bufPayload.AvailableBuffer(), msg)
+		if err != nil {
+			return err
+		}
+		buf.bufPayload.Write(payload)
+		return nil
 	}
 }
 
 // encodeHeader creates and encodes the protocol header into the header buffer.
-func (buf *buffer) encodeHeader(packetID uint8, msgType message.MsgType, payloadLength uint8) error {
-	header := message.NewHeader(packetID, msgType, payloadLength)
-	if err := binary.Write(buf.bufHeader, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("%w: header encoding failed", ErrEncodingFailed)
+// V1 headers carry an 8-bit length, V2 headers a 16-bit little-endian length.
+func (buf *buffer) encodeHeader(packetID uint8, msgType message.MsgType, payloadLength int) error {
+	if payloadLength > message.MaxPayloadV2 {
+		return fmt.Errorf("%w: payload length %d exceeds maximum %d", ErrPayloadTooLarge, payloadLength, message.MaxPayloadV2)
+	}
+
+	header := message.NewHeader(packetID, msgType, uint16(payloadLength))
+
+	buf.bufHeader.Write(header.Magic[:])
+	buf.bufHeader.WriteByte(header.PacketID)
+	buf.bufHeader.WriteByte(uint8(header.Version))
+	buf.bufHeader.WriteByte(uint8(header.Type))
+	buf.bufHeader.WriteByte(uint8(header.Length))
+	if header.Version == message.V2 {
+		buf.bufHeader.WriteByte(uint8(header.Length >> 8))
 	}
 
 	return nil
 }
 
-// encodeFooter calculates and encodes the CRC footer based on transport type.
-func (buf *buffer) encodeFooter(transportType message.TransportCRC) error {
+// encodeFooter calculates and encodes the CRC footer based on transport type, computing
+// HMAC footers with keys.
+func (buf *buffer) encodeFooter(transportType message.TransportCRC, keys *message.MACKeyring) error {
 	data := buf.bytes()
-	footer := message.NewFooter(transportType, data)
+	footer := message.Footer{Bytes: keys.AppendFooter(nil, transportType, data)}
+	if len(footer.Bytes) != message.GetFooterSize(transportType) {
+		return missingFooter(transportType)
+	}
 
 	if err := binary.Write(buf.bufFooter, binary.LittleEndian, footer.Bytes); err != nil {
 		return fmt.Errorf("%w: footer encoding failed", ErrEncodingFailed)
@@ -58,6 +80,15 @@ func (buf *buffer) encodeFooter(transportType message.TransportCRC) error {
 	return nil
 }
 
+// missingFooter returns the error for a footer that cannot be computed: an HMAC footer
+// without a key for the sender, or a length footer for a V2 frame.
+func missingFooter(transportType message.TransportCRC) error {
+	if transportType.IsMAC() {
+		return fmt.Errorf("%w: no MAC key for the sender", ErrEncodingFailed)
+	}
+	return fmt.Errorf("%w: length footer limits payloads to %d bytes", ErrPayloadTooLarge, message.MaxPayloadV1)
+}
+
 // writeField encodes a binary field to the payload buffer with error context.
 func (buf *buffer) writeField(field interface{}, fieldName string) error {
 	if err := binary.Write(buf.bufPayload, binary.LittleEndian, field); err != nil {
@@ -258,3 +289,23 @@ func (buf *buffer) encodeDataMulti(m *message.SensorDataMulti) error {
 
 	return nil
 }
+
+// encodeSecure encodes a SecureMessage with its key, session, counter, and ciphertext.
+func (buf *buffer) encodeSecure(msg *message.SecureMessage) error {
+	buf.bufPayload.WriteByte(msg.KeyID)
+
+	if err := buf.writeField(msg.Session, "session"); err != nil {
+		return err
+	}
+	if err := buf.writeField(msg.Counter, "counter"); err != nil {
+		return err
+	}
+	if err := buf.writeField(uint16(len(msg.Ciphertext)), "ciphertext length"); err != nil {
+		return err
+	}
+	if _, err := buf.bufPayload.Write(msg.Ciphertext); err != nil {
+		return fmt.Errorf("%w: ciphertext encoding failed", ErrEncodingFailed)
+	}
+
+	return nil
+}
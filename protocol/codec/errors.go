@@ -18,8 +18,16 @@ var (
 	ErrUnsupportedVersion = errors.New("unsupported protocol version") // Unknown protocol version
 	ErrInvalidFooter      = errors.New("footer validation failed")   // Footer CRC validation failed
 	ErrInvalidCRC         = errors.New("CRC validation failed")      // CRC checksum mismatch
+	ErrInvalidMAC         = errors.New("message authentication failed") // HMAC footer mismatch or no key for the sensor
 	ErrPayloadTooLarge    = errors.New("payload exceeds maximum size") // Payload size exceeds limits
 	ErrMessageTooShort    = errors.New("message too short")          // Message shorter than minimum size
 	ErrInvalidHeader      = errors.New("invalid header")             // Header structure is invalid
 	ErrUnknownMessageType = errors.New("unknown message type")       // Unrecognized message type for decoding
+	ErrTrailingData       = errors.New("trailing data after message") // Bytes left over after the message in strict mode
+	ErrValueOutOfRange    = errors.New("value out of range")         // Enumerated field holds an undefined value in strict mode
+
+	// Registry errors
+	ErrReservedMessageType = errors.New("message type outside the vendor range") // Registered type is reserved for the protocol
+	ErrTypeRegistered      = errors.New("message type already registered")       // Type was registered before
+	ErrInvalidTypeCodec    = errors.New("invalid type codec")                    // TypeCodec is incomplete or does not match its type
 )
@@ -0,0 +1,198 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"kinetica-protocol/protocol/message"
+	"sync/atomic"
+)
+
+// FrameReader extracts complete protocol frames from a byte stream.
+// It scans for the magic bytes, validates the header and footer of each candidate frame,
+// and discards input one byte at a time until a valid frame is found, so a stream
+// recovers on its own from noise, truncated frames, or a misaligned start.
+type FrameReader struct {
+	reader       *bufio.Reader        // Buffered stream large enough to hold a full frame
+	transportCRC message.TransportCRC // Footer type used to validate frames
+	keys         *message.MACKeyring  // Keys of HMAC footers (nil for none)
+	maxPayload   int                  // Largest payload length accepted as plausible
+	skipped      atomic.Uint64        // Total bytes discarded while resynchronizing
+	crcErrors    atomic.Uint64        // Candidate frames rejected because the footer did not match
+}
+
+// FrameBufferSize returns the buffer size needed to hold a complete frame with the given
+// maximum payload length. Readers sharing a bufio.Reader with a FrameReader should use it.
+func FrameBufferSize(maxPayload int) int {
+	return message.HeaderSizeV2 + min(maxPayload, message.MaxPayloadV2) + message.MaxFooterSize
+}
+
+// NewFrameReader creates a frame reader over the stream.
+// If r is already a bufio.Reader of sufficient size it is used directly, so callers
+// can keep peeking at the same buffered stream.
+func NewFrameReader(r io.Reader, transportCRC message.TransportCRC, maxPayload int) *FrameReader {
+	if maxPayload <= 0 || maxPayload > message.MaxPayloadV2 {
+		maxPayload = message.MaxPayloadV2
+	}
+
+	return &FrameReader{
+		reader:       bufio.NewReaderSize(r, FrameBufferSize(maxPayload)),
+		transportCRC: transportCRC,
+		maxPayload:   maxPayload,
+	}
+}
+
+// SetMACKeyring sets the keys used to verify HMAC footers. It must be called before the
+// first ReadFrame; without keys, every frame with an HMAC footer is discarded.
+func (fr *FrameReader) SetMACKeyring(keys *message.MACKeyring) {
+	fr.keys = keys
+}
+
+// ReadFrame returns the next frame with a valid header and footer.
+// Bytes that cannot start a valid frame are discarded and counted in Skipped.
+// Errors from the underlying stream are returned unchanged; bytes already buffered
+// are kept, so a read interrupted by a timeout can be resumed with another call.
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	footerSize := message.GetFooterSize(fr.transportCRC)
+
+	for {
+		start, err := fr.reader.Peek(len(message.MagicBytes))
+		if err != nil {
+			return nil, err
+		}
+
+		if start[0] != message.MagicBytes[0] || start[1] != message.MagicBytes[1] {
+			fr.skip(1)
+			continue
+		}
+
+		fixed, err := fr.reader.Peek(message.HeaderSize)
+		if err != nil {
+			return nil, err
+		}
+
+		headerSize := message.HeaderSizeFor(message.Version(fixed[3]))
+		if headerSize == 0 {
+			fr.skip(1)
+			continue
+		}
+
+		headerBuf, err := fr.reader.Peek(headerSize)
+		if err != nil {
+			return nil, err
+		}
+
+		header, err := ParseHeader(headerBuf)
+		if err != nil || int(header.Length) > fr.maxPayload {
+			fr.skip(1)
+			continue
+		}
+
+		dataSize := headerSize + int(header.Length)
+		frame, err := fr.reader.Peek(dataSize + footerSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if footerSize > 0 {
+			expected := fr.keys.AppendFooter(nil, fr.transportCRC, frame[:dataSize])
+			if !footerMatches(fr.transportCRC, frame[dataSize:], expected) {
+				fr.crcErrors.Add(1)
+				fr.skip(1)
+				continue
+			}
+		}
+
+		result := make([]byte, len(frame))
+		copy(result, frame)
+		_, _ = fr.reader.Discard(len(frame))
+
+		return result, nil
+	}
+}
+
+// Skipped returns the total number of bytes discarded while resynchronizing.
+func (fr *FrameReader) Skipped() uint64 {
+	return fr.skipped.Load()
+}
+
+// CRCErrors returns the number of candidate frames with a well-formed header whose
+// footer did not match the frame contents, including failed HMAC footers.
+func (fr *FrameReader) CRCErrors() uint64 {
+	return fr.crcErrors.Load()
+}
+
+// skip discards n bytes from the stream and records them as skipped.
+func (fr *FrameReader) skip(n int) {
+	discarded, _ := fr.reader.Discard(n)
+	fr.skipped.Add(uint64(discarded))
+}
+
+// SplitFrames splits a datagram holding one or more concatenated frames into its frames.
+// Unlike FrameReader it does not resynchronize: the datagram must start with a frame,
+// every header and footer must be valid, and the frames must cover it exactly. Any
+// violation rejects the whole datagram. The returned frames share memory with data.
+func SplitFrames(data []byte, transportCRC message.TransportCRC, maxPayload int) ([][]byte, error) {
+	return SplitFramesKeyed(data, transportCRC, maxPayload, nil)
+}
+
+// SplitFramesKeyed splits a datagram like SplitFrames, verifying HMAC footers with the
+// key of each frame's sender in keys. SplitFrames has no keys, so it rejects every
+// datagram with an HMAC footer.
+func SplitFramesKeyed(data []byte, transportCRC message.TransportCRC, maxPayload int, keys *message.MACKeyring) ([][]byte, error) {
+	if maxPayload <= 0 || maxPayload > message.MaxPayloadV2 {
+		maxPayload = message.MaxPayloadV2
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty datagram", ErrMessageTooShort)
+	}
+
+	footerSize := message.GetFooterSize(transportCRC)
+	var frames [][]byte
+
+	for offset := 0; offset < len(data); {
+		header, err := ParseHeader(data[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("frame at offset %d: %w", offset, err)
+		}
+		if int(header.Length) > maxPayload {
+			return nil, fmt.Errorf("%w: frame at offset %d declares %d bytes, maximum is %d",
+				ErrPayloadTooLarge, offset, header.Length, maxPayload)
+		}
+
+		dataSize := header.Size() + int(header.Length)
+		frameSize := dataSize + footerSize
+		if frameSize > len(data)-offset {
+			return nil, fmt.Errorf("%w: frame at offset %d needs %d bytes, datagram has %d",
+				ErrInsufficientData, offset, frameSize, len(data)-offset)
+		}
+
+		frame := data[offset : offset+frameSize]
+		if footerSize > 0 {
+			expected := keys.AppendFooter(nil, transportCRC, frame[:dataSize])
+			if !footerMatches(transportCRC, frame[dataSize:], expected) {
+				if transportCRC.IsMAC() {
+					return nil, fmt.Errorf("%w: frame at offset %d", ErrInvalidMAC, offset)
+				}
+				return nil, fmt.Errorf("%w: frame at offset %d: expected %x, got %x",
+					ErrInvalidFooter, offset, expected, frame[dataSize:])
+			}
+		}
+
+		frames = append(frames, frame)
+		offset += frameSize
+	}
+
+	return frames, nil
+}
+
+// footerMatches compares a received footer with the expected one, in constant time for
+// HMAC footers. An empty expected HMAC footer, for a sender without a key, never matches.
+func footerMatches(transportCRC message.TransportCRC, received, expected []byte) bool {
+	if transportCRC.IsMAC() {
+		return len(expected) == len(received) && hmac.Equal(received, expected)
+	}
+	return bytes.Equal(received, expected)
+}
@@ -0,0 +1,222 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"kinetica-protocol/protocol/message"
+	"testing"
+)
+
+func heartbeatFrame(t *testing.T, packetID uint8, transport message.TransportCRC) []byte {
+	t.Helper()
+	data, err := Marshal(&message.SensorHeartbeat{SensorID: 1, TimeStamp: 12345, Battery: 80, Status: message.Ok}, packetID, message.MsgTypeHeartbeat, transport)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return data
+}
+
+func TestFrameReader_ValidStream(t *testing.T) {
+	first := heartbeatFrame(t, 1, message.TransportCRC8)
+	second := heartbeatFrame(t, 2, message.TransportCRC8)
+
+	fr := NewFrameReader(bytes.NewReader(append(append([]byte{}, first...), second...)), message.TransportCRC8, 255)
+
+	for i, expected := range [][]byte{first, second} {
+		frame, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d error = %v", i, err)
+		}
+		if !bytes.Equal(frame, expected) {
+			t.Errorf("Frame %d mismatch: got %x, want %x", i, frame, expected)
+		}
+	}
+
+	if _, err := fr.ReadFrame(); err != io.EOF {
+		t.Errorf("Expected io.EOF at end of stream, got %v", err)
+	}
+	if fr.Skipped() != 0 {
+		t.Errorf("Expected no skipped bytes, got %d", fr.Skipped())
+	}
+}
+
+func TestFrameReader_ResyncAfterGarbage(t *testing.T) {
+	frame := heartbeatFrame(t, 1, message.TransportCRC16)
+	garbage := []byte{0x00, 0xFF, 'K', 0x13, 'K', 'N', 0x01}
+
+	fr := NewFrameReader(bytes.NewReader(append(garbage, frame...)), message.TransportCRC16, 255)
+
+	got, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame error = %v", err)
+	}
+	if !bytes.Equal(got, frame) {
+		t.Errorf("Frame mismatch: got %x, want %x", got, frame)
+	}
+	if fr.Skipped() != uint64(len(garbage)) {
+		t.Errorf("Expected %d skipped bytes, got %d", len(garbage), fr.Skipped())
+	}
+}
+
+func TestFrameReader_SkipsCorruptFrame(t *testing.T) {
+	corrupt := heartbeatFrame(t, 1, message.TransportCRC8)
+	corrupt[8] ^= 0xFF
+	valid := heartbeatFrame(t, 2, message.TransportCRC8)
+
+	fr := NewFrameReader(bytes.NewReader(append(corrupt, valid...)), message.TransportCRC8, 255)
+
+	got, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame error = %v", err)
+	}
+	if got[2] != 2 {
+		t.Errorf("Expected packet 2 after corrupt packet, got packet %d", got[2])
+	}
+	if fr.Skipped() != uint64(len(corrupt)) {
+		t.Errorf("Expected %d skipped bytes, got %d", len(corrupt), fr.Skipped())
+	}
+	if fr.CRCErrors() != 1 {
+		t.Errorf("Expected 1 CRC error, got %d", fr.CRCErrors())
+	}
+}
+
+func TestFrameReader_RejectsOversizedLength(t *testing.T) {
+	frame := heartbeatFrame(t, 1, message.TransportNone)
+	bogus := []byte{'K', 'N', 0x09, 0x01, 0x03, 0xFF}
+
+	fr := NewFrameReader(bytes.NewReader(append(bogus, frame...)), message.TransportNone, 32)
+
+	got, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame error = %v", err)
+	}
+	if !bytes.Equal(got, frame) {
+		t.Errorf("Frame mismatch: got %x, want %x", got, frame)
+	}
+}
+
+type interruptedReader struct {
+	chunks [][]byte
+}
+
+var errInterrupted = errors.New("interrupted")
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	chunk := r.chunks[0]
+	r.chunks = r.chunks[1:]
+	if chunk == nil {
+		return 0, errInterrupted
+	}
+	return copy(p, chunk), nil
+}
+
+func TestFrameReader_ResumeAfterError(t *testing.T) {
+	frame := heartbeatFrame(t, 1, message.TransportCRC8)
+	r := &interruptedReader{chunks: [][]byte{frame[:5], nil, frame[5:]}}
+
+	fr := NewFrameReader(r, message.TransportCRC8, 255)
+
+	if _, err := fr.ReadFrame(); !errors.Is(err, errInterrupted) {
+		t.Fatalf("Expected interrupted error, got %v", err)
+	}
+
+	got, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame error = %v", err)
+	}
+	if !bytes.Equal(got, frame) {
+		t.Errorf("Frame mismatch after resume: got %x, want %x", got, frame)
+	}
+}
+
+func TestSplitFrames(t *testing.T) {
+	first := heartbeatFrame(t, 1, message.TransportCRC8)
+	second := heartbeatFrame(t, 2, message.TransportCRC8)
+	datagram := append(append([]byte{}, first...), second...)
+
+	frames, err := SplitFrames(datagram, message.TransportCRC8, 255)
+	if err != nil {
+		t.Fatalf("SplitFrames error = %v", err)
+	}
+	if len(frames) != 2 || !bytes.Equal(frames[0], first) || !bytes.Equal(frames[1], second) {
+		t.Errorf("Unexpected frames: %x", frames)
+	}
+}
+
+func TestSplitFrames_RejectsDatagram(t *testing.T) {
+	frame := heartbeatFrame(t, 1, message.TransportCRC8)
+
+	corrupt := append([]byte{}, frame...)
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	tests := []struct {
+		name     string
+		datagram []byte
+		wantErr  error
+	}{
+		{"empty", nil, ErrMessageTooShort},
+		{"truncated", frame[:len(frame)-1], ErrInsufficientData},
+		{"trailing bytes", append(append([]byte{}, frame...), 0x00), ErrMessageTooShort},
+		{"leading garbage", append([]byte{0x00}, frame...), ErrInvalidMagicBytes},
+		{"bad footer", corrupt, ErrInvalidFooter},
+		{"bad second frame", append(append([]byte{}, frame...), corrupt...), ErrInvalidFooter},
+		{"oversized", frame, ErrPayloadTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxPayload := 255
+			if tt.name == "oversized" {
+				maxPayload = 1
+			}
+			frames, err := SplitFrames(tt.datagram, message.TransportCRC8, maxPayload)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SplitFrames error = %v, want %v", err, tt.wantErr)
+			}
+			if frames != nil {
+				t.Errorf("Expected no frames, got %d", len(frames))
+			}
+		})
+	}
+}
+
+func TestFrameReader_HMACFooter(t *testing.T) {
+	keys := message.NewMACKeyring()
+	keys.Set(1, []byte("sensor-1-key"))
+
+	heartbeat := &message.SensorHeartbeat{SensorID: 1, TimeStamp: 12345, Battery: 80, Status: message.Ok}
+	forged, err := MarshalKeyed(heartbeat, 1, message.MsgTypeHeartbeat, message.TransportHMAC128, keys)
+	if err != nil {
+		t.Fatalf("MarshalKeyed failed: %v", err)
+	}
+	forged[message.HeaderSize+1] ^= 0xFF
+	valid, err := MarshalKeyed(heartbeat, 2, message.MsgTypeHeartbeat, message.TransportHMAC128, keys)
+	if err != nil {
+		t.Fatalf("MarshalKeyed failed: %v", err)
+	}
+
+	fr := NewFrameReader(bytes.NewReader(append(append([]byte{}, forged...), valid...)), message.TransportHMAC128, 255)
+	fr.SetMACKeyring(keys)
+
+	frame, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame error = %v", err)
+	}
+	if !bytes.Equal(frame, valid) {
+		t.Errorf("Expected the authentic frame, got %x", frame)
+	}
+	if fr.CRCErrors() != 1 {
+		t.Errorf("Expected 1 rejected frame, got %d", fr.CRCErrors())
+	}
+
+	if _, err := SplitFramesKeyed(forged, message.TransportHMAC128, 255, keys); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("SplitFramesKeyed error = %v, want %v", err, ErrInvalidMAC)
+	}
+	if _, err := SplitFrames(valid, message.TransportHMAC128, 255); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("SplitFrames without keys error = %v, want %v", err, ErrInvalidMAC)
+	}
+}
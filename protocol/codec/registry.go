@@ -0,0 +1,107 @@
+package codec
+
+import (
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"sync"
+)
+
+// TypeCodec encodes and decodes an application-defined message type. Once registered
+// with Register, the type is handled by every encoding and decoding function of the
+// package, and so by every transport, like the built-in types.
+type TypeCodec struct {
+	New    func() message.Message                                // Returns a new, empty message of the type
+	Append func(dst []byte, msg message.Message) ([]byte, error) // Appends the encoded payload of msg to dst
+	Decode func(payload []byte, msg message.Message) error       // Decodes a payload into a message returned by New
+}
+
+// Registered message types, guarded by registryMu.
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[message.MsgType]TypeCodec)
+)
+
+// Register installs the codec of an application-defined message type. The type must lie
+// in the vendor range (message.MsgTypeVendorMin to message.MsgTypeVendorMax), all functions
+// of the codec must be set, and messages returned by New must report the type.
+//
+// Decode receives the payload of the packet, which is only valid during the call, and
+// must copy any bytes the message keeps. It may be called with a message it decoded
+// before, as Decoder reuses one message of each type, and should reuse its slices.
+//
+// Returns an error if the type is reserved, already registered, or the codec is invalid.
+func Register(msgType message.MsgType, c TypeCodec) error {
+	if !msgType.IsVendor() {
+		return fmt.Errorf("%w: 0x%02x", ErrReservedMessageType, uint8(msgType))
+	}
+	if c.New == nil || c.Append == nil || c.Decode == nil {
+		return fmt.Errorf("%w: New, Append, and Decode are required", ErrInvalidTypeCodec)
+	}
+	if msg := c.New(); msg == nil || msg.MessageType() != msgType {
+		return fmt.Errorf("%w: New returns %T instead of a message of type 0x%02x", ErrInvalidTypeCodec, msg, uint8(msgType))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[msgType]; ok {
+		return fmt.Errorf("%w: 0x%02x", ErrTypeRegistered, uint8(msgType))
+	}
+	registry[msgType] = c
+	return nil
+}
+
+// Unregister removes the codec of a message type. Packets of the type are no longer
+// encoded or decoded.
+func Unregister(msgType message.MsgType) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, msgType)
+}
+
+// lookup returns the registered codec of a message type.
+func lookup(msgType message.MsgType) (TypeCodec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[msgType]
+	return c, ok
+}
+
+// newRegistered returns a new message of a registered type, or nil if it is unknown.
+func newRegistered(msgType message.MsgType) message.Message {
+	if c, ok := lookup(msgType); ok {
+		return c.New()
+	}
+	return nil
+}
+
+// appendRegistered appends the payload of a message of a registered type to dst.
+// On error dst is returned unchanged with the error.
+func appendRegistered(dst []byte, msg message.Message) ([]byte, error) {
+	if msg == nil {
+		return dst, ErrInvalidMessageType
+	}
+	c, ok := lookup(msg.MessageType())
+	if !ok {
+		return dst, ErrInvalidMessageType
+	}
+
+	start := len(dst)
+	dst, err := c.Append(dst, msg)
+	if err != nil {
+		return dst[:start], fmt.Errorf("%w: type 0x%02x: %w", ErrEncodingFailed, uint8(msg.MessageType()), err)
+	}
+	return dst, nil
+}
+
+// decodeRegistered decodes the payload of a registered type into msg.
+func decodeRegistered(payload []byte, msg message.Message) error {
+	c, ok := lookup(msg.MessageType())
+	if !ok {
+		return ErrInvalidMessageType
+	}
+	if err := c.Decode(payload, msg); err != nil {
+		return fmt.Errorf("%w: type 0x%02x: %w", ErrDecodingFailed, uint8(msg.MessageType()), err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"reflect"
+	"testing"
+)
+
+const msgTypeEMG = message.MsgTypeVendorMin + 1
+
+// emgFrame is a vendor message carrying a block of 16-bit EMG samples.
+type emgFrame struct {
+	SensorID uint8
+	Samples  []int16
+}
+
+func (*emgFrame) MessageType() message.MsgType {
+	return msgTypeEMG
+}
+
+var errNoSamples = errors.New("no samples")
+
+var emgCodec = TypeCodec{
+	New: func() message.Message { return &emgFrame{} },
+	Append: func(dst []byte, msg message.Message) ([]byte, error) {
+		m := msg.(*emgFrame)
+		if len(m.Samples) == 0 {
+			return dst, errNoSamples
+		}
+		dst = append(dst, m.SensorID, uint8(len(m.Samples)))
+		for _, sample := range m.Samples {
+			dst = binary.LittleEndian.AppendUint16(dst, uint16(sample))
+		}
+		return dst, nil
+	},
+	Decode: func(payload []byte, msg message.Message) error {
+		m := msg.(*emgFrame)
+		if len(payload) < 2 || len(payload) != 2+2*int(payload[1]) {
+			return ErrInsufficientData
+		}
+		m.SensorID = payload[0]
+		m.Samples = resize(m.Samples, int(payload[1]))
+		for i := range m.Samples {
+			m.Samples[i] = int16(binary.LittleEndian.Uint16(payload[2+2*i:]))
+		}
+		return nil
+	},
+}
+
+// registerEMG registers the EMG vendor type for the duration of the test.
+func registerEMG(t testing.TB) {
+	t.Helper()
+	if err := Register(msgTypeEMG, emgCodec); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	t.Cleanup(func() { Unregister(msgTypeEMG) })
+}
+
+func TestRegister_Errors(t *testing.T) {
+	if err := Register(message.MsgTypeSensorData, emgCodec); !errors.Is(err, ErrReservedMessageType) {
+		t.Errorf("expected ErrReservedMessageType for a built-in type, got %v", err)
+	}
+	if err := Register(0x20, emgCodec); !errors.Is(err, ErrReservedMessageType) {
+		t.Errorf("expected ErrReservedMessageType below the vendor range, got %v", err)
+	}
+	if err := Register(msgTypeEMG, TypeCodec{New: emgCodec.New}); !errors.Is(err, ErrInvalidTypeCodec) {
+		t.Errorf("expected ErrInvalidTypeCodec for a codec without functions, got %v", err)
+	}
+	if err := Register(msgTypeEMG+1, emgCodec); !errors.Is(err, ErrInvalidTypeCodec) {
+		t.Errorf("expected ErrInvalidTypeCodec for a mismatched constructor, got %v", err)
+	}
+
+	registerEMG(t)
+	if err := Register(msgTypeEMG, emgCodec); !errors.Is(err, ErrTypeRegistered) {
+		t.Errorf("expected ErrTypeRegistered, got %v", err)
+	}
+}
+
+func TestRegistry_RoundTrip(t *testing.T) {
+	registerEMG(t)
+	emg := &emgFrame{SensorID: 3, Samples: []int16{-120, 0, 870, 32767}}
+
+	data, err := Marshal(emg, 7, msgTypeEMG, message.TransportCRC16)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	appended, err := AppendMarshal(nil, emg, 7, msgTypeEMG, message.TransportCRC16)
+	if err != nil {
+		t.Fatalf("AppendMarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(appended, data) {
+		t.Errorf("AppendMarshal = %x, Marshal = %x", appended, data)
+	}
+
+	decoded := map[string]func() (message.Message, error){
+		"Unmarshal": func() (message.Message, error) { return Unmarshal(data, message.TransportCRC16) },
+		"UnmarshalStrict": func() (message.Message, error) {
+			return UnmarshalStrict(data, message.TransportCRC16)
+		},
+		"UnmarshalInto": func() (message.Message, error) {
+			msg := &emgFrame{}
+			_, err := UnmarshalInto(data, message.TransportCRC16, msg)
+			return msg, err
+		},
+		"Decoder": func() (message.Message, error) {
+			envelope, err := NewDecoder(message.TransportCRC16).Decode(data)
+			if err != nil {
+				return nil, err
+			}
+			return envelope.Payload, nil
+		},
+	}
+	for name, decode := range decoded {
+		msg, err := decode()
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(msg, emg) {
+			t.Errorf("%s: got %+v, want %+v", name, msg, emg)
+		}
+	}
+
+	payload, err := MarshalPayload(emg)
+	if err != nil {
+		t.Fatalf("MarshalPayload failed: %v", err)
+	}
+	msg, err := UnmarshalPayload(msgTypeEMG, payload)
+	if err != nil {
+		t.Fatalf("UnmarshalPayload failed: %v", err)
+	}
+	if !reflect.DeepEqual(msg, emg) {
+		t.Errorf("UnmarshalPayload: got %+v, want %+v", msg, emg)
+	}
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	registerEMG(t)
+
+	if _, err := Marshal(&emgFrame{SensorID: 1}, 1, msgTypeEMG, message.TransportNone); !errors.Is(err, ErrEncodingFailed) || !errors.Is(err, errNoSamples) {
+		t.Errorf("expected ErrEncodingFailed wrapping the codec error, got %v", err)
+	}
+
+	data, err := Marshal(&emgFrame{SensorID: 1, Samples: []int16{1, 2}}, 1, msgTypeEMG, message.TransportNone)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	data[7] = 3 // Sample count no longer matches the payload
+
+	if _, err := Unmarshal(data, message.TransportNone); !errors.Is(err, ErrDecodingFailed) {
+		t.Errorf("expected ErrDecodingFailed, got %v", err)
+	}
+	_, err = UnmarshalStrict(data, message.TransportNone)
+	requireDecodeError(t, err, message.HeaderSize, "payload", ErrInsufficientData)
+
+	Unregister(msgTypeEMG)
+	if _, err := Marshal(&emgFrame{SensorID: 1, Samples: []int16{1}}, 1, msgTypeEMG, message.TransportNone); !errors.Is(err, ErrInvalidMessageType) {
+		t.Errorf("expected ErrInvalidMessageType after Unregister, got %v", err)
+	}
+	if _, err := NewDecoder(message.TransportNone).Decode(data); !errors.Is(err, ErrUnknownMessageType) {
+		t.Errorf("expected ErrUnknownMessageType after Unregister, got %v", err)
+	}
+	if envelope, err := UnmarshalFrame(data, message.TransportNone); !errors.Is(err, ErrUnknownMessageType) || envelope != nil {
+		t.Errorf("expected UnmarshalFrame to fail with ErrUnknownMessageType after Unregister, got %v, %v", envelope, err)
+	}
+}
+
+func TestDecoder_RegisteredZeroAllocs(t *testing.T) {
+	registerEMG(t)
+	data, err := Marshal(&emgFrame{SensorID: 2, Samples: make([]int16, 64)}, 1, msgTypeEMG, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	d := NewDecoder(message.TransportCRC8)
+	if _, err := d.Decode(data); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := d.Decode(data); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected 0 allocations per Decode, got %v", allocs)
+	}
+}
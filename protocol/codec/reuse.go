@@ -0,0 +1,458 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"math"
+)
+
+// UnmarshalInto decodes a packet into a caller-provided message, validating the header
+// and footer like UnmarshalFrame. The message must be a pointer to the struct matching
+// the packet type (for example *message.SensorData for MsgTypeSensorData); slices it
+// already holds are reused, and byte fields such as Fragment.Data or Item.Value
+// reference data instead of being copied. Fields are read directly without reflection,
+// so decoding into a message reused across packets does not allocate once its slices
+// have grown. Packets with HMAC footers are rejected; a Decoder given keys with
+// SetMACKeyring verifies them.
+//
+// Returns the decoded header or an error if validation or decoding fails.
+func UnmarshalInto(data []byte, transport message.TransportCRC, msg message.Message) (message.Header, error) {
+	return unmarshalInto(data, transport, nil, msg, fieldReader{})
+}
+
+// unmarshalInto decodes a packet into msg, verifying HMAC footers with keys. The reader
+// selects strict validation and whether byte fields are copied out of data.
+func unmarshalInto(data []byte, transport message.TransportCRC, keys *message.MACKeyring, msg message.Message, r fieldReader) (message.Header, error) {
+	header, err := readHeader(data)
+	if err != nil {
+		return message.Header{}, r.headerError(data, err)
+	}
+
+	dataSize := header.Size() + int(header.Length)
+	if len(data) < dataSize {
+		return message.Header{}, r.annotate(len(data), "payload", fmt.Errorf("%w: need %d bytes, got %d", ErrInsufficientData, dataSize, len(data)))
+	}
+	if msg == nil || msg.MessageType() != header.Type {
+		return message.Header{}, fmt.Errorf("%w: packet type 0x%02x does not match %T", ErrInvalidMessageType, uint8(header.Type), msg)
+	}
+
+	r.data = data[header.Size():dataSize]
+	r.offset = header.Size()
+	if err := r.decode(msg); err != nil {
+		return message.Header{}, err
+	}
+	if r.strict && len(r.data) > 0 {
+		return message.Header{}, r.annotate(r.offset, "payload", fmt.Errorf("%w: %d unread payload bytes", ErrTrailingData, len(r.data)))
+	}
+
+	if err := checkFooter(transport, keys, data[:dataSize], data[dataSize:]); err != nil {
+		return message.Header{}, r.annotate(dataSize, "footer", err)
+	}
+	if frameSize := dataSize + message.GetFooterSize(transport); r.strict && len(data) > frameSize {
+		return message.Header{}, r.annotate(frameSize, "footer", fmt.Errorf("%w: %d bytes after the footer", ErrTrailingData, len(data)-frameSize))
+	}
+
+	return header, nil
+}
+
+// Decoder decodes packets of any type without per-packet heap allocations. It keeps one
+// message of each type and decodes every packet into the message of its type, so the
+// envelope and message returned by Decode are only valid until the next call, and
+// byte fields reference the decoded data. A Decoder is not safe for concurrent use.
+type Decoder struct {
+	transport message.TransportCRC                // Footer type used to validate packets
+	strict    bool                                // Validate like UnmarshalFrameStrict
+	keys      *message.MACKeyring                 // Keys of HMAC footers (nil for none)
+	envelope  message.Envelope                    // Envelope returned by Decode
+	command   message.SensorCommand               // Reused SensorCommand
+	config    message.SensorConfig                // Reused SensorConfig
+	heartbeat message.SensorHeartbeat             // Reused SensorHeartbeat
+	data      message.SensorData                  // Reused SensorData
+	custom    message.CustomData                  // Reused CustomData
+	timeSync  message.TimeSync                    // Reused TimeSync
+	ack       message.Ack                         // Reused Ack
+	register  message.Registration                // Reused Registration
+	fragment  message.Fragment                    // Reused Fragment
+	relayed   message.RelayedMessage              // Reused RelayedMessage
+	dataMulti message.SensorDataMulti             // Reused SensorDataMulti
+	secure    message.SecureMessage               // Reused SecureMessage
+	vendor    map[message.MsgType]message.Message // Reused messages of registered types
+}
+
+// NewDecoder creates a decoder validating footers of the given transport type.
+func NewDecoder(transport message.TransportCRC) *Decoder {
+	return &Decoder{transport: transport}
+}
+
+// SetMACKeyring sets the keys used to verify HMAC footers. Without keys, every packet with
+// an HMAC footer is rejected.
+func (d *Decoder) SetMACKeyring(keys *message.MACKeyring) {
+	d.keys = keys
+}
+
+// Decode decodes a packet like UnmarshalFrame into the decoder's reused envelope and
+// messages. Raw references data and ReceivedAt is left zero.
+//
+// Returns the decoded envelope or an error if validation or decoding fails.
+func (d *Decoder) Decode(data []byte) (*message.Envelope, error) {
+	r := fieldReader{strict: d.strict}
+
+	header, err := readHeader(data)
+	if err != nil {
+		return nil, r.headerError(data, err)
+	}
+
+	msg := d.message(header.Type)
+	if msg == nil {
+		return nil, r.annotate(4, "type", fmt.Errorf("%w: 0x%02x", ErrUnknownMessageType, uint8(header.Type)))
+	}
+
+	header, err = unmarshalInto(data, d.transport, d.keys, msg, r)
+	if err != nil {
+		return nil, err
+	}
+
+	dataSize := header.Size() + int(header.Length)
+	footerSize := message.GetFooterSize(d.transport)
+
+	d.envelope = message.Envelope{
+		Header:  header,
+		Payload: msg,
+		Footer:  message.Footer{Bytes: data[dataSize : dataSize+footerSize]},
+		Raw:     data,
+	}
+	return &d.envelope, nil
+}
+
+// message returns the reused message for a packet type, or nil for unknown types.
+func (d *Decoder) message(msgType message.MsgType) message.Message {
+	switch msgType {
+	case message.MsgTypeCommand:
+		return &d.command
+	case message.MsgTypeConfig:
+		return &d.config
+	case message.MsgTypeHeartbeat:
+		return &d.heartbeat
+	case message.MsgTypeSensorData:
+		return &d.data
+	case message.MsgTypeCustom:
+		return &d.custom
+	case message.MsgTypeTimeSync:
+		return &d.timeSync
+	case message.MsgTypeAck:
+		return &d.ack
+	case message.MsgTypeRegister:
+		return &d.register
+	case message.MsgTypeFragment:
+		return &d.fragment
+	case message.MsgTypeRelayed:
+		return &d.relayed
+	case message.MsgTypeSensorDataMulti:
+		return &d.dataMulti
+	case message.MsgTypeSecure:
+		return &d.secure
+	default:
+		return d.registered(msgType)
+	}
+}
+
+// registered returns the reused message of a registered type, creating it on first use,
+// or nil if the type is not registered.
+func (d *Decoder) registered(msgType message.MsgType) message.Message {
+	c, ok := lookup(msgType)
+	if !ok {
+		return nil
+	}
+	if msg, ok := d.vendor[msgType]; ok {
+		return msg
+	}
+	if d.vendor == nil {
+		d.vendor = make(map[message.MsgType]message.Message)
+	}
+	msg := c.New()
+	d.vendor[msgType] = msg
+	return msg
+}
+
+// readHeader decodes the protocol header at the start of data without allocating.
+// It validates the magic bytes first, then selects the header layout by version.
+func readHeader(data []byte) (message.Header, error) {
+	if len(data) < message.HeaderSize {
+		return message.Header{}, fmt.Errorf("%w: need at least %d bytes", ErrMessageTooShort, message.HeaderSize)
+	}
+
+	header := message.Header{
+		Magic:    [2]byte{data[0], data[1]},
+		PacketID: data[2],
+		Version:  message.Version(data[3]),
+		Type:     message.MsgType(data[4]),
+	}
+
+	if header.Magic != message.MagicBytes {
+		return message.Header{}, fmt.Errorf("%w: got %x", ErrInvalidMagicBytes, header.Magic)
+	}
+
+	switch header.Version {
+	case message.V1:
+		header.Length = uint16(data[5])
+	case message.V2:
+		if len(data) < message.HeaderSizeV2 {
+			return message.Header{}, fmt.Errorf("%w: need %d bytes for V2 header", ErrMessageTooShort, message.HeaderSizeV2)
+		}
+		header.Length = uint16(data[5]) | uint16(data[6])<<8
+	default:
+		return message.Header{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, header.Version)
+	}
+
+	return header, nil
+}
+
+// checkFooter verifies the footer following a frame without allocating for checksum
+// footers. HMAC footers are computed with keys, compared in constant time, and fail with
+// ErrInvalidMAC, and V2 frames are rejected with length footers.
+func checkFooter(transport message.TransportCRC, keys *message.MACKeyring, frame, footer []byte) error {
+	footerSize := message.GetFooterSize(transport)
+	if footerSize == 0 {
+		return nil
+	}
+	if len(footer) < footerSize {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidFooter, footerSize, len(footer))
+	}
+	footer = footer[:footerSize]
+
+	var sum [message.MaxFooterSize]byte
+	expected := keys.AppendFooter(sum[:0], transport, frame)
+	if footerMatches(transport, footer, expected) {
+		return nil
+	}
+
+	if transport.IsMAC() {
+		if len(expected) != footerSize {
+			return fmt.Errorf("%w: no key for the sender", ErrInvalidMAC)
+		}
+		return fmt.Errorf("%w: footer does not match", ErrInvalidMAC)
+	}
+	if len(expected) != footerSize {
+		return fmt.Errorf("%w: length footer is not defined for V2 frames", ErrInvalidFooter)
+	}
+	return fmt.Errorf("%w: expected %x, got %x", ErrInvalidFooter, append([]byte(nil), expected...), footer)
+}
+
+// fieldReader reads little-endian fields from a payload without reflection or copies.
+// The first failed read is recorded in err and all later reads return zero values.
+// In strict mode, errors are DecodeErrors carrying the packet offset of the field and
+// enumerated fields are checked against their defined values.
+type fieldReader struct {
+	data   []byte // Remaining payload bytes
+	offset int    // Packet offset of the next payload byte
+	err    error  // First read error
+	strict bool   // Validate enumerated values and annotate errors with positions
+	owned  bool   // Copy byte fields instead of referencing the packet
+}
+
+// fail records a read of n bytes for the named field that ran past the payload.
+func (r *fieldReader) fail(name string, n int) {
+	if r.err == nil {
+		if r.strict {
+			r.err = r.annotate(r.offset, name, fmt.Errorf("%w: %w: need %d bytes, %d remain", ErrDecodingFailed, ErrInsufficientData, n, len(r.data)))
+		} else {
+			r.err = fmt.Errorf("%w: failed to read %s", ErrDecodingFailed, name)
+		}
+	}
+	r.data = nil
+}
+
+// check records an undefined value of the enumerated field just read when decoding strictly.
+func (r *fieldReader) check(name string, value uint8, valid bool) {
+	if r.strict && r.err == nil && !valid {
+		r.err = r.annotate(r.offset-1, name, fmt.Errorf("%w: 0x%02x", ErrValueOutOfRange, value))
+	}
+}
+
+// annotate wraps err in a DecodeError at the given packet offset when decoding strictly.
+func (r *fieldReader) annotate(offset int, field string, err error) error {
+	if !r.strict {
+		return err
+	}
+	return &DecodeError{Offset: offset, Field: field, Err: err}
+}
+
+// headerError annotates an error from readHeader with the offending header field.
+func (r *fieldReader) headerError(data []byte, err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidMagicBytes):
+		return r.annotate(0, "magic", err)
+	case errors.Is(err, ErrUnsupportedVersion):
+		return r.annotate(3, "version", err)
+	default:
+		return r.annotate(len(data), "header", err)
+	}
+}
+
+// next returns the next n bytes, or nil after recording an error if fewer remain.
+func (r *fieldReader) next(n int, name string) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.fail(name, n)
+		return nil
+	}
+	b := r.data[:n:n]
+	r.data = r.data[n:]
+	r.offset += n
+	return b
+}
+
+// bytes returns the next n bytes of a byte field, copied if the reader owns its output.
+func (r *fieldReader) bytes(n int, name string) []byte {
+	b := r.next(n, name)
+	if r.owned && b != nil {
+		b = append(make([]byte, 0, n), b...)
+	}
+	return b
+}
+
+func (r *fieldReader) uint8(name string) uint8 {
+	if b := r.next(1, name); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *fieldReader) uint16(name string) uint16 {
+	if b := r.next(2, name); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *fieldReader) uint32(name string) uint32 {
+	if b := r.next(4, name); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *fieldReader) uint64(name string) uint64 {
+	if b := r.next(8, name); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *fieldReader) float32(name string) float32 {
+	return math.Float32frombits(r.uint32(name))
+}
+
+// decode dispatches to the message-specific reader and returns the first read error.
+func (r *fieldReader) decode(msg message.Message) error {
+	switch m := msg.(type) {
+	case *message.SensorCommand:
+		m.SensorID = r.uint8("sensor ID")
+		m.TimeStamp = r.uint32("timestamp")
+		m.Command = r.uint8("command")
+	case *message.SensorConfig:
+		m.SensorID = r.uint8("sensor ID")
+		m.TimeStamp = r.uint32("timestamp")
+		m.Config = r.items(m.Config, "config items count")
+	case *message.SensorHeartbeat:
+		m.SensorID = r.uint8("sensor ID")
+		m.TimeStamp = r.uint32("timestamp")
+		m.Battery = r.uint8("battery level")
+		m.Status = message.Status(r.uint8("status"))
+		r.check("status", uint8(m.Status), m.Status.Valid())
+	case *message.SensorData:
+		m.SensorID = r.uint8("sensor ID")
+		m.TimeStamp = r.uint32("timestamp")
+		r.sensorData(&m.Data)
+	case *message.CustomData:
+		m.SensorID = r.uint8("sensor ID")
+		m.TimeStamp = r.uint32("timestamp")
+		m.DataType = message.CustomType(r.uint8("data type"))
+		m.Data = r.items(m.Data, "custom items count")
+	case *message.TimeSync:
+		m.SensorID = r.uint8("sensor ID")
+		m.ServerTime = r.uint32("server time")
+		m.SensorTime = r.uint32("sensor time")
+	case *message.Ack:
+		m.SensorID = r.uint8("sensor ID")
+		m.MessageID = r.uint16("message ID")
+		m.Status = message.AckStatus(r.uint8("status"))
+		r.check("ack status", uint8(m.Status), m.Status.Valid())
+	case *message.Registration:
+		m.SensorID = r.uint8("sensor ID")
+		m.DeviceType = message.DeviceType(r.uint8("device type"))
+		r.check("device type", uint8(m.DeviceType), m.DeviceType.Valid())
+		m.Capabilities = r.uint8("capabilities")
+		m.FWVersion = r.uint16("firmware version")
+	case *message.Fragment:
+		m.MessageID = r.uint16("message ID")
+		m.FragmentNum = r.uint8("fragment number")
+		m.TotalFragments = r.uint8("total fragments")
+		m.Data = r.bytes(int(r.uint16("data length")), "fragment data")
+	case *message.RelayedMessage:
+		m.RelayID = r.uint8("relay ID")
+		m.OriginalData = r.bytes(int(r.uint16("original data length")), "original data")
+	case *message.SensorDataMulti:
+		m.SensorID = r.uint8("sensor ID")
+		m.TimeStamp = r.uint32("timestamp")
+		count := int(r.uint8("length"))
+		m.Data = resize(m.Data, count)
+		for i := 0; i < count && r.err == nil; i++ {
+			r.sensorData(&m.Data[i])
+		}
+	case *message.SecureMessage:
+		m.KeyID = r.uint8("key ID")
+		m.Session = r.uint64("session")
+		m.Counter = r.uint32("counter")
+		m.Ciphertext = r.bytes(int(r.uint16("ciphertext length")), "ciphertext")
+	default:
+		if err := decodeRegistered(r.data, msg); err != nil {
+			return r.annotate(r.offset, "payload", err)
+		}
+		r.offset += len(r.data)
+		r.data = nil
+	}
+
+	return r.err
+}
+
+// sensorData reads a data type, value count, and float32 values into d, reusing its slice.
+func (r *fieldReader) sensorData(d *message.Data) {
+	d.Type = message.DataType(r.uint8("data type"))
+	r.check("data type", uint8(d.Type), d.Type.Valid())
+	count := int(r.uint8("values count"))
+	if r.err != nil || len(r.data) < count*4 {
+		r.fail("sensor values", count*4)
+		d.Values = d.Values[:0]
+		return
+	}
+
+	d.Values = resize(d.Values, count)
+	for i := range d.Values {
+		d.Values[i] = r.float32("sensor value")
+	}
+}
+
+// items reads an item count followed by each item (key-length-value) into items,
+// reusing its backing array. Item values reference the payload unless the reader owns
+// its output.
+func (r *fieldReader) items(items []message.Item, countName string) []message.Item {
+	count := int(r.uint8(countName))
+	items = items[:0]
+	for i := 0; i < count && r.err == nil; i++ {
+		key := message.ConfigKey(r.uint8("item key"))
+		length := r.uint8("item length")
+		value := r.bytes(int(length), "item value")
+		items = append(items, message.Item{Key: key, Length: length, Value: value})
+	}
+	return items
+}
+
+// resize returns s with length n, reusing its backing array when it is large enough.
+func resize[T any](s []T, n int) []T {
+	if cap(s) >= n {
+		return s[:n]
+	}
+	return make([]T, n)
+}
@@ -0,0 +1,195 @@
+package codec
+
+import (
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalInto_AllTypes(t *testing.T) {
+	for _, msg := range allMessages() {
+		data, err := Marshal(msg, 5, msg.MessageType(), message.TransportCRC16)
+		if err != nil {
+			t.Fatalf("Marshal %T failed: %v", msg, err)
+		}
+
+		want, err := Unmarshal(data, message.TransportCRC16)
+		if err != nil {
+			t.Fatalf("Unmarshal %T failed: %v", msg, err)
+		}
+
+		got := reflect.New(reflect.TypeOf(msg).Elem()).Interface().(message.Message)
+		header, err := UnmarshalInto(data, message.TransportCRC16, got)
+		if err != nil {
+			t.Fatalf("UnmarshalInto %T failed: %v", msg, err)
+		}
+		if header.PacketID != 5 || header.Type != msg.MessageType() {
+			t.Errorf("%T: unexpected header %+v", msg, header)
+		}
+		if !equalMessages(got, want) {
+			t.Errorf("%T: got %+v, want %+v", msg, got, want)
+		}
+	}
+}
+
+func TestDecoder_AllTypes(t *testing.T) {
+	d := NewDecoder(message.TransportCRC32)
+
+	for _, msg := range allMessages() {
+		data, err := Marshal(msg, 9, msg.MessageType(), message.TransportCRC32)
+		if err != nil {
+			t.Fatalf("Marshal %T failed: %v", msg, err)
+		}
+
+		envelope, err := d.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode %T failed: %v", msg, err)
+		}
+		if envelope.Header.PacketID != 9 || envelope.Header.Type != msg.MessageType() {
+			t.Errorf("%T: unexpected header %+v", msg, envelope.Header)
+		}
+		if len(envelope.Footer.Bytes) != 4 {
+			t.Errorf("%T: expected 4 footer bytes, got %d", msg, len(envelope.Footer.Bytes))
+		}
+		if !equalMessages(envelope.Payload, msg) {
+			t.Errorf("%T: got %+v, want %+v", msg, envelope.Payload, msg)
+		}
+	}
+}
+
+func TestDecoder_ReusesMessages(t *testing.T) {
+	d := NewDecoder(message.TransportCRC8)
+
+	long := &message.SensorData{SensorID: 1, Data: message.Data{Type: message.Quaternion, Values: []float32{1, 2, 3, 4}}}
+	short := &message.SensorData{SensorID: 2, Data: message.Data{Type: message.Accelerometer, Values: []float32{5}}}
+
+	for _, msg := range []*message.SensorData{long, short} {
+		data, err := Marshal(msg, 1, message.MsgTypeSensorData, message.TransportCRC8)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		envelope, err := d.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if envelope.Payload != message.Message(&d.data) {
+			t.Error("Decode did not reuse the SensorData message")
+		}
+		if !equalMessages(envelope.Payload, msg) {
+			t.Errorf("got %+v, want %+v", envelope.Payload, msg)
+		}
+	}
+}
+
+func TestUnmarshalInto_Errors(t *testing.T) {
+	hb := &message.SensorHeartbeat{SensorID: 1, Battery: 50, Status: message.Ok}
+	data, err := Marshal(hb, 1, message.MsgTypeHeartbeat, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	if _, err := UnmarshalInto(data, message.TransportCRC8, &message.Ack{}); !errors.Is(err, ErrInvalidMessageType) {
+		t.Errorf("Expected ErrInvalidMessageType for mismatched type, got %v", err)
+	}
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	if _, err := UnmarshalInto(corrupted, message.TransportCRC8, &message.SensorHeartbeat{}); !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("Expected ErrInvalidFooter, got %v", err)
+	}
+
+	if _, err := UnmarshalInto(data[:len(data)-3], message.TransportCRC8, &message.SensorHeartbeat{}); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("Expected ErrInsufficientData, got %v", err)
+	}
+
+	// A payload length that is too short for the declared value count.
+	short := []byte{'K', 'N', 1, 1, byte(message.MsgTypeSensorData), 7, 1, 0, 0, 0, 0, 1, 3}
+	if _, err := UnmarshalInto(short, message.TransportNone, &message.SensorData{}); !errors.Is(err, ErrDecodingFailed) {
+		t.Errorf("Expected ErrDecodingFailed, got %v", err)
+	}
+
+	d := NewDecoder(message.TransportNone)
+	unknown := []byte{'K', 'N', 1, 1, 0x7F, 0}
+	if _, err := d.Decode(unknown); !errors.Is(err, ErrUnknownMessageType) {
+		t.Errorf("Expected ErrUnknownMessageType, got %v", err)
+	}
+}
+
+func TestDecoder_ZeroAllocs(t *testing.T) {
+	msg := &message.SensorDataMulti{SensorID: 1, TimeStamp: 12345, Data: []message.Data{
+		{Type: message.Accelerometer, Values: []float32{0.1, 0.2, 9.8}},
+		{Type: message.Gyroscope, Values: []float32{0.01, 0.02, 0.03}},
+	}}
+	data, err := Marshal(msg, 1, message.MsgTypeSensorDataMulti, message.TransportCRC32)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	d := NewDecoder(message.TransportCRC32)
+	if _, err := d.Decode(data); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = d.Decode(data)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func BenchmarkUnmarshal_SensorData(b *testing.B) {
+	msg := &message.SensorData{SensorID: 1, TimeStamp: 12345, Data: message.Data{
+		Type: message.Accelerometer, Values: []float32{0.1, 0.2, 9.8},
+	}}
+	data, err := Marshal(msg, 1, message.MsgTypeSensorData, message.TransportCRC8)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Unmarshal(data, message.TransportCRC8); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalInto_SensorData(b *testing.B) {
+	msg := &message.SensorData{SensorID: 1, TimeStamp: 12345, Data: message.Data{
+		Type: message.Accelerometer, Values: []float32{0.1, 0.2, 9.8},
+	}}
+	data, err := Marshal(msg, 1, message.MsgTypeSensorData, message.TransportCRC8)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var decoded message.SensorData
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := UnmarshalInto(data, message.TransportCRC8, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder_SensorDataMulti(b *testing.B) {
+	msg := &message.SensorDataMulti{SensorID: 1, TimeStamp: 12345, Data: []message.Data{
+		{Type: message.Accelerometer, Values: []float32{0.1, 0.2, 9.8}},
+		{Type: message.Gyroscope, Values: []float32{0.01, 0.02, 0.03}},
+		{Type: message.Quaternion, Values: []float32{1, 0, 0, 0}},
+	}}
+	data, err := Marshal(msg, 1, message.MsgTypeSensorDataMulti, message.TransportCRC32)
+	if err != nil {
+		b.Fatal(err)
+	}
+	d := NewDecoder(message.TransportCRC32)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := d.Decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
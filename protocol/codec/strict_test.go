@@ -0,0 +1,197 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"testing"
+)
+
+// resizePayload marshals msg without a footer and changes its V1 payload by delta bytes,
+// appending zeros or cutting bytes off the end and updating the length field to match.
+func resizePayload(t *testing.T, msg message.Message, delta int) []byte {
+	t.Helper()
+	data, err := Marshal(msg, 1, msg.MessageType(), message.TransportNone)
+	if err != nil {
+		t.Fatalf("Marshal %T failed: %v", msg, err)
+	}
+	if delta < 0 {
+		data = data[:len(data)+delta]
+	} else {
+		data = append(data, make([]byte, delta)...)
+	}
+	data[5] = uint8(int(data[5]) + delta)
+	return data
+}
+
+// requireDecodeError asserts that err is a DecodeError at offset and field wrapping target.
+func requireDecodeError(t *testing.T, err error, offset int, field string, target error) {
+	t.Helper()
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected DecodeError, got %v", err)
+	}
+	if decodeErr.Offset != offset || decodeErr.Field != field {
+		t.Errorf("expected offset %d (%s), got offset %d (%s)", offset, field, decodeErr.Offset, decodeErr.Field)
+	}
+	if !errors.Is(err, target) {
+		t.Errorf("expected %v, got %v", target, err)
+	}
+}
+
+func TestUnmarshalStrict_AllTypes(t *testing.T) {
+	for _, msg := range allMessages() {
+		data, err := Marshal(msg, 3, msg.MessageType(), message.TransportCRC16)
+		if err != nil {
+			t.Fatalf("Marshal %T failed: %v", msg, err)
+		}
+
+		envelope, err := UnmarshalFrameStrict(data, message.TransportCRC16)
+		if err != nil {
+			t.Fatalf("UnmarshalFrameStrict %T failed: %v", msg, err)
+		}
+		if envelope.Header.PacketID != 3 || len(envelope.Footer.Bytes) != 2 {
+			t.Errorf("%T: unexpected envelope %+v", msg, envelope)
+		}
+		if !equalMessages(envelope.Payload, msg) {
+			t.Errorf("%T: got %+v, want %+v", msg, envelope.Payload, msg)
+		}
+	}
+}
+
+func TestUnmarshalStrict_CopiesBytes(t *testing.T) {
+	fragment := &message.Fragment{MessageID: 1, FragmentNum: 0, TotalFragments: 1, Data: []byte{1, 2, 3}}
+	data, err := Marshal(fragment, 1, message.MsgTypeFragment, message.TransportNone)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	msg, err := UnmarshalStrict(data, message.TransportNone)
+	if err != nil {
+		t.Fatalf("UnmarshalStrict failed: %v", err)
+	}
+	clear(data)
+	if got := msg.(*message.Fragment).Data; !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("fragment data changed with the packet: %v", got)
+	}
+}
+
+func TestUnmarshalStrict_TrailingPayload(t *testing.T) {
+	ack := &message.Ack{SensorID: 1, MessageID: 2, Status: message.AckOK}
+	data := resizePayload(t, ack, 2)
+
+	if _, err := Unmarshal(data, message.TransportNone); err != nil {
+		t.Fatalf("lenient Unmarshal should accept trailing payload bytes: %v", err)
+	}
+	_, err := UnmarshalStrict(data, message.TransportNone)
+	requireDecodeError(t, err, 10, "payload", ErrTrailingData)
+}
+
+func TestUnmarshalStrict_TrailingFooter(t *testing.T) {
+	ack := &message.Ack{SensorID: 1, MessageID: 2, Status: message.AckOK}
+	data, err := Marshal(ack, 1, message.MsgTypeAck, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	frameSize := len(data)
+	data = append(data, 0x4B)
+
+	if _, err := Unmarshal(data, message.TransportCRC8); err != nil {
+		t.Fatalf("lenient Unmarshal should accept bytes after the footer: %v", err)
+	}
+	_, err = UnmarshalStrict(data, message.TransportCRC8)
+	requireDecodeError(t, err, frameSize, "footer", ErrTrailingData)
+}
+
+func TestUnmarshalStrict_ShortPayload(t *testing.T) {
+	sensorData := &message.SensorData{
+		SensorID: 1,
+		Data:     message.Data{Type: message.Accelerometer, Values: []float32{1, 2, 3}},
+	}
+	data := resizePayload(t, sensorData, -4)
+
+	_, err := UnmarshalStrict(data, message.TransportNone)
+	requireDecodeError(t, err, 13, "sensor values", ErrInsufficientData)
+	if !errors.Is(err, ErrDecodingFailed) {
+		t.Errorf("expected ErrDecodingFailed, got %v", err)
+	}
+
+	_, err = UnmarshalStrict(data[:len(data)-1], message.TransportNone)
+	requireDecodeError(t, err, len(data)-1, "payload", ErrInsufficientData)
+}
+
+func TestUnmarshalStrict_OutOfRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    message.Message
+		offset int
+		field  string
+	}{
+		{"data type", &message.SensorData{SensorID: 1, Data: message.Data{Type: 0x20}}, 11, "data type"},
+		{"status", &message.SensorHeartbeat{SensorID: 1, Battery: 90, Status: 0x7F}, 12, "status"},
+		{"ack status", &message.Ack{SensorID: 1, MessageID: 2, Status: 0x33}, 9, "ack status"},
+		{"device type", &message.Registration{SensorID: 1, DeviceType: 0xEE}, 7, "device type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.msg, 1, tt.msg.MessageType(), message.TransportCRC8)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			if _, err := Unmarshal(data, message.TransportCRC8); err != nil {
+				t.Fatalf("lenient Unmarshal should accept undefined values: %v", err)
+			}
+			_, err = UnmarshalStrict(data, message.TransportCRC8)
+			requireDecodeError(t, err, tt.offset, tt.field, ErrValueOutOfRange)
+
+			_, err = NewStrictDecoder(message.TransportCRC8).Decode(data)
+			requireDecodeError(t, err, tt.offset, tt.field, ErrValueOutOfRange)
+		})
+	}
+}
+
+func TestUnmarshalStrict_Header(t *testing.T) {
+	ack := &message.Ack{SensorID: 1, MessageID: 2, Status: message.AckOK}
+	data, err := Marshal(ack, 1, message.MsgTypeAck, message.TransportNone)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	badMagic := bytes.Clone(data)
+	badMagic[1] = 'X'
+	_, err = UnmarshalStrict(badMagic, message.TransportNone)
+	requireDecodeError(t, err, 0, "magic", ErrInvalidMagicBytes)
+
+	badVersion := bytes.Clone(data)
+	badVersion[3] = 0x09
+	_, err = UnmarshalStrict(badVersion, message.TransportNone)
+	requireDecodeError(t, err, 3, "version", ErrUnsupportedVersion)
+
+	badType := bytes.Clone(data)
+	badType[4] = 0x7E
+	_, err = UnmarshalStrict(badType, message.TransportNone)
+	requireDecodeError(t, err, 4, "type", ErrUnknownMessageType)
+
+	_, err = UnmarshalStrict(data[:4], message.TransportNone)
+	requireDecodeError(t, err, 4, "header", ErrMessageTooShort)
+}
+
+func TestStrictDecoder_Footer(t *testing.T) {
+	ack := &message.Ack{SensorID: 1, MessageID: 2, Status: message.AckOK}
+	data, err := Marshal(ack, 1, message.MsgTypeAck, message.TransportCRC16)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	data[len(data)-1] ^= 0xFF
+
+	_, err = NewStrictDecoder(message.TransportCRC16).Decode(data)
+	requireDecodeError(t, err, len(data)-2, "footer", ErrInvalidFooter)
+
+	_, err = NewDecoder(message.TransportCRC16).Decode(data)
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) || !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("expected plain ErrInvalidFooter from the lenient decoder, got %v", err)
+	}
+}
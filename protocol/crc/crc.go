@@ -0,0 +1,216 @@
+// Package crc implements cyclic redundancy checks described by the Rocksoft model
+// parameters (width, poly, init, refin, refout, xorout) used by the CRC catalogue, so
+// the exact variant computed by a firmware CRC peripheral can be reproduced. Tables
+// process eight bytes per step; CRC-32/ISO-HDLC and CRC-32C use the hardware-accelerated
+// implementation of hash/crc32 where available.
+package crc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"sync"
+)
+
+// CheckInput is the catalogue check string whose checksum is recorded in Params.Check.
+const CheckInput = "123456789"
+
+// Params describes a CRC algorithm in the Rocksoft model.
+type Params struct {
+	Name   string // Catalogue name, e.g. "CRC-16/MODBUS"
+	Width  uint8  // Register width in bits (1 to 32)
+	Poly   uint32 // Generator polynomial in normal (non-reflected) form without the top bit
+	Init   uint32 // Initial register value
+	RefIn  bool   // Process input bytes least significant bit first
+	RefOut bool   // Reflect the final register before XorOut
+	XorOut uint32 // Value XORed into the final register
+	Check  uint32 // Checksum of CheckInput, verified by NewTable (0 to skip)
+}
+
+// Table computes the CRC described by its parameters. Lookup tables are built on first
+// use. A Table is safe for concurrent use.
+type Table struct {
+	params Params          // Algorithm parameters
+	once   sync.Once       // Guards building the lookup tables
+	slices *[8][256]uint32 // Slicing-by-8 tables
+	fast   *crc32.Table    // hash/crc32 table for accelerated 32-bit variants (nil otherwise)
+}
+
+// NewTable validates the parameters and creates a table for them. If Check is set, the
+// parameters must reproduce it for CheckInput.
+func NewTable(p Params) (*Table, error) {
+	if p.Width < 1 || p.Width > 32 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidWidth, p.Width)
+	}
+	if mask := p.mask(); p.Poly&^mask != 0 || p.Init&^mask != 0 || p.XorOut&^mask != 0 {
+		return nil, fmt.Errorf("%w: %d bits", ErrInvalidValue, p.Width)
+	}
+
+	t := &Table{params: p}
+	if p.Check != 0 {
+		if got := t.Checksum([]byte(CheckInput)); got != p.Check {
+			return nil, fmt.Errorf("%w: %s gives 0x%X, expected 0x%X", ErrCheckFailed, p.Name, got, p.Check)
+		}
+	}
+	return t, nil
+}
+
+// Params returns the algorithm parameters.
+func (t *Table) Params() Params {
+	return t.params
+}
+
+// Size returns the checksum size in bytes.
+func (t *Table) Size() int {
+	return (int(t.params.Width) + 7) / 8
+}
+
+// Checksum returns the CRC of data.
+func (t *Table) Checksum(data []byte) uint32 {
+	return t.finish(t.update(t.start(), data))
+}
+
+// AppendLittleEndian appends the CRC of data to dst, least significant byte first, using
+// Size bytes, and returns the extended buffer.
+func (t *Table) AppendLittleEndian(dst, data []byte) []byte {
+	sum := t.Checksum(data)
+	for i := 0; i < t.Size(); i++ {
+		dst = append(dst, byte(sum>>(8*i)))
+	}
+	return dst
+}
+
+// mask returns the bits of a register of the configured width.
+func (p Params) mask() uint32 {
+	return uint32(1<<p.Width - 1)
+}
+
+// shift is the distance the register of a non-reflected algorithm is moved left so its
+// top bit is bit 31.
+func (t *Table) shift() uint {
+	return 32 - uint(t.params.Width)
+}
+
+// start returns the initial working register. Reflected algorithms keep the register
+// reflected in the low bits; normal ones keep it aligned to the top of 32 bits.
+func (t *Table) start() uint32 {
+	if t.params.RefIn {
+		return reflect(t.params.Init, t.params.Width)
+	}
+	return t.params.Init << t.shift()
+}
+
+// finish converts a working register into the checksum.
+func (t *Table) finish(reg uint32) uint32 {
+	p := t.params
+	crc := reg
+	if p.RefIn {
+		if !p.RefOut {
+			crc = reflect(crc, p.Width)
+		}
+	} else {
+		crc >>= t.shift()
+		if p.RefOut {
+			crc = reflect(crc, p.Width)
+		}
+	}
+	return crc ^ p.XorOut
+}
+
+// update advances a working register over data.
+func (t *Table) update(reg uint32, data []byte) uint32 {
+	t.once.Do(t.build)
+
+	if t.fast != nil {
+		return ^crc32.Update(^reg, t.fast, data)
+	}
+
+	s := t.slices
+	if t.params.RefIn {
+		for len(data) >= 8 {
+			reg ^= binary.LittleEndian.Uint32(data)
+			reg = s[7][byte(reg)] ^ s[6][byte(reg>>8)] ^ s[5][byte(reg>>16)] ^ s[4][reg>>24] ^
+				s[3][data[4]] ^ s[2][data[5]] ^ s[1][data[6]] ^ s[0][data[7]]
+			data = data[8:]
+		}
+		for _, b := range data {
+			reg = reg>>8 ^ s[0][byte(reg)^b]
+		}
+		return reg
+	}
+
+	for len(data) >= 8 {
+		reg ^= binary.BigEndian.Uint32(data)
+		reg = s[7][reg>>24] ^ s[6][byte(reg>>16)] ^ s[5][byte(reg>>8)] ^ s[4][byte(reg)] ^
+			s[3][data[4]] ^ s[2][data[5]] ^ s[1][data[6]] ^ s[0][data[7]]
+		data = data[8:]
+	}
+	for _, b := range data {
+		reg = reg<<8 ^ s[0][byte(reg>>24)^b]
+	}
+	return reg
+}
+
+// build creates the lookup tables, or selects a hash/crc32 table for reflected 32-bit
+// polynomials that package accelerates.
+func (t *Table) build() {
+	p := t.params
+	if p.Width == 32 && p.RefIn {
+		switch p.Poly {
+		case 0x04C11DB7:
+			t.fast = crc32.IEEETable
+			return
+		case 0x1EDC6F41:
+			t.fast = crc32.MakeTable(crc32.Castagnoli)
+			return
+		}
+	}
+
+	s := new([8][256]uint32)
+	if p.RefIn {
+		poly := reflect(p.Poly, p.Width)
+		for i := range 256 {
+			reg := uint32(i)
+			for range 8 {
+				if reg&1 != 0 {
+					reg = reg>>1 ^ poly
+				} else {
+					reg >>= 1
+				}
+			}
+			s[0][i] = reg
+		}
+		for k := 1; k < 8; k++ {
+			for i := range 256 {
+				prev := s[k-1][i]
+				s[k][i] = prev>>8 ^ s[0][byte(prev)]
+			}
+		}
+	} else {
+		poly := p.Poly << t.shift()
+		for i := range 256 {
+			reg := uint32(i) << 24
+			for range 8 {
+				if reg&0x80000000 != 0 {
+					reg = reg<<1 ^ poly
+				} else {
+					reg <<= 1
+				}
+			}
+			s[0][i] = reg
+		}
+		for k := 1; k < 8; k++ {
+			for i := range 256 {
+				prev := s[k-1][i]
+				s[k][i] = prev<<8 ^ s[0][prev>>24]
+			}
+		}
+	}
+	t.slices = s
+}
+
+// reflect reverses the low width bits of v.
+func reflect(v uint32, width uint8) uint32 {
+	return bits.Reverse32(v) >> (32 - uint(width))
+}
@@ -0,0 +1,178 @@
+package crc
+
+import (
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+// reference computes a CRC bit by bit straight from the Rocksoft model.
+func reference(p Params, data []byte) uint32 {
+	mask := p.mask()
+	top := uint32(1) << (p.Width - 1)
+	reg := p.Init
+
+	for _, b := range data {
+		if p.RefIn {
+			b = byte(reflect(uint32(b), 8))
+		}
+		for i := 7; i >= 0; i-- {
+			bit := uint32(b>>i) & 1
+			feedback := (reg&top != 0) != (bit != 0)
+			reg = (reg << 1) & mask
+			if feedback {
+				reg ^= p.Poly
+			}
+		}
+	}
+
+	if p.RefOut {
+		reg = reflect(reg, p.Width)
+	}
+	return reg ^ p.XorOut
+}
+
+// unusual are catalogue algorithms exercising widths below 8 bits, widths that are not
+// a multiple of 8, and RefIn differing from RefOut.
+var unusual = []Params{
+	{Name: "CRC-5/USB", Width: 5, Poly: 0x05, Init: 0x1F, RefIn: true, RefOut: true, XorOut: 0x1F, Check: 0x19},
+	{Name: "CRC-12/3GPP", Width: 12, Poly: 0x80F, RefOut: true, Check: 0xDAF},
+	{Name: "CRC-24/OPENPGP", Width: 24, Poly: 0x864CFB, Init: 0xB704CE, Check: 0x21CF02},
+}
+
+func TestPresets_CheckValues(t *testing.T) {
+	for _, table := range Presets() {
+		p := table.Params()
+		if got := table.Checksum([]byte(CheckInput)); got != p.Check {
+			t.Errorf("%s: check = 0x%X, want 0x%X", p.Name, got, p.Check)
+		}
+		if _, err := NewTable(p); err != nil {
+			t.Errorf("%s: NewTable failed: %v", p.Name, err)
+		}
+		if found, ok := Lookup(p.Name); !ok || found != table {
+			t.Errorf("Lookup(%q) did not return the preset", p.Name)
+		}
+	}
+
+	if _, ok := Lookup("CRC-99/UNKNOWN"); ok {
+		t.Error("Lookup found an unknown name")
+	}
+}
+
+func TestTable_MatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 512)
+	rng.Read(data)
+
+	var tables []*Table
+	tables = append(tables, Presets()...)
+	for _, p := range unusual {
+		table, err := NewTable(p)
+		if err != nil {
+			t.Fatalf("%s: NewTable failed: %v", p.Name, err)
+		}
+		tables = append(tables, table)
+	}
+
+	for _, table := range tables {
+		p := table.Params()
+		for n := 0; n <= len(data); n += 1 + n/4 {
+			if got, want := table.Checksum(data[:n]), reference(p, data[:n]); got != want {
+				t.Fatalf("%s over %d bytes = 0x%X, want 0x%X", p.Name, n, got, want)
+			}
+		}
+	}
+}
+
+func TestTable_HashCRC32Compatible(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+
+	if got, want := CRC32ISOHDLC.Checksum(data), crc32.ChecksumIEEE(data); got != want {
+		t.Errorf("CRC-32/ISO-HDLC = 0x%08X, hash/crc32 IEEE = 0x%08X", got, want)
+	}
+	if got, want := CRC32C.Checksum(data), crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)); got != want {
+		t.Errorf("CRC-32C = 0x%08X, hash/crc32 Castagnoli = 0x%08X", got, want)
+	}
+}
+
+func TestNewTable_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+		want   error
+	}{
+		{"zero width", Params{Width: 0, Poly: 0x07}, ErrInvalidWidth},
+		{"too wide", Params{Width: 33, Poly: 0x07}, ErrInvalidWidth},
+		{"poly too wide", Params{Width: 8, Poly: 0x107}, ErrInvalidValue},
+		{"init too wide", Params{Width: 16, Poly: 0x1021, Init: 0x1FFFF}, ErrInvalidValue},
+		{"wrong check", Params{Name: "CRC-16/BAD", Width: 16, Poly: 0x1021, Check: 0x1234}, ErrCheckFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTable(tt.params); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestTable_AppendLittleEndian(t *testing.T) {
+	got := CRC16Modbus.AppendLittleEndian([]byte{0xAA}, []byte(CheckInput))
+	if len(got) != 3 || got[0] != 0xAA || got[1] != 0x37 || got[2] != 0x4B {
+		t.Errorf("AppendLittleEndian = %x, want aa374b", got)
+	}
+}
+
+func TestDigest_Streaming(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	data := make([]byte, 300)
+	rng.Read(data)
+
+	for _, table := range Presets() {
+		d := New(table)
+		for rest := data; len(rest) > 0; {
+			n := min(1+rng.Intn(20), len(rest))
+			d.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if got, want := d.Sum32(), table.Checksum(data); got != want {
+			t.Errorf("%s: streaming = 0x%X, one-shot = 0x%X", table.Params().Name, got, want)
+		}
+
+		d.Reset()
+		d.Write([]byte(CheckInput))
+		if got := d.Sum32(); got != table.Params().Check {
+			t.Errorf("%s: after Reset = 0x%X, want 0x%X", table.Params().Name, got, table.Params().Check)
+		}
+	}
+
+	sum := New(CRC16Modbus).Sum(nil)
+	if len(sum) != 2 {
+		t.Errorf("CRC-16 Sum appended %d bytes, want 2", len(sum))
+	}
+	d := New(CRC16Modbus)
+	d.Write([]byte(CheckInput))
+	if sum := d.Sum([]byte{0x01}); len(sum) != 3 || sum[1] != 0x4B || sum[2] != 0x37 {
+		t.Errorf("Sum = %x, want 014b37", sum)
+	}
+}
+
+func benchmarkTable(b *testing.B, table *Table, size int) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(3)).Read(data)
+
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		table.Checksum(data)
+	}
+}
+
+func BenchmarkCRC16Modbus_64(b *testing.B) { benchmarkTable(b, CRC16Modbus, 64) }
+
+func BenchmarkCRC32BZip2_4K(b *testing.B) { benchmarkTable(b, CRC32BZip2, 4096) }
+
+func BenchmarkCRC32ISOHDLC_4K(b *testing.B) { benchmarkTable(b, CRC32ISOHDLC, 4096) }
+
+func BenchmarkCRC32C_4K(b *testing.B) { benchmarkTable(b, CRC32C, 4096) }
@@ -0,0 +1,53 @@
+package crc
+
+import "hash"
+
+var _ hash.Hash32 = (*Digest)(nil)
+
+// Digest computes a CRC incrementally over data written in pieces. It implements
+// hash.Hash32; as with the standard library hashes, Sum appends the checksum in
+// big-endian byte order using Size bytes.
+type Digest struct {
+	table *Table // Algorithm and lookup tables
+	reg   uint32 // Working register
+}
+
+// New creates a streaming digest for the table's algorithm.
+func New(t *Table) *Digest {
+	return &Digest{table: t, reg: t.start()}
+}
+
+// Write adds data to the running checksum. It never returns an error.
+func (d *Digest) Write(data []byte) (int, error) {
+	d.reg = d.table.update(d.reg, data)
+	return len(data), nil
+}
+
+// Sum32 returns the checksum of the data written so far.
+func (d *Digest) Sum32() uint32 {
+	return d.table.finish(d.reg)
+}
+
+// Sum appends the checksum to b in big-endian byte order.
+func (d *Digest) Sum(b []byte) []byte {
+	sum := d.Sum32()
+	for i := d.Size() - 1; i >= 0; i-- {
+		b = append(b, byte(sum>>(8*i)))
+	}
+	return b
+}
+
+// Reset restarts the checksum.
+func (d *Digest) Reset() {
+	d.reg = d.table.start()
+}
+
+// Size returns the checksum size in bytes.
+func (d *Digest) Size() int {
+	return d.table.Size()
+}
+
+// BlockSize returns the preferred write size in bytes.
+func (d *Digest) BlockSize() int {
+	return 8
+}
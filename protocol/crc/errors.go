@@ -0,0 +1,10 @@
+package crc
+
+import "errors"
+
+// CRC engine error definitions.
+var (
+	ErrInvalidWidth = errors.New("invalid CRC width")              // Width outside 1 to 32 bits
+	ErrInvalidValue = errors.New("CRC parameter exceeds width")    // Poly, Init, or XorOut wider than the register
+	ErrCheckFailed  = errors.New("CRC check value does not match") // Parameters do not reproduce their Check value
+)
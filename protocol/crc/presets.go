@@ -0,0 +1,44 @@
+package crc
+
+// Named presets from the CRC catalogue. Each is verified against its check value by the
+// package tests.
+var (
+	CRC8SMBus = preset(Params{Name: "CRC-8/SMBUS", Width: 8, Poly: 0x07, Check: 0xF4})
+	CRC8Maxim = preset(Params{Name: "CRC-8/MAXIM-DOW", Width: 8, Poly: 0x31, RefIn: true, RefOut: true, Check: 0xA1})
+
+	CRC16CCITTFalse = preset(Params{Name: "CRC-16/CCITT-FALSE", Width: 16, Poly: 0x1021, Init: 0xFFFF, Check: 0x29B1})
+	CRC16XModem     = preset(Params{Name: "CRC-16/XMODEM", Width: 16, Poly: 0x1021, Check: 0x31C3})
+	CRC16Kermit     = preset(Params{Name: "CRC-16/KERMIT", Width: 16, Poly: 0x1021, RefIn: true, RefOut: true, Check: 0x2189})
+	CRC16Modbus     = preset(Params{Name: "CRC-16/MODBUS", Width: 16, Poly: 0x8005, Init: 0xFFFF, RefIn: true, RefOut: true, Check: 0x4B37})
+	CRC16ARC        = preset(Params{Name: "CRC-16/ARC", Width: 16, Poly: 0x8005, RefIn: true, RefOut: true, Check: 0xBB3D})
+
+	CRC32BZip2   = preset(Params{Name: "CRC-32/BZIP2", Width: 32, Poly: 0x04C11DB7, Init: 0xFFFFFFFF, XorOut: 0xFFFFFFFF, Check: 0xFC891918})
+	CRC32ISOHDLC = preset(Params{Name: "CRC-32/ISO-HDLC", Width: 32, Poly: 0x04C11DB7, Init: 0xFFFFFFFF, RefIn: true, RefOut: true, XorOut: 0xFFFFFFFF, Check: 0xCBF43926})
+	CRC32MPEG2   = preset(Params{Name: "CRC-32/MPEG-2", Width: 32, Poly: 0x04C11DB7, Init: 0xFFFFFFFF, Check: 0x0376E6E7})
+	CRC32C       = preset(Params{Name: "CRC-32C", Width: 32, Poly: 0x1EDC6F41, Init: 0xFFFFFFFF, RefIn: true, RefOut: true, XorOut: 0xFFFFFFFF, Check: 0xE3069283})
+)
+
+// Presets returns all named presets.
+func Presets() []*Table {
+	return []*Table{
+		CRC8SMBus, CRC8Maxim,
+		CRC16CCITTFalse, CRC16XModem, CRC16Kermit, CRC16Modbus, CRC16ARC,
+		CRC32BZip2, CRC32ISOHDLC, CRC32MPEG2, CRC32C,
+	}
+}
+
+// Lookup returns the preset with the given catalogue name.
+func Lookup(name string) (*Table, bool) {
+	for _, t := range Presets() {
+		if t.params.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// preset creates the table of a catalogue algorithm. The parameters are known to be
+// valid, so the check is left to the tests instead of running at startup.
+func preset(p Params) *Table {
+	return &Table{params: p}
+}
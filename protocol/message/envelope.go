@@ -0,0 +1,14 @@
+package message
+
+import "time"
+
+// Envelope carries a decoded message together with the packet metadata it arrived with.
+// Receivers use it to access the PacketID for acknowledgments, duplicate detection,
+// and loss accounting, which the bare Message does not expose.
+type Envelope struct {
+	Header     Header    // Decoded packet header (PacketID, version, type, length)
+	Payload    Message   // Decoded message payload
+	Footer     Footer    // Footer bytes as received (empty for TransportNone)
+	ReceivedAt time.Time // Time the packet was received (zero if not set by a transport)
+	Raw        []byte    // Complete raw packet bytes
+}
@@ -2,6 +2,7 @@ package message
 
 import (
 	"kinetica-protocol/internal/utils"
+	"kinetica-protocol/protocol/crc"
 )
 
 // TransportCRC defines the type of CRC validation used by different transport layers.
@@ -14,32 +15,81 @@ const (
 	TransportCRC32  TransportCRC = 0x03 // 32-bit CRC for high reliability (TCP)
 	TransportLength TransportCRC = 0x04 // Simple length validation (UDP)
 	TransportNone   TransportCRC = 0x05 // No validation required
+
+	TransportHMAC64  TransportCRC = 0x06 // HMAC-SHA256 truncated to 8 bytes, keyed per sensor
+	TransportHMAC128 TransportCRC = 0x07 // HMAC-SHA256 truncated to 16 bytes, keyed per sensor
+
+	// CRC variants for devices whose hardware CRC unit computes a different algorithm.
+	TransportCRC8Maxim    TransportCRC = 0x08 // CRC-8/MAXIM-DOW (1-Wire)
+	TransportCRC16Modbus  TransportCRC = 0x09 // CRC-16/MODBUS
+	TransportCRC16XModem  TransportCRC = 0x0A // CRC-16/XMODEM
+	TransportCRC16Kermit  TransportCRC = 0x0B // CRC-16/KERMIT (reflected CCITT)
+	TransportCRC32ISOHDLC TransportCRC = 0x0C // CRC-32/ISO-HDLC (Ethernet, zlib)
+	TransportCRC32C       TransportCRC = 0x0D // CRC-32C (Castagnoli)
+	TransportCRC32MPEG2   TransportCRC = 0x0E // CRC-32/MPEG-2 (STM32 CRC unit default)
 )
 
+// transportCRCs maps each checksum footer type to its CRC algorithm.
+var transportCRCs = map[TransportCRC]*crc.Table{
+	TransportCRC8:         crc.CRC8SMBus,
+	TransportCRC16:        crc.CRC16CCITTFalse,
+	TransportCRC32:        crc.CRC32BZip2,
+	TransportCRC8Maxim:    crc.CRC8Maxim,
+	TransportCRC16Modbus:  crc.CRC16Modbus,
+	TransportCRC16XModem:  crc.CRC16XModem,
+	TransportCRC16Kermit:  crc.CRC16Kermit,
+	TransportCRC32ISOHDLC: crc.CRC32ISOHDLC,
+	TransportCRC32C:       crc.CRC32C,
+	TransportCRC32MPEG2:   crc.CRC32MPEG2,
+}
+
+// MaxFooterSize is the largest footer size of any transport CRC type in bytes.
+const MaxFooterSize = 16
+
+// IsMAC reports whether the footer type is a keyed message authentication code
+// rather than a checksum.
+func (t TransportCRC) IsMAC() bool {
+	return t == TransportHMAC64 || t == TransportHMAC128
+}
+
+// CRC returns the CRC algorithm of a checksum footer type, or nil for footer types
+// that are not a CRC (length, HMAC, or none). The checksum is stored little-endian.
+func (t TransportCRC) CRC() *crc.Table {
+	return transportCRCs[t]
+}
+
 // Footer represents the protocol message footer containing validation data.
 type Footer struct {
 	Bytes []byte // CRC or validation bytes based on transport type
 }
 
 // NewFooter creates a new protocol footer with appropriate validation data
-// based on the specified transport CRC type.
+// based on the specified transport CRC type. HMAC footers need the keys of a
+// MACKeyring and are always empty here; see MACKeyring.AppendFooter.
 func NewFooter(transportType TransportCRC, data []byte) *Footer {
-	var value []byte
+	return &Footer{Bytes: AppendFooter(nil, transportType, data)}
+}
 
+// AppendFooter appends the footer of data for the specified transport CRC type to dst
+// and returns the extended buffer. Checksum footers never allocate when dst has room;
+// nothing is appended for HMAC footers, which MACKeyring.AppendFooter computes.
+// The length footer holds the frame length in one byte and is only defined for V1
+// frames, so nothing is appended for a V2 frame.
+func AppendFooter(dst []byte, transportType TransportCRC, data []byte) []byte {
 	switch transportType {
-	case TransportCRC8:
-		value = CalculateChecksum8(data)
-	case TransportCRC16:
-		value = CalculateChecksum16(data)
-	case TransportCRC32:
-		value = CalculateChecksum32(data)
 	case TransportLength:
-		value = CalculateLength(data)
-	case TransportNone:
-		value = nil
+		if len(data) > 3 && Version(data[3]) != V1 {
+			return dst
+		}
+		return append(dst, byte(len(data)))
+	case TransportHMAC64, TransportHMAC128:
+		return dst
 	}
 
-	return &Footer{Bytes: value}
+	if table := transportType.CRC(); table != nil {
+		return table.AppendLittleEndian(dst, data)
+	}
+	return dst
 }
 
 // CalculateChecksum8 computes an 8-bit CRC checksum for the given data.
@@ -63,23 +113,24 @@ func CalculateChecksum32(data []byte) []byte {
 }
 
 // CalculateLength creates a simple length validation footer.
-// Used by UDP and other datagram-based transport layers.
+// Used by UDP and other datagram-based transport layers. It is empty for V2 frames.
 func CalculateLength(data []byte) []byte {
-	return []byte{byte(len(data))}
+	return AppendFooter(nil, TransportLength, data)
 }
 
 // GetFooterSize returns the footer size in bytes for the specified transport CRC type.
 func GetFooterSize(transport TransportCRC) int {
 	switch transport {
-	case TransportCRC8:
-		return 1
-	case TransportCRC16:
-		return 2
-	case TransportCRC32:
-		return 4
 	case TransportLength:
 		return 1
-	default:
-		return 0
+	case TransportHMAC64:
+		return 8
+	case TransportHMAC128:
+		return 16
+	}
+
+	if table := transport.CRC(); table != nil {
+		return table.Size()
 	}
+	return 0
 }
@@ -12,7 +12,7 @@ var MagicBytes = [2]byte{'K', 'N'}
 // Protocol version constants.
 const (
 	V1 Version = 1 // Version 1 of the protocol
-	V2 Version = 2 // Version 2 of the protocol (future use)
+	V2 Version = 2 // Version 2 of the protocol (16-bit payload length)
 )
 
 // Message type constants defining all supported message types in the protocol.
@@ -28,10 +28,33 @@ const (
 	MsgTypeFragment        MsgType = 0x09 // Fragmented message part
 	MsgTypeRelayed         MsgType = 0x0A // Relayed message through hub
 	MsgTypeSensorDataMulti MsgType = 0x0B // Multiple sensor data in one message
+	MsgTypeSecure          MsgType = 0x0C // Authenticated and encrypted message
 )
 
-// HeaderSize is the fixed size of the protocol header in bytes.
-const HeaderSize = 6
+// Message type ranges. Types below MsgTypeVendorMin are reserved for the protocol, and
+// the vendor range is free for application-defined messages registered with the codec.
+const (
+	MsgTypeVendorMin MsgType = 0x40 // First type available for vendor messages
+	MsgTypeVendorMax MsgType = 0x7F // Last type available for vendor messages
+)
+
+// IsVendor reports whether the type lies in the range reserved for vendor messages.
+func (t MsgType) IsVendor() bool {
+	return t >= MsgTypeVendorMin && t <= MsgTypeVendorMax
+}
+
+// Header sizes for each protocol version.
+// HeaderSize is also the minimum number of bytes needed to identify a packet's version.
+const (
+	HeaderSize   = 6 // V1 header: magic, packet ID, version, type, 8-bit length
+	HeaderSizeV2 = 7 // V2 header: magic, packet ID, version, type, 16-bit length (little-endian)
+)
+
+// Maximum payload lengths representable by each header version.
+const (
+	MaxPayloadV1 = 0xFF   // Largest payload a V1 header can describe
+	MaxPayloadV2 = 0xFFFF // Largest payload a V2 header can describe
+)
 
 // Header represents the protocol message header containing packet metadata.
 type Header struct {
@@ -39,17 +62,41 @@ type Header struct {
 	PacketID uint8   // Unique packet identifier (0-255, wraps around)
 	Version  Version // Protocol version
 	Type     MsgType // Message type identifier
-	Length   uint8   // Payload length in bytes
+	Length   uint16  // Payload length in bytes (at most MaxPayloadV1 for V1 headers)
 }
 
 // NewHeader creates a new protocol header with the specified parameters.
-// It automatically sets the magic bytes and protocol version.
-func NewHeader(packetID uint8, msgType MsgType, payloadLength uint8) Header {
+// It automatically sets the magic bytes and selects the smallest protocol version
+// able to represent the payload length: V1 up to 255 bytes, V2 above.
+func NewHeader(packetID uint8, msgType MsgType, payloadLength uint16) Header {
+	version := V1
+	if payloadLength > MaxPayloadV1 {
+		version = V2
+	}
+
 	return Header{
 		Magic:    MagicBytes,
 		PacketID: packetID,
-		Version:  V1,
+		Version:  version,
 		Type:     msgType,
 		Length:   payloadLength,
 	}
 }
+
+// Size returns the encoded size of the header in bytes, or 0 for unsupported versions.
+func (h Header) Size() int {
+	return HeaderSizeFor(h.Version)
+}
+
+// HeaderSizeFor returns the encoded header size for the protocol version,
+// or 0 if the version is not supported.
+func HeaderSizeFor(version Version) int {
+	switch version {
+	case V1:
+		return HeaderSize
+	case V2:
+		return HeaderSizeV2
+	default:
+		return 0
+	}
+}
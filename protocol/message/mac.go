@@ -0,0 +1,112 @@
+package message
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"sync"
+)
+
+// MACKeyring holds the HMAC keys used by the TransportHMAC64 and TransportHMAC128 footer
+// types. A frame is keyed by its sender, the sensor ID reported by SensorIDOf (the relay
+// ID of relayed messages); frames whose sensor has no key of its own use the default key.
+// Frames without a sender, such as fragments, secure messages, and vendor messages,
+// cannot carry an HMAC footer. It is safe for concurrent use, and a nil keyring holds no
+// keys.
+type MACKeyring struct {
+	mu       sync.RWMutex     // Guards keys and fallback
+	keys     map[uint8][]byte // Keys by sensor ID
+	fallback []byte           // Key for sensors without their own key (nil for none)
+}
+
+// NewMACKeyring creates an empty keyring.
+func NewMACKeyring() *MACKeyring {
+	return &MACKeyring{keys: make(map[uint8][]byte)}
+}
+
+// Set installs the key of a sensor, replacing any previous key.
+func (k *MACKeyring) Set(sensorID uint8, key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[sensorID] = append([]byte(nil), key...)
+}
+
+// SetDefault installs the key used for sensors without their own key.
+// A nil key removes the default.
+func (k *MACKeyring) SetDefault(key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.fallback = append([]byte(nil), key...)
+	if key == nil {
+		k.fallback = nil
+	}
+}
+
+// Remove deletes the key of a sensor.
+func (k *MACKeyring) Remove(sensorID uint8) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, sensorID)
+}
+
+// Key returns the key of a sensor, falling back to the default key.
+func (k *MACKeyring) Key(sensorID uint8) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if key, ok := k.keys[sensorID]; ok {
+		return key, true
+	}
+	return k.fallback, k.fallback != nil
+}
+
+// AppendFooter appends the footer of data like the package-level AppendFooter, computing
+// HMAC footers with the key of the frame's sender. Nothing is appended for an HMAC footer
+// if no key is available.
+func (k *MACKeyring) AppendFooter(dst []byte, transportType TransportCRC, data []byte) []byte {
+	if transportType.IsMAC() {
+		return append(dst, k.MAC(data, GetFooterSize(transportType))...)
+	}
+	return AppendFooter(dst, transportType, data)
+}
+
+// MAC computes the HMAC-SHA256 of a frame, truncated to size bytes, with the key of the
+// frame's sender. It returns nil if the frame has no sender or no key is available.
+func (k *MACKeyring) MAC(data []byte, size int) []byte {
+	if k == nil {
+		return nil
+	}
+
+	sensorID, ok := macSensorID(data)
+	if !ok {
+		return nil
+	}
+	key, ok := k.Key(sensorID)
+	if !ok {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)[:size]
+}
+
+// macSensorID returns the sender of a frame. The payload of every message type for which
+// SensorIDOf reports a sender starts with it; other types have no sender.
+func macSensorID(data []byte) (uint8, bool) {
+	if len(data) < HeaderSize {
+		return 0, false
+	}
+
+	size := HeaderSizeFor(Version(data[3]))
+	if size == 0 || len(data) <= size {
+		return 0, false
+	}
+
+	switch MsgType(data[4]) {
+	case MsgTypeCommand, MsgTypeConfig, MsgTypeHeartbeat, MsgTypeSensorData, MsgTypeCustom,
+		MsgTypeTimeSync, MsgTypeAck, MsgTypeRegister, MsgTypeRelayed, MsgTypeSensorDataMulti:
+		return data[size], true
+	default:
+		return 0, false
+	}
+}
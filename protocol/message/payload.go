@@ -65,6 +65,14 @@ const (
 	AckBufferFull     AckStatus = 0x05 // Receive buffer overflow
 )
 
+// Command code constants for SensorCommand messages.
+const (
+	CommandDiscover uint8 = 0xD0 // Ask sensors to announce themselves with a Registration
+)
+
+// BroadcastSensorID addresses a SensorCommand to every sensor that receives it.
+const BroadcastSensorID uint8 = 0xFF
+
 // Device type constants identifying hardware capabilities.
 const (
 	DeviceType3Axis  DeviceType = 0x01 // 3-axis sensor (accelerometer only)
@@ -177,6 +185,41 @@ type SensorDataMulti struct {
 	Data      []Data // Array of sensor measurements
 }
 
+// SecureMessage carries another message encrypted and authenticated with a pre-shared key.
+// The key, session, and counter are sent in the clear so the receiver can select the key,
+// rebuild the nonce, and reject replays.
+type SecureMessage struct {
+	KeyID      uint8  // Identifier of the pre-shared key (typically the SensorID)
+	Session    uint64 // Random identifier of the sender session
+	Counter    uint32 // Message counter within the session
+	Ciphertext []byte // Encrypted inner message type and payload followed by the authentication tag
+}
+
+// Valid reports whether the data type is one of the defined sensor data types.
+func (t DataType) Valid() bool {
+	return t >= Accelerometer && t <= EulerAngles
+}
+
+// Valid reports whether the status is one of the defined device states.
+func (s Status) Valid() bool {
+	return s >= Ok && s <= Error
+}
+
+// Valid reports whether the acknowledgment status is one of the defined codes.
+func (s AckStatus) Valid() bool {
+	return s >= AckOK && s <= AckBufferFull
+}
+
+// Valid reports whether the device type is one of the defined hardware types.
+func (d DeviceType) Valid() bool {
+	switch d {
+	case DeviceType3Axis, DeviceType6Axis, DeviceType9Axis, DeviceTypeHub, DeviceTypeRelay, DeviceTypeCustom:
+		return true
+	default:
+		return false
+	}
+}
+
 // MessageType returns the message type identifier for SensorCommand.
 func (s *SensorCommand) MessageType() MsgType {
 	return MsgTypeCommand
@@ -231,3 +274,37 @@ func (r *RelayedMessage) MessageType() MsgType {
 func (d *SensorDataMulti) MessageType() MsgType {
 	return MsgTypeSensorDataMulti
 }
+
+// MessageType returns the message type identifier for SecureMessage.
+func (s *SecureMessage) MessageType() MsgType {
+	return MsgTypeSecure
+}
+
+// SensorIDOf returns the sensor identifier carried by the message.
+// For RelayedMessage the relay identifier is returned; Fragment and SecureMessage carry none.
+func SensorIDOf(msg Message) (uint8, bool) {
+	switch m := msg.(type) {
+	case *SensorCommand:
+		return m.SensorID, true
+	case *SensorConfig:
+		return m.SensorID, true
+	case *SensorHeartbeat:
+		return m.SensorID, true
+	case *SensorData:
+		return m.SensorID, true
+	case *CustomData:
+		return m.SensorID, true
+	case *TimeSync:
+		return m.SensorID, true
+	case *Ack:
+		return m.SensorID, true
+	case *Registration:
+		return m.SensorID, true
+	case *RelayedMessage:
+		return m.RelayID, true
+	case *SensorDataMulti:
+		return m.SensorID, true
+	default:
+		return 0, false
+	}
+}
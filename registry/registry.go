@@ -0,0 +1,296 @@
+// Package registry keeps track of the sensors a server talks to. It records sensor
+// identity from Registration messages, liveness and battery from SensorHeartbeat
+// messages, marks sensors offline after missed heartbeats, and reports lifecycle
+// events. Sensors are keyed by SensorID, so a sensor keeps its state when it
+// reconnects over a different connection or transport.
+package registry
+
+import (
+	"context"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/server"
+	"kinetica-protocol/transport"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry defaults.
+const (
+	DefaultHeartbeatInterval = 5 * time.Second // Expected interval between sensor heartbeats
+	DefaultMissedHeartbeats  = 3               // Heartbeats missed before a sensor is marked offline
+)
+
+// Config defines registry parameters. Zero values are replaced with defaults.
+type Config struct {
+	HeartbeatInterval time.Duration // Expected interval between sensor heartbeats
+	MissedHeartbeats  int           // Heartbeats missed before a sensor is marked offline
+	OnEvent           func(Event)   // Called for every lifecycle event, outside the registry lock
+}
+
+// withDefaults returns a copy of the configuration with zero values replaced by defaults.
+func (c Config) withDefaults() Config {
+	if c.HeartbeatInterval <= 0 {
+		c.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+	if c.MissedHeartbeats <= 0 {
+		c.MissedHeartbeats = DefaultMissedHeartbeats
+	}
+	return c
+}
+
+// Registry tracks sensor sessions. It is safe for concurrent use.
+type Registry struct {
+	config  Config            // Registry parameters
+	mu      sync.RWMutex      // Guards sensors
+	sensors map[uint8]*Sensor // Known sensors by SensorID
+	now     func() time.Time  // Clock used for timestamps and liveness checks
+}
+
+// New creates an empty registry.
+func New(config Config) *Registry {
+	return &Registry{
+		config:  config.withDefaults(),
+		sensors: make(map[uint8]*Sensor),
+		now:     time.Now,
+	}
+}
+
+// Observe records any message received from a sensor on conn.
+// Registration and SensorHeartbeat update identity and liveness; other messages
+// carrying a SensorID only refresh LastSeen of sensors that are already known.
+func (r *Registry) Observe(msg message.Message, conn transport.Connection) {
+	switch m := msg.(type) {
+	case *message.Registration:
+		r.Register(m, conn)
+	case *message.SensorHeartbeat:
+		r.Heartbeat(m, conn)
+	default:
+		if id, ok := message.SensorIDOf(msg); ok {
+			r.touch(id, conn)
+		}
+	}
+}
+
+// Register records a Registration received on conn and returns the updated sensor.
+func (r *Registry) Register(msg *message.Registration, conn transport.Connection) Sensor {
+	now := r.now()
+
+	r.mu.Lock()
+	s, events := r.seen(msg.SensorID, conn, now, true)
+	s.Registered = true
+	s.DeviceType = msg.DeviceType
+	s.Capabilities = msg.Capabilities
+	s.FWVersion = msg.FWVersion
+	s.RegisteredAt = now
+	events = append(events, EventRegistered)
+	snapshot := *s
+	r.mu.Unlock()
+
+	r.emit(events, snapshot, now)
+	return snapshot
+}
+
+// Heartbeat records a SensorHeartbeat received on conn and returns the updated sensor.
+func (r *Registry) Heartbeat(msg *message.SensorHeartbeat, conn transport.Connection) Sensor {
+	now := r.now()
+
+	r.mu.Lock()
+	s, events := r.seen(msg.SensorID, conn, now, true)
+	if s.Status != 0 && s.Status != msg.Status {
+		events = append(events, EventStatusChanged)
+	}
+	s.Battery = msg.Battery
+	s.Status = msg.Status
+	s.LastHeartbeat = now
+	snapshot := *s
+	r.mu.Unlock()
+
+	r.emit(events, snapshot, now)
+	return snapshot
+}
+
+// touch refreshes a known sensor that sent any other message.
+func (r *Registry) touch(sensorID uint8, conn transport.Connection) {
+	now := r.now()
+
+	r.mu.Lock()
+	if _, ok := r.sensors[sensorID]; !ok {
+		r.mu.Unlock()
+		return
+	}
+	s, events := r.seen(sensorID, conn, now, false)
+	snapshot := *s
+	r.mu.Unlock()
+
+	r.emit(events, snapshot, now)
+}
+
+// seen updates connection and liveness of a sensor, creating it if create is set,
+// and returns the lifecycle events caused by the update. The caller holds r.mu.
+func (r *Registry) seen(sensorID uint8, conn transport.Connection, now time.Time, create bool) (*Sensor, []EventType) {
+	var events []EventType
+
+	s, ok := r.sensors[sensorID]
+	if !ok && create {
+		s = &Sensor{SensorID: sensorID, State: StateOffline}
+		r.sensors[sensorID] = s
+	}
+
+	if conn != nil && s.Conn != conn {
+		if s.Conn != nil || !s.LastSeen.IsZero() {
+			events = append(events, EventReconnected)
+		}
+		s.Conn = conn
+	}
+
+	if s.State != StateOnline {
+		s.State = StateOnline
+		events = append(events, EventOnline)
+	}
+
+	s.LastSeen = now
+	return s, events
+}
+
+// Get returns the sensor with the given ID.
+func (r *Registry) Get(sensorID uint8) (Sensor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.sensors[sensorID]
+	if !ok {
+		return Sensor{}, false
+	}
+	return *s, true
+}
+
+// List returns all known sensors ordered by SensorID.
+func (r *Registry) List() []Sensor {
+	r.mu.RLock()
+	sensors := make([]Sensor, 0, len(r.sensors))
+	for _, s := range r.sensors {
+		sensors = append(sensors, *s)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(sensors, func(i, j int) bool { return sensors[i].SensorID < sensors[j].SensorID })
+	return sensors
+}
+
+// Online returns the sensors currently online ordered by SensorID.
+func (r *Registry) Online() []Sensor {
+	var online []Sensor
+	for _, s := range r.List() {
+		if s.State == StateOnline {
+			online = append(online, s)
+		}
+	}
+	return online
+}
+
+// Remove forgets a sensor and reports whether it was known.
+func (r *Registry) Remove(sensorID uint8) bool {
+	now := r.now()
+
+	r.mu.Lock()
+	s, ok := r.sensors[sensorID]
+	if ok {
+		delete(r.sensors, sensorID)
+	}
+	r.mu.Unlock()
+
+	if ok {
+		r.emit([]EventType{EventRemoved}, *s, now)
+	}
+	return ok
+}
+
+// Detach clears the connection of every sensor last heard on conn. Sensor state is kept,
+// so a sensor that reconnects, possibly over another transport, resumes its session.
+// Call it when a connection closes, for example from server.Config.OnDisconnect.
+func (r *Registry) Detach(conn transport.Connection) {
+	now := r.now()
+
+	r.mu.Lock()
+	var detached []Sensor
+	for _, s := range r.sensors {
+		if s.Conn == conn {
+			s.Conn = nil
+			detached = append(detached, *s)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, s := range detached {
+		r.emit([]EventType{EventDisconnected}, s, now)
+	}
+}
+
+// Sweep marks sensors that have sent neither a heartbeat nor a Registration within the
+// liveness window (HeartbeatInterval × MissedHeartbeats) as offline and returns them.
+// Other messages do not keep a sensor online.
+func (r *Registry) Sweep() []Sensor {
+	now := r.now()
+	window := r.config.HeartbeatInterval * time.Duration(r.config.MissedHeartbeats)
+
+	r.mu.Lock()
+	var expired []Sensor
+	for _, s := range r.sensors {
+		if s.State == StateOnline && now.Sub(lastAlive(s)) > window {
+			s.State = StateOffline
+			expired = append(expired, *s)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, s := range expired {
+		r.emit([]EventType{EventOffline}, s, now)
+	}
+	return expired
+}
+
+// lastAlive returns the time of the last heartbeat of a sensor, or of its last
+// Registration if that is more recent.
+func lastAlive(s *Sensor) time.Time {
+	if s.RegisteredAt.After(s.LastHeartbeat) {
+		return s.RegisteredAt
+	}
+	return s.LastHeartbeat
+}
+
+// Run calls Sweep every HeartbeatInterval until ctx is done.
+func (r *Registry) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Sweep()
+		}
+	}
+}
+
+// Middleware returns server middleware that records every received message in the
+// registry before passing it on to the next handler.
+func (r *Registry) Middleware() server.Middleware {
+	return func(next server.HandlerFunc) server.HandlerFunc {
+		return func(w server.ReplyWriter, req *server.Request) error {
+			r.Observe(req.Message(), req.Conn)
+			return next(w, req)
+		}
+	}
+}
+
+// emit reports events for a sensor to the OnEvent hook.
+func (r *Registry) emit(events []EventType, s Sensor, now time.Time) {
+	if r.config.OnEvent == nil {
+		return
+	}
+	for _, t := range events {
+		r.config.OnEvent(Event{Type: t, Sensor: s, Time: now})
+	}
+}
@@ -0,0 +1,236 @@
+package registry
+
+import (
+	"context"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/server"
+	"kinetica-protocol/transport"
+	"testing"
+	"time"
+)
+
+type stubConnection struct {
+	transport.Connection
+	name string
+}
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestRegistry(events *[]Event) (*Registry, *clock) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	r := New(Config{
+		HeartbeatInterval: time.Second,
+		MissedHeartbeats:  3,
+		OnEvent:           func(e Event) { *events = append(*events, e) },
+	})
+	r.now = c.now
+	return r, c
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func equalTypes(a, b []EventType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRegistry_Register(t *testing.T) {
+	var events []Event
+	r, _ := newTestRegistry(&events)
+	conn := &stubConnection{name: "tcp"}
+
+	r.Register(&message.Registration{
+		SensorID:     4,
+		DeviceType:   message.DeviceType9Axis,
+		Capabilities: 0x07,
+		FWVersion:    0x0102,
+	}, conn)
+
+	s, ok := r.Get(4)
+	if !ok {
+		t.Fatal("Expected sensor 4 to be registered")
+	}
+	if !s.Registered || s.DeviceType != message.DeviceType9Axis || s.Capabilities != 0x07 || s.FWVersion != 0x0102 {
+		t.Errorf("Unexpected sensor %+v", s)
+	}
+	if s.State != StateOnline || s.Conn != conn {
+		t.Errorf("Expected sensor online on the registering connection, got %+v", s)
+	}
+	if got := eventTypes(events); !equalTypes(got, []EventType{EventOnline, EventRegistered}) {
+		t.Errorf("Unexpected events %v", got)
+	}
+}
+
+func TestRegistry_Heartbeat(t *testing.T) {
+	var events []Event
+	r, _ := newTestRegistry(&events)
+	conn := &stubConnection{}
+
+	r.Heartbeat(&message.SensorHeartbeat{SensorID: 2, Battery: 80, Status: message.Ok}, conn)
+	r.Heartbeat(&message.SensorHeartbeat{SensorID: 2, Battery: 15, Status: message.LowBattery}, conn)
+
+	s, _ := r.Get(2)
+	if s.Registered {
+		t.Error("Expected heartbeat-only sensor to be unregistered")
+	}
+	if s.Battery != 15 || s.Status != message.LowBattery {
+		t.Errorf("Expected latest heartbeat values, got %+v", s)
+	}
+	if got := eventTypes(events); !equalTypes(got, []EventType{EventOnline, EventStatusChanged}) {
+		t.Errorf("Unexpected events %v", got)
+	}
+}
+
+func TestRegistry_Sweep(t *testing.T) {
+	var events []Event
+	r, c := newTestRegistry(&events)
+	conn := &stubConnection{}
+
+	r.Heartbeat(&message.SensorHeartbeat{SensorID: 1, Status: message.Ok}, conn)
+	r.Heartbeat(&message.SensorHeartbeat{SensorID: 2, Status: message.Ok}, conn)
+
+	c.advance(2 * time.Second)
+	r.Heartbeat(&message.SensorHeartbeat{SensorID: 2, Status: message.Ok}, conn)
+
+	c.advance(2 * time.Second)
+	expired := r.Sweep()
+	if len(expired) != 1 || expired[0].SensorID != 1 {
+		t.Fatalf("Expected only sensor 1 to expire, got %+v", expired)
+	}
+
+	if s, _ := r.Get(1); s.State != StateOffline {
+		t.Errorf("Expected sensor 1 offline, got %v", s.State)
+	}
+	if online := r.Online(); len(online) != 1 || online[0].SensorID != 2 {
+		t.Errorf("Expected sensor 2 online, got %+v", online)
+	}
+	if last := events[len(events)-1]; last.Type != EventOffline || last.Sensor.SensorID != 1 {
+		t.Errorf("Expected offline event for sensor 1, got %+v", last)
+	}
+
+	events = nil
+	r.Heartbeat(&message.SensorHeartbeat{SensorID: 1, Status: message.Ok}, conn)
+	if got := eventTypes(events); !equalTypes(got, []EventType{EventOnline}) {
+		t.Errorf("Expected sensor to come back online, got %v", got)
+	}
+}
+
+func TestRegistry_SweepIgnoresData(t *testing.T) {
+	var events []Event
+	r, c := newTestRegistry(&events)
+	conn := &stubConnection{}
+
+	r.Register(&message.Registration{SensorID: 1}, conn)
+	c.advance(2 * time.Second)
+	r.Observe(&message.SensorData{SensorID: 1}, conn)
+	if expired := r.Sweep(); len(expired) != 0 {
+		t.Fatalf("Expected the registration to keep the sensor online, got %+v", expired)
+	}
+
+	c.advance(2 * time.Second)
+	r.Observe(&message.SensorData{SensorID: 1}, conn)
+	if expired := r.Sweep(); len(expired) != 1 || expired[0].SensorID != 1 {
+		t.Errorf("Expected the sensor to expire without heartbeats, got %+v", expired)
+	}
+}
+
+func TestRegistry_ReconnectKeepsState(t *testing.T) {
+	var events []Event
+	r, _ := newTestRegistry(&events)
+	tcp := &stubConnection{name: "tcp"}
+	ble := &stubConnection{name: "ble"}
+
+	r.Register(&message.Registration{SensorID: 9, DeviceType: message.DeviceType6Axis, FWVersion: 3}, tcp)
+	r.Detach(tcp)
+
+	if s, _ := r.Get(9); s.Conn != nil {
+		t.Error("Expected connection to be cleared after Detach")
+	}
+
+	events = nil
+	r.Heartbeat(&message.SensorHeartbeat{SensorID: 9, Battery: 50, Status: message.Ok}, ble)
+
+	s, _ := r.Get(9)
+	if s.Conn != ble {
+		t.Error("Expected sensor to move to the new connection")
+	}
+	if !s.Registered || s.DeviceType != message.DeviceType6Axis || s.FWVersion != 3 {
+		t.Errorf("Expected registration data to survive reconnect, got %+v", s)
+	}
+	if got := eventTypes(events); !equalTypes(got, []EventType{EventReconnected}) {
+		t.Errorf("Unexpected events %v", got)
+	}
+}
+
+func TestRegistry_ObserveUnknownSensor(t *testing.T) {
+	var events []Event
+	r, _ := newTestRegistry(&events)
+
+	r.Observe(&message.SensorData{SensorID: 5}, &stubConnection{})
+
+	if _, ok := r.Get(5); ok {
+		t.Error("Expected data from an unknown sensor not to create an entry")
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events, got %v", eventTypes(events))
+	}
+}
+
+func TestRegistry_Middleware(t *testing.T) {
+	r := New(Config{})
+	router := server.NewRouter()
+	router.Use(r.Middleware())
+	router.OnRegistration(func(w server.ReplyWriter, req *server.Request, msg *message.Registration) error {
+		return nil
+	})
+
+	req := &server.Request{
+		Envelope: &message.Envelope{Payload: &message.Registration{SensorID: 12}},
+		Conn:     &stubConnection{},
+	}
+	if err := router.ServeMessage(nil, req); err != nil {
+		t.Fatalf("ServeMessage() error = %v", err)
+	}
+
+	if _, ok := r.Get(12); !ok {
+		t.Error("Expected middleware to record the registration")
+	}
+}
+
+func TestRegistry_Run(t *testing.T) {
+	r := New(Config{HeartbeatInterval: 5 * time.Millisecond, MissedHeartbeats: 1})
+	r.Heartbeat(&message.SensorHeartbeat{SensorID: 1, Status: message.Ok}, &stubConnection{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	go r.Run(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if s, _ := r.Get(1); s.State == StateOffline {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected Run to mark the sensor offline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
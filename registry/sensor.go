@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"time"
+)
+
+// State represents the liveness of a sensor.
+type State uint8
+
+// Sensor state constants.
+const (
+	StateOnline  State = 0x01 // Sensor was heard from within the liveness window
+	StateOffline State = 0x02 // Sensor missed too many heartbeats
+)
+
+// String returns a human-readable name of the state.
+func (s State) String() string {
+	switch s {
+	case StateOnline:
+		return "online"
+	case StateOffline:
+		return "offline"
+	default:
+		return "unknown"
+	}
+}
+
+// Sensor is a snapshot of everything the registry knows about one sensor.
+type Sensor struct {
+	SensorID      uint8                // Sensor identifier
+	Registered    bool                 // Registration received (sensors may be seen by heartbeat first)
+	DeviceType    message.DeviceType   // Hardware type from Registration
+	Capabilities  uint8                // Capability bitmask from Registration
+	FWVersion     uint16               // Firmware version from Registration
+	Battery       uint8                // Battery level from the last heartbeat
+	Status        message.Status       // Operational status from the last heartbeat
+	State         State                // Current liveness state
+	Conn          transport.Connection // Connection the sensor was last heard on (nil when detached)
+	RegisteredAt  time.Time            // Time of the last Registration
+	LastHeartbeat time.Time            // Time of the last heartbeat
+	LastSeen      time.Time            // Time of the last message of any type
+}
+
+// EventType identifies a sensor lifecycle event.
+type EventType uint8
+
+// Lifecycle event constants.
+const (
+	EventRegistered    EventType = 0x01 // Registration received
+	EventOnline        EventType = 0x02 // Sensor seen for the first time or after being offline
+	EventOffline       EventType = 0x03 // Sensor missed too many heartbeats
+	EventStatusChanged EventType = 0x04 // Heartbeat reported a different Status
+	EventReconnected   EventType = 0x05 // Sensor heard on a different connection than before
+	EventDisconnected  EventType = 0x06 // Connection the sensor was heard on was closed
+	EventRemoved       EventType = 0x07 // Sensor removed from the registry
+)
+
+// String returns a human-readable name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventRegistered:
+		return "registered"
+	case EventOnline:
+		return "online"
+	case EventOffline:
+		return "offline"
+	case EventStatusChanged:
+		return "status-changed"
+	case EventReconnected:
+		return "reconnected"
+	case EventDisconnected:
+		return "disconnected"
+	case EventRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change in a sensor's lifecycle.
+type Event struct {
+	Type   EventType // What happened
+	Sensor Sensor    // Sensor state after the change
+	Time   time.Time // When the change was recorded
+}
@@ -0,0 +1,12 @@
+package server
+
+import "errors"
+
+// Server error definitions for message dispatch and lifecycle.
+var (
+	ErrServerClosed      = errors.New("server closed")                // Serve called after Shutdown or Close
+	ErrNoHandler         = errors.New("no handler for message type")  // Router has no handler for the received type
+	ErrUnexpectedMessage = errors.New("unexpected message payload")   // Payload does not match the registered handler type
+	ErrNoSensorID        = errors.New("message carries no sensor ID") // Ack requested for a message without a sensor ID
+	ErrHandlerPanic      = errors.New("handler panicked")             // Handler panic recovered by the Recover middleware
+)
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+)
+
+// Request carries a received message together with its packet metadata and the
+// connection it arrived on.
+type Request struct {
+	Envelope *message.Envelope    // Received message with header, footer, and raw bytes
+	Conn     transport.Connection // Connection the message arrived on
+	ctx      context.Context      // Canceled when the connection ends or the server stops
+}
+
+// Message returns the decoded message payload. It is nil if the connection passed on
+// a packet whose type it could not decode.
+func (r *Request) Message() message.Message {
+	return r.Envelope.Payload
+}
+
+// MessageType returns the type of the decoded message, or the type from the packet
+// header when the message could not be decoded.
+func (r *Request) MessageType() message.MsgType {
+	if r.Envelope.Payload != nil {
+		return r.Envelope.Payload.MessageType()
+	}
+	return r.Envelope.Header.Type
+}
+
+// PacketID returns the PacketID of the received packet.
+func (r *Request) PacketID() uint8 {
+	return r.Envelope.Header.PacketID
+}
+
+// Context returns the request context. It is canceled when the connection is closed
+// or the server is forcibly stopped.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// ReplyWriter sends responses on the connection a request arrived on.
+type ReplyWriter interface {
+	// Reply sends a message back to the peer.
+	Reply(msg message.Message, msgType message.MsgType) error
+
+	// Ack acknowledges the request packet with the given status, using the
+	// request's PacketID as the MessageID and its sensor ID.
+	Ack(status message.AckStatus) error
+}
+
+// Handler processes a received message.
+type Handler interface {
+	ServeMessage(w ReplyWriter, r *Request) error
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface.
+type HandlerFunc func(w ReplyWriter, r *Request) error
+
+// ServeMessage calls f(w, r).
+func (f HandlerFunc) ServeMessage(w ReplyWriter, r *Request) error {
+	return f(w, r)
+}
+
+// Middleware wraps a handler to add behavior around message processing.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// replyWriter implements ReplyWriter on top of the request connection.
+type replyWriter struct {
+	req *Request // Request being answered
+}
+
+// Reply sends a message on the request connection, honoring the request context.
+func (w *replyWriter) Reply(msg message.Message, msgType message.MsgType) error {
+	return w.req.Conn.SendContext(w.req.Context(), msg, msgType)
+}
+
+// Ack sends an Ack for the request packet.
+func (w *replyWriter) Ack(status message.AckStatus) error {
+	sensorID, ok := message.SensorIDOf(w.req.Message())
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNoSensorID, w.req.Message())
+	}
+
+	return w.Reply(&message.Ack{
+		SensorID:  sensorID,
+		MessageID: uint16(w.req.PacketID()),
+		Status:    status,
+	}, message.MsgTypeAck)
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Recover returns middleware that converts a handler panic into an error wrapping
+// ErrHandlerPanic, so one faulty handler does not take down the connection.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w ReplyWriter, r *Request) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("%w: %v", ErrHandlerPanic, p)
+				}
+			}()
+			return next(w, r)
+		}
+	}
+}
+
+// Logger returns middleware that logs every dispatched message with its type, PacketID,
+// processing time, and handler error. A nil logger uses the standard logger.
+func Logger(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w ReplyWriter, r *Request) error {
+			start := time.Now()
+			err := next(w, r)
+			logger.Printf("type=0x%02x packet=%d duration=%v err=%v",
+				uint8(r.MessageType()), r.PacketID(), time.Since(start), err)
+			return err
+		}
+	}
+}
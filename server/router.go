@@ -0,0 +1,137 @@
+package server
+
+import (
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"sync"
+)
+
+// Router dispatches messages to handlers registered per message type.
+// Middleware registered with Use wraps every dispatched handler, including the fallback.
+type Router struct {
+	mu         sync.RWMutex                    // Guards handlers, fallback, and middleware
+	handlers   map[message.MsgType]HandlerFunc // Handlers by message type
+	fallback   HandlerFunc                     // Handler for types without a registered handler
+	middleware []Middleware                    // Middleware applied in registration order
+}
+
+// NewRouter creates an empty router. Messages without a handler are reported as ErrNoHandler.
+func NewRouter() *Router {
+	return &Router{
+		handlers: make(map[message.MsgType]HandlerFunc),
+	}
+}
+
+// Use appends middleware. The first registered middleware is the outermost.
+func (rt *Router) Use(mw ...Middleware) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.middleware = append(rt.middleware, mw...)
+}
+
+// Handle registers a handler for the message type, replacing any previous one.
+func (rt *Router) Handle(msgType message.MsgType, h HandlerFunc) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.handlers[msgType] = h
+}
+
+// HandleDefault registers a handler for message types without a specific handler.
+func (rt *Router) HandleDefault(h HandlerFunc) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.fallback = h
+}
+
+// ServeMessage dispatches the request to the handler for the message type in its packet
+// header through the middleware chain.
+func (rt *Router) ServeMessage(w ReplyWriter, r *Request) error {
+	msgType := r.MessageType()
+
+	rt.mu.RLock()
+	h, ok := rt.handlers[msgType]
+	if !ok {
+		h = rt.fallback
+	}
+	middleware := rt.middleware
+	rt.mu.RUnlock()
+
+	if h == nil {
+		h = func(ReplyWriter, *Request) error {
+			return fmt.Errorf("%w: 0x%02x", ErrNoHandler, uint8(msgType))
+		}
+	}
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+
+	return h(w, r)
+}
+
+// OnSensorCommand registers a handler for SensorCommand messages.
+func (rt *Router) OnSensorCommand(h func(w ReplyWriter, r *Request, msg *message.SensorCommand) error) {
+	rt.Handle(message.MsgTypeCommand, typed(h))
+}
+
+// OnSensorConfig registers a handler for SensorConfig messages.
+func (rt *Router) OnSensorConfig(h func(w ReplyWriter, r *Request, msg *message.SensorConfig) error) {
+	rt.Handle(message.MsgTypeConfig, typed(h))
+}
+
+// OnHeartbeat registers a handler for SensorHeartbeat messages.
+func (rt *Router) OnHeartbeat(h func(w ReplyWriter, r *Request, msg *message.SensorHeartbeat) error) {
+	rt.Handle(message.MsgTypeHeartbeat, typed(h))
+}
+
+// OnSensorData registers a handler for SensorData messages.
+func (rt *Router) OnSensorData(h func(w ReplyWriter, r *Request, msg *message.SensorData) error) {
+	rt.Handle(message.MsgTypeSensorData, typed(h))
+}
+
+// OnCustomData registers a handler for CustomData messages.
+func (rt *Router) OnCustomData(h func(w ReplyWriter, r *Request, msg *message.CustomData) error) {
+	rt.Handle(message.MsgTypeCustom, typed(h))
+}
+
+// OnTimeSync registers a handler for TimeSync messages.
+func (rt *Router) OnTimeSync(h func(w ReplyWriter, r *Request, msg *message.TimeSync) error) {
+	rt.Handle(message.MsgTypeTimeSync, typed(h))
+}
+
+// OnAck registers a handler for Ack messages.
+func (rt *Router) OnAck(h func(w ReplyWriter, r *Request, msg *message.Ack) error) {
+	rt.Handle(message.MsgTypeAck, typed(h))
+}
+
+// OnRegistration registers a handler for Registration messages.
+func (rt *Router) OnRegistration(h func(w ReplyWriter, r *Request, msg *message.Registration) error) {
+	rt.Handle(message.MsgTypeRegister, typed(h))
+}
+
+// OnFragment registers a handler for raw Fragment messages. Connections wrapped with
+// the fragment package deliver reassembled messages instead.
+func (rt *Router) OnFragment(h func(w ReplyWriter, r *Request, msg *message.Fragment) error) {
+	rt.Handle(message.MsgTypeFragment, typed(h))
+}
+
+// OnRelayedMessage registers a handler for RelayedMessage messages.
+func (rt *Router) OnRelayedMessage(h func(w ReplyWriter, r *Request, msg *message.RelayedMessage) error) {
+	rt.Handle(message.MsgTypeRelayed, typed(h))
+}
+
+// OnSensorDataMulti registers a handler for SensorDataMulti messages.
+func (rt *Router) OnSensorDataMulti(h func(w ReplyWriter, r *Request, msg *message.SensorDataMulti) error) {
+	rt.Handle(message.MsgTypeSensorDataMulti, typed(h))
+}
+
+// typed adapts a handler for a concrete message type to HandlerFunc.
+func typed[T message.Message](h func(w ReplyWriter, r *Request, msg T) error) HandlerFunc {
+	return func(w ReplyWriter, r *Request) error {
+		msg, ok := r.Message().(T)
+		if !ok {
+			return fmt.Errorf("%w: %T", ErrUnexpectedMessage, r.Message())
+		}
+		return h(w, r, msg)
+	}
+}
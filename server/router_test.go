@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"kinetica-protocol/protocol/message"
+	"log"
+	"testing"
+)
+
+type recordingWriter struct {
+	replies []message.Message
+	acks    []message.AckStatus
+}
+
+func (w *recordingWriter) Reply(msg message.Message, msgType message.MsgType) error {
+	w.replies = append(w.replies, msg)
+	return nil
+}
+
+func (w *recordingWriter) Ack(status message.AckStatus) error {
+	w.acks = append(w.acks, status)
+	return nil
+}
+
+func newRequest(msg message.Message, packetID uint8) *Request {
+	return &Request{Envelope: &message.Envelope{
+		Header:  message.NewHeader(packetID, msg.MessageType(), 0),
+		Payload: msg,
+	}}
+}
+
+func TestRouter_TypedHandler(t *testing.T) {
+	router := NewRouter()
+
+	var got *message.Registration
+	router.OnRegistration(func(w ReplyWriter, r *Request, msg *message.Registration) error {
+		got = msg
+		return w.Ack(message.AckOK)
+	})
+
+	w := &recordingWriter{}
+	if err := router.ServeMessage(w, newRequest(&message.Registration{SensorID: 7}, 1)); err != nil {
+		t.Fatalf("ServeMessage() error = %v", err)
+	}
+
+	if got == nil || got.SensorID != 7 {
+		t.Errorf("Expected registration from sensor 7, got %+v", got)
+	}
+	if len(w.acks) != 1 || w.acks[0] != message.AckOK {
+		t.Errorf("Expected one AckOK, got %v", w.acks)
+	}
+}
+
+func TestRouter_NoHandler(t *testing.T) {
+	router := NewRouter()
+
+	err := router.ServeMessage(&recordingWriter{}, newRequest(&message.SensorHeartbeat{SensorID: 1}, 1))
+	if !errors.Is(err, ErrNoHandler) {
+		t.Errorf("Expected ErrNoHandler, got %v", err)
+	}
+}
+
+func TestRouter_UndecodedMessage(t *testing.T) {
+	router := NewRouter()
+	router.Use(Recover(), Logger(log.New(io.Discard, "", 0)))
+
+	r := &Request{Envelope: &message.Envelope{Header: message.NewHeader(1, message.MsgTypeVendorMax, 0)}}
+	if err := router.ServeMessage(&recordingWriter{}, r); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("Expected ErrNoHandler for a packet without payload, got %v", err)
+	}
+}
+
+func TestRouter_Default(t *testing.T) {
+	router := NewRouter()
+
+	called := false
+	router.HandleDefault(func(w ReplyWriter, r *Request) error {
+		called = true
+		return nil
+	})
+
+	if err := router.ServeMessage(&recordingWriter{}, newRequest(&message.TimeSync{SensorID: 1}, 1)); err != nil {
+		t.Fatalf("ServeMessage() error = %v", err)
+	}
+	if !called {
+		t.Error("Expected default handler to be called")
+	}
+}
+
+func TestRouter_MiddlewareOrder(t *testing.T) {
+	router := NewRouter()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(w ReplyWriter, r *Request) error {
+				order = append(order, name+">")
+				err := next(w, r)
+				order = append(order, "<"+name)
+				return err
+			}
+		}
+	}
+	router.Use(trace("a"), trace("b"))
+	router.OnHeartbeat(func(w ReplyWriter, r *Request, msg *message.SensorHeartbeat) error {
+		order = append(order, "handler")
+		return nil
+	})
+
+	if err := router.ServeMessage(&recordingWriter{}, newRequest(&message.SensorHeartbeat{SensorID: 1}, 1)); err != nil {
+		t.Fatalf("ServeMessage() error = %v", err)
+	}
+
+	expected := []string{"a>", "b>", "handler", "<b", "<a"}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	router := NewRouter()
+	router.Use(Recover())
+	router.OnSensorData(func(w ReplyWriter, r *Request, msg *message.SensorData) error {
+		panic("boom")
+	})
+
+	err := router.ServeMessage(&recordingWriter{}, newRequest(&message.SensorData{SensorID: 1}, 1))
+	if !errors.Is(err, ErrHandlerPanic) {
+		t.Errorf("Expected ErrHandlerPanic, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+// Package server provides a message routing framework for Kinetica protocol servers.
+// A Server accepts connections from any transport.Transport, serves each connection in
+// its own goroutine, and dispatches every received message to a Handler, typically a
+// Router with typed handlers and middleware. Handlers answer through a ReplyWriter.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kinetica-protocol/transport"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxReceiveErrors is the number of consecutive receive errors after which
+// a connection is dropped when Config.MaxReceiveErrors is zero.
+const DefaultMaxReceiveErrors = 8
+
+// Config defines optional server hooks and limits.
+type Config struct {
+	Wrap             func(transport.Connection) transport.Connection        // Wraps each accepted connection (e.g. fragment or reliable layers)
+	OnConnect        func(conn transport.Connection)                        // Called when a connection starts being served
+	OnDisconnect     func(conn transport.Connection, err error)             // Called when a connection ends, with the error that ended it
+	OnError          func(conn transport.Connection, r *Request, err error) // Called with handler errors and non-fatal receive errors (r is nil)
+	MaxReceiveErrors int                                                    // Consecutive receive errors before a connection is dropped (read timeouts excluded)
+}
+
+// withDefaults returns a copy of the configuration with zero values replaced by defaults.
+func (c Config) withDefaults() Config {
+	if c.MaxReceiveErrors <= 0 {
+		c.MaxReceiveErrors = DefaultMaxReceiveErrors
+	}
+	return c
+}
+
+// serverConn tracks a connection being served.
+type serverConn struct {
+	conn      transport.Connection // Connection as seen by handlers
+	active    atomic.Bool          // Set while a handler is processing a message
+	closeOnce sync.Once            // Ensures the connection is closed once
+}
+
+// close closes the connection once.
+func (c *serverConn) close() {
+	c.closeOnce.Do(func() { _ = c.conn.Close() })
+}
+
+// Server accepts connections from a transport and dispatches their messages to a handler.
+type Server struct {
+	transport transport.Transport      // Transport providing incoming connections
+	handler   Handler                  // Handler invoked for every received message
+	config    Config                   // Server hooks and limits
+	ctx       context.Context          // Parent context of all request contexts
+	cancel    context.CancelFunc       // Cancels ctx on forced stop
+	mu        sync.Mutex               // Guards conns and the shutdown transition
+	conns     map[*serverConn]struct{} // Connections being served
+	wg        sync.WaitGroup           // Tracks connection goroutines
+	shutdown  atomic.Bool              // Set once Shutdown or Close is called
+}
+
+// NewServer creates a server for the transport that dispatches messages to handler.
+func NewServer(t transport.Transport, handler Handler, config Config) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		transport: t,
+		handler:   handler,
+		config:    config.withDefaults(),
+		ctx:       ctx,
+		cancel:    cancel,
+		conns:     make(map[*serverConn]struct{}),
+	}
+}
+
+// Serve listens on the transport and serves every accepted connection in its own goroutine.
+// It returns once the transport stops delivering connections and all connections have
+// ended; after Shutdown or Close the returned error is ErrServerClosed.
+func (s *Server) Serve() error {
+	if s.shutdown.Load() {
+		return ErrServerClosed
+	}
+
+	conns, err := s.transport.Listen()
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	for conn := range conns {
+		if s.config.Wrap != nil {
+			conn = s.config.Wrap(conn)
+		}
+
+		c := &serverConn{conn: conn}
+		if !s.track(c) {
+			c.close()
+			continue
+		}
+		go s.serveConn(c)
+	}
+
+	s.wg.Wait()
+
+	if s.shutdown.Load() {
+		return ErrServerClosed
+	}
+	return nil
+}
+
+// Shutdown stops accepting connections, closes idle connections, and waits for busy
+// ones to finish their current message before closing them and the transport.
+// If ctx ends first, remaining connections are closed forcibly and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown.Store(true)
+	s.mu.Unlock()
+
+	s.closeConns(false)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return s.transport.Close()
+	case <-ctx.Done():
+		_ = s.Close()
+		return ctx.Err()
+	}
+}
+
+// Close immediately closes the transport and all connections and cancels request contexts.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.shutdown.Store(true)
+	s.mu.Unlock()
+
+	s.cancel()
+	err := s.transport.Close()
+	s.closeConns(true)
+	return err
+}
+
+// track registers a connection unless the server is shutting down.
+func (s *Server) track(c *serverConn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown.Load() {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// untrack removes a connection from the served set.
+func (s *Server) untrack(c *serverConn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+}
+
+// activate marks a connection busy unless the server is shutting down. It holds the
+// shutdown lock, so closeConns never closes a connection that has just become busy.
+func (s *Server) activate(c *serverConn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown.Load() {
+		return false
+	}
+	c.active.Store(true)
+	return true
+}
+
+// closeConns closes idle connections, or all connections if force is set.
+func (s *Server) closeConns(force bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		if force || !c.active.Load() {
+			c.close()
+		}
+	}
+}
+
+// serveConn runs the receive loop of a connection and reports its lifecycle.
+func (s *Server) serveConn(c *serverConn) {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	if s.config.OnConnect != nil {
+		s.config.OnConnect(c.conn)
+	}
+
+	err := s.receiveLoop(ctx, c)
+
+	s.untrack(c)
+	c.close()
+
+	if s.config.OnDisconnect != nil {
+		s.config.OnDisconnect(c.conn, err)
+	}
+}
+
+// receiveLoop receives messages and dispatches them until the connection fails,
+// too many consecutive receive errors occur, or the server shuts down. Read timeouts
+// only mean the peer is idle and do not count as failures.
+func (s *Server) receiveLoop(ctx context.Context, c *serverConn) error {
+	failures := 0
+
+	for {
+		envelope, err := c.conn.ReceiveEnvelope()
+		if err != nil {
+			if s.shutdown.Load() {
+				return ErrServerClosed
+			}
+			if errors.Is(err, transport.ErrConnectionClosed) || errors.Is(err, transport.ErrContextCanceled) {
+				return err
+			}
+
+			s.reportError(c.conn, nil, err)
+			if errors.Is(err, transport.ErrReadTimeout) {
+				continue
+			}
+			if failures++; failures >= s.config.MaxReceiveErrors {
+				return err
+			}
+			continue
+		}
+		failures = 0
+
+		if !s.activate(c) {
+			return ErrServerClosed
+		}
+		req := &Request{Envelope: envelope, Conn: c.conn, ctx: ctx}
+		if err := s.handler.ServeMessage(&replyWriter{req: req}, req); err != nil {
+			s.reportError(c.conn, req, err)
+		}
+		c.active.Store(false)
+
+		if s.shutdown.Load() {
+			return ErrServerClosed
+		}
+	}
+}
+
+// reportError passes an error to the OnError hook if one is configured.
+func (s *Server) reportError(conn transport.Connection, r *Request, err error) {
+	if s.config.OnError != nil {
+		s.config.OnError(conn, r, err)
+	}
+}
@@ -0,0 +1,389 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockConnection struct {
+	inbox     chan message.Message
+	frames    chan []byte
+	errs      chan error
+	mu        sync.Mutex
+	sent      []message.Message
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newMockConnection() *mockConnection {
+	return &mockConnection{
+		inbox:  make(chan message.Message, 16),
+		frames: make(chan []byte, 16),
+		errs:   make(chan error, 16),
+		closed: make(chan struct{}),
+	}
+}
+
+func (m *mockConnection) Send(msg message.Message, msgType message.MsgType) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *mockConnection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	return m.Send(msg, msgType)
+}
+
+func (m *mockConnection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return m.Send(msg, msgType)
+}
+
+func (m *mockConnection) Receive() (message.Message, error) {
+	envelope, err := m.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+func (m *mockConnection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	return m.Receive()
+}
+
+func (m *mockConnection) ReceiveEnvelope() (*message.Envelope, error) {
+	select {
+	case msg := <-m.inbox:
+		return &message.Envelope{Header: message.NewHeader(5, msg.MessageType(), 0), Payload: msg}, nil
+	case frame := <-m.frames:
+		envelope, err := codec.UnmarshalFrame(frame, message.TransportNone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", transport.ErrReceiveFailed, err)
+		}
+		return envelope, nil
+	case err := <-m.errs:
+		return nil, err
+	case <-m.closed:
+		return nil, transport.ErrConnectionClosed
+	}
+}
+
+func (m *mockConnection) State() transport.ConnectionState {
+	return transport.StateConnected
+}
+
+func (m *mockConnection) Close() error {
+	m.closeOnce.Do(func() { close(m.closed) })
+	return nil
+}
+
+func (m *mockConnection) sentMessages() []message.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]message.Message(nil), m.sent...)
+}
+
+type mockTransport struct {
+	conns     chan transport.Connection
+	closeOnce sync.Once
+}
+
+func newMockTransport() *mockTransport {
+	return &mockTransport{conns: make(chan transport.Connection, 4)}
+}
+
+func (m *mockTransport) Connection() (transport.Connection, error) {
+	return nil, transport.ErrUnrealizedMethod
+}
+
+func (m *mockTransport) Listen() (<-chan transport.Connection, error) {
+	return m.conns, nil
+}
+
+func (m *mockTransport) Close() error {
+	m.closeOnce.Do(func() { close(m.conns) })
+	return nil
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServer_DispatchAndReply(t *testing.T) {
+	tr := newMockTransport()
+	router := NewRouter()
+	router.OnRegistration(func(w ReplyWriter, r *Request, msg *message.Registration) error {
+		return w.Ack(message.AckOK)
+	})
+
+	srv := NewServer(tr, router, Config{})
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve() }()
+
+	conn := newMockConnection()
+	tr.conns <- conn
+	conn.inbox <- &message.Registration{SensorID: 3}
+
+	waitFor(t, func() bool { return len(conn.sentMessages()) == 1 })
+
+	ack, ok := conn.sentMessages()[0].(*message.Ack)
+	if !ok {
+		t.Fatalf("Expected Ack reply, got %T", conn.sentMessages()[0])
+	}
+	if ack.SensorID != 3 || ack.MessageID != 5 || ack.Status != message.AckOK {
+		t.Errorf("Unexpected Ack %+v", ack)
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+}
+
+func TestServer_ShutdownWaitsForHandler(t *testing.T) {
+	tr := newMockTransport()
+	router := NewRouter()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router.OnSensorData(func(w ReplyWriter, r *Request, msg *message.SensorData) error {
+		close(started)
+		<-release
+		return w.Reply(&message.TimeSync{SensorID: msg.SensorID}, message.MsgTypeTimeSync)
+	})
+
+	var disconnects sync.WaitGroup
+	disconnects.Add(2)
+	srv := NewServer(tr, router, Config{
+		OnDisconnect: func(conn transport.Connection, err error) { disconnects.Done() },
+	})
+	go srv.Serve()
+
+	busy := newMockConnection()
+	idle := newMockConnection()
+	tr.conns <- busy
+	tr.conns <- idle
+	busy.inbox <- &message.SensorData{SensorID: 1}
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- srv.Shutdown(context.Background()) }()
+
+	select {
+	case <-idle.closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected idle connection to be closed")
+	}
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned while a handler was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	disconnects.Wait()
+
+	if len(busy.sentMessages()) != 1 {
+		t.Errorf("Expected in-flight reply to be sent, got %d", len(busy.sentMessages()))
+	}
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	tr := newMockTransport()
+	router := NewRouter()
+
+	started := make(chan struct{})
+	router.OnSensorData(func(w ReplyWriter, r *Request, msg *message.SensorData) error {
+		close(started)
+		<-r.Context().Done()
+		return r.Context().Err()
+	})
+
+	srv := NewServer(tr, router, Config{})
+	go srv.Serve()
+
+	conn := newMockConnection()
+	tr.conns <- conn
+	conn.inbox <- &message.SensorData{SensorID: 1}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestServer_HandlerError(t *testing.T) {
+	tr := newMockTransport()
+	router := NewRouter()
+
+	errs := make(chan error, 1)
+	srv := NewServer(tr, router, Config{
+		OnError: func(conn transport.Connection, r *Request, err error) { errs <- err },
+	})
+	go srv.Serve()
+	defer srv.Close()
+
+	conn := newMockConnection()
+	tr.conns <- conn
+	conn.inbox <- &message.SensorHeartbeat{SensorID: 1}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrNoHandler) {
+			t.Errorf("Expected ErrNoHandler, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnError to be called")
+	}
+}
+
+// vendorMessage is an application-defined message the server has no codec for.
+type vendorMessage struct{}
+
+func (*vendorMessage) MessageType() message.MsgType {
+	return message.MsgTypeVendorMax
+}
+
+func TestServer_UnknownMessageType(t *testing.T) {
+	tr := newMockTransport()
+	router := NewRouter()
+	router.Use(Recover())
+	registered := make(chan uint8, 1)
+	router.OnRegistration(func(w ReplyWriter, r *Request, msg *message.Registration) error {
+		registered <- msg.SensorID
+		return nil
+	})
+
+	errs := make(chan error, 1)
+	srv := NewServer(tr, router, Config{
+		OnError: func(conn transport.Connection, r *Request, err error) { errs <- err },
+	})
+	go srv.Serve()
+	defer srv.Close()
+
+	// A valid frame of a type that is not registered on the receiving side.
+	if err := codec.Register(message.MsgTypeVendorMax, codec.TypeCodec{
+		New:    func() message.Message { return &vendorMessage{} },
+		Append: func(dst []byte, msg message.Message) ([]byte, error) { return append(dst, 0x01), nil },
+		Decode: func(payload []byte, msg message.Message) error { return nil },
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	unknown, err := codec.Marshal(&vendorMessage{}, 1, message.MsgTypeVendorMax, message.TransportNone)
+	codec.Unregister(message.MsgTypeVendorMax)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	registration, err := codec.Marshal(&message.Registration{SensorID: 3}, 2, message.MsgTypeRegister, message.TransportNone)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	conn := newMockConnection()
+	tr.conns <- conn
+	conn.frames <- unknown
+	conn.frames <- registration
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, codec.ErrUnknownMessageType) {
+			t.Errorf("Expected ErrUnknownMessageType, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnError to be called for the unknown frame")
+	}
+	select {
+	case id := <-registered:
+		if id != 3 {
+			t.Errorf("Expected registration from sensor 3, got %d", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the connection to keep serving after the unknown frame")
+	}
+}
+
+func TestServer_ReceiveErrorBudget(t *testing.T) {
+	tr := newMockTransport()
+	router := NewRouter()
+
+	disconnected := make(chan error, 1)
+	srv := NewServer(tr, router, Config{
+		MaxReceiveErrors: 2,
+		OnDisconnect:     func(conn transport.Connection, err error) { disconnected <- err },
+	})
+	go srv.Serve()
+	defer srv.Close()
+
+	conn := newMockConnection()
+	for i := 0; i < 5; i++ {
+		conn.errs <- transport.ErrReadTimeout
+	}
+	tr.conns <- conn
+
+	waitFor(t, func() bool { return len(conn.errs) == 0 })
+	select {
+	case err := <-disconnected:
+		t.Fatalf("Expected read timeouts not to drop the connection, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	conn.errs <- transport.ErrReceiveFailed
+	conn.errs <- transport.ErrReceiveFailed
+	select {
+	case err := <-disconnected:
+		if !errors.Is(err, transport.ErrReceiveFailed) {
+			t.Errorf("Expected ErrReceiveFailed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the connection to be dropped after MaxReceiveErrors")
+	}
+}
+
+func TestServer_Wrap(t *testing.T) {
+	tr := newMockTransport()
+	router := NewRouter()
+
+	wrapped := make(chan transport.Connection, 1)
+	srv := NewServer(tr, router, Config{
+		Wrap: func(conn transport.Connection) transport.Connection {
+			wrapped <- conn
+			return conn
+		},
+	})
+	go srv.Serve()
+	defer srv.Close()
+
+	conn := newMockConnection()
+	tr.conns <- conn
+
+	select {
+	case got := <-wrapped:
+		if got != conn {
+			t.Error("Expected accepted connection to be wrapped")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Wrap to be called")
+	}
+}
@@ -10,7 +10,7 @@ import (
 
 // BLE transport constants for protocol configuration.
 const (
-	TransportCRC   = message.TransportCRC8 // 8-bit CRC for low-overhead BLE communication
+	TransportCRC   = message.TransportCRC8 // Default 8-bit CRC for low-overhead BLE communication
 	MaxMessageSize = 255                   // Maximum message size in bytes for BLE MTU constraints
 )
 
@@ -4,6 +4,7 @@
 package ble
 
 import (
+	"kinetica-protocol/protocol/message"
 	"time"
 	"tinygo.org/x/bluetooth"
 )
@@ -24,4 +25,16 @@ type Config struct {
 	// Timing configuration
 	ScanTimeout time.Duration // Maximum time to scan for target device
 	ReadTimeout time.Duration // Timeout for receiving data from device
+
+	// Frame validation
+	CRC     message.TransportCRC // Footer type matching the device firmware (0 = TransportCRC)
+	MACKeys *message.MACKeyring  // Keys of HMAC footers, required when CRC is TransportHMAC64 or TransportHMAC128
+}
+
+// transportCRC returns the configured footer type, or TransportCRC if none is set.
+func (c Config) transportCRC() message.TransportCRC {
+	if c.CRC == 0 {
+		return TransportCRC
+	}
+	return c.CRC
 }
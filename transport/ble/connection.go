@@ -1,7 +1,6 @@
 package ble
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -14,19 +13,37 @@ import (
 	"tinygo.org/x/bluetooth"
 )
 
+// errReadTimeout is returned by bleReader when no notification arrives within the read timeout.
+var errReadTimeout = errors.New("read timeout")
+
 // bleReader adapts BLE notification channel to io.Reader interface for bufio compatibility.
-// It manages fragmented BLE packets and provides timeout-based reading.
+// It manages fragmented BLE packets and provides timeout-based, cancelable reading.
 type bleReader struct {
-	rxBuffer    chan []byte   // Channel receiving BLE notification data
-	current     []byte        // Current data buffer being read
-	pos         int           // Current position in the data buffer
-	readTimeout time.Duration // Timeout for waiting for new data
+	rxBuffer    chan []byte                     // Channel receiving BLE notification data
+	current     []byte                          // Current data buffer being read
+	pos         int                             // Current position in the data buffer
+	readTimeout time.Duration                   // Timeout for waiting for new data
+	ctx         context.Context                 // Connection lifetime context (nil for none)
+	call        atomic.Pointer[context.Context] // Context of the receive in progress (nil for none)
 }
 
 // Read implements io.Reader interface for BLE notification data.
-// It handles packet fragmentation and applies read timeouts.
+// It handles packet fragmentation, applies read timeouts, and returns the context
+// error when the connection or the current receive is canceled.
 func (r *bleReader) Read(p []byte) (n int, err error) {
 	if r.pos >= len(r.current) {
+		var call context.Context
+		if stored := r.call.Load(); stored != nil {
+			call = *stored
+		}
+
+		var timeout <-chan time.Time
+		if r.readTimeout > 0 {
+			timer := time.NewTimer(r.readTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
 		select {
 		case data, ok := <-r.rxBuffer:
 			if !ok {
@@ -34,8 +51,12 @@ func (r *bleReader) Read(p []byte) (n int, err error) {
 			}
 			r.current = data
 			r.pos = 0
-		case <-time.After(r.readTimeout):
-			return 0, errors.New("read timeout")
+		case <-timeout:
+			return 0, errReadTimeout
+		case <-done(r.ctx):
+			return 0, r.ctx.Err()
+		case <-done(call):
+			return 0, call.Err()
 		}
 	}
 
@@ -44,17 +65,32 @@ func (r *bleReader) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// done returns the context's Done channel, or nil (blocking forever) for a nil context.
+func done(ctx context.Context) <-chan struct{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Done()
+}
+
 // Connection represents an active BLE connection with GATT characteristics.
 // It manages protocol message transmission over BLE write/notify characteristics.
 type Connection struct {
-	ctx         context.Context                    // Context for connection lifecycle
-	device      *bluetooth.Device                  // Connected BLE device
-	writeChar   bluetooth.DeviceCharacteristic     // Characteristic for sending data
-	notifyChar  bluetooth.DeviceCharacteristic     // Characteristic for receiving notifications
-	reader      *bufio.Reader                      // Buffered reader for protocol messages
-	readTimeout time.Duration                      // Timeout for read operations
-	packetID    atomic.Uint32                      // Atomic counter for unique packet IDs
-	rxBuffer    chan []byte                        // Buffer for incoming notification data
+	ctx         context.Context                // Context for connection lifecycle
+	device      *bluetooth.Device              // Connected BLE device
+	writeChar   bluetooth.DeviceCharacteristic // Characteristic for sending data
+	notifyChar  bluetooth.DeviceCharacteristic // Characteristic for receiving notifications
+	reader      *bleReader                     // Cancelable reader over notification data
+	frames      *codec.FrameReader             // Resynchronizing frame reader for protocol messages
+	crcType     message.TransportCRC           // Footer type used to encode and validate frames
+	macKeys     *message.MACKeyring            // Keys of HMAC footers (nil for none)
+	readTimeout time.Duration                  // Timeout for read operations
+	packetID    atomic.Uint32                  // Atomic counter for unique packet IDs
+	rxBuffer    chan []byte                    // Buffer for incoming notification data
+	stats       transport.Counters             // Traffic and error counters
+	observers   transport.CodecObservers       // Observers of encode and decode operations
+	state       transport.StateTracker         // Connection state updated by I/O outcomes
+	stopWatch   func() bool                    // Stops watching ctx for cancellation
 }
 
 // NewConnection creates a new BLE connection with the specified device and configuration.
@@ -65,21 +101,30 @@ func NewConnection(ctx context.Context, device *bluetooth.Device, config Config)
 	bleReader := &bleReader{
 		rxBuffer:    rxBuffer,
 		readTimeout: config.ReadTimeout,
+		ctx:         ctx,
 	}
 
 	conn := &Connection{
 		ctx:         ctx,
 		device:      device,
-		reader:      bufio.NewReader(bleReader),
+		reader:      bleReader,
+		frames:      codec.NewFrameReader(bleReader, config.transportCRC(), MaxMessageSize),
+		crcType:     config.transportCRC(),
+		macKeys:     config.MACKeys,
 		readTimeout: config.ReadTimeout,
 		packetID:    atomic.Uint32{},
 		rxBuffer:    rxBuffer,
 	}
+	conn.frames.SetMACKeyring(config.MACKeys)
 
 	if err := conn.setupCharacteristics(config, rxBuffer); err != nil {
 		return nil, fmt.Errorf("failed to setup characteristics: %v", err)
 	}
 
+	conn.stopWatch = context.AfterFunc(ctx, func() {
+		conn.state.Set(transport.StateDisconnected, ctx.Err())
+	})
+
 	return conn, nil
 }
 
@@ -128,13 +173,33 @@ func (c *Connection) setupCharacteristics(config Config, rxBuffer chan []byte) e
 }
 
 // Send encodes and transmits a protocol message over the BLE write characteristic.
-// It validates message size against BLE MTU constraints and handles fragmentation if needed.
+// A new PacketID is taken from the connection's counter.
 func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	return c.send(context.Background(), msg, msgType, c.getNextPacketID())
+}
+
+// SendContext encodes and transmits a protocol message like Send, failing if ctx is
+// already done. Writes without response complete immediately and are not interrupted.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return c.send(ctx, msg, msgType, c.getNextPacketID())
+}
+
+// SendPacket encodes and transmits a protocol message over the BLE write characteristic
+// using the given PacketID.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	return c.send(context.Background(), msg, msgType, packetID)
+}
+
+// send encodes and writes a message with the given PacketID.
+// It validates message size against BLE MTU constraints.
+func (c *Connection) send(ctx context.Context, msg message.Message, msgType message.MsgType, packetID uint8) error {
 	if msg == nil {
 		return fmt.Errorf("%w: message is nil", transport.ErrInvalidMessageSize)
 	}
 
-	binaryMsg, err := codec.Marshal(msg, c.getNextPacketID(), msgType, TransportCRC)
+	start := time.Now()
+	binaryMsg, err := codec.MarshalKeyed(msg, packetID, msgType, c.crcType, c.macKeys)
+	c.observers.Notify(transport.CodecEncode, start, err)
 	if err != nil {
 		return fmt.Errorf("%w: failed to marshal message: %w", transport.ErrSendFailed, err)
 	}
@@ -143,77 +208,142 @@ func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
 		return fmt.Errorf("%w: message size %d exceeds maximum %d bytes", transport.ErrMsgLarge, len(binaryMsg), MaxMessageSize)
 	}
 
-	select {
-	case <-c.ctx.Done():
-		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, c.ctx.Err())
-	default:
+	if err := c.contextErr(ctx); err != nil {
+		return err
 	}
 
 	_, err = c.writeChar.WriteWithoutResponse(binaryMsg)
 	if err != nil {
-		return fmt.Errorf("%w: failed to write: %w", transport.ErrSendFailed, err)
+		err = fmt.Errorf("%w: failed to write: %w", transport.ErrSendFailed, err)
+		c.state.Set(transport.StateDisconnected, err)
+		return err
 	}
 
+	c.stats.AddSent(len(binaryMsg))
 	return nil
 }
 
-// Receive reads and decodes a protocol message from BLE notifications.
-// It handles packet fragmentation and validates the complete message.
+// Receive reads and decodes a protocol message, discarding the packet metadata.
 func (c *Connection) Receive() (message.Message, error) {
-	select {
-	case <-c.ctx.Done():
-		return nil, fmt.Errorf("%w: %w", transport.ErrContextCanceled, c.ctx.Err())
-	default:
+	envelope, err := c.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
 	}
+	return envelope.Payload, nil
+}
 
-	headerBuf := make([]byte, message.HeaderSize)
-	n, err := io.ReadFull(c.reader, headerBuf)
+// ReceiveContext reads and decodes a protocol message like Receive, returning early
+// when ctx is canceled or its deadline passes. The read timeout still applies.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	envelope, err := c.receive(ctx)
 	if err != nil {
-		if err == io.EOF {
-			return nil, fmt.Errorf("%w: connection closed by peer", transport.ErrConnectionClosed)
-		}
-		return nil, fmt.Errorf("%w: failed to read header (%d/%d bytes): %w", transport.ErrReceiveFailed, n, message.HeaderSize, err)
+		return nil, err
 	}
+	return envelope.Payload, nil
+}
 
-	payloadLength := headerBuf[5]
-	if int(payloadLength) > MaxMessageSize {
-		return nil, fmt.Errorf("%w: payload length %d exceeds maximum %d", transport.ErrMsgLarge, payloadLength, MaxMessageSize)
-	}
+// ReceiveEnvelope reads and decodes a protocol message from BLE notifications.
+// The returned envelope includes the packet header, footer, raw bytes, and receive time.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	return c.receive(context.Background())
+}
 
-	footerSize := message.GetFooterSize(TransportCRC)
-	remainingSize := int(payloadLength) + footerSize
-	remainingBuf := make([]byte, remainingSize)
+// receive reads the next frame from notifications and decodes it into an envelope.
+// A blocked read returns as soon as the connection context or ctx is done.
+func (c *Connection) receive(ctx context.Context) (*message.Envelope, error) {
+	if err := c.contextErr(ctx); err != nil {
+		return nil, err
+	}
 
-	n, err = io.ReadFull(c.reader, remainingBuf)
+	c.reader.call.Store(&ctx)
+	frame, err := c.frames.ReadFrame()
 	if err != nil {
-		if err == io.EOF {
-			return nil, fmt.Errorf("%w: connection closed while reading payload", transport.ErrConnectionClosed)
+		if ctxErr := c.contextErr(ctx); ctxErr != nil {
+			return nil, ctxErr
 		}
-		return nil, fmt.Errorf("%w: failed to read payload (%d/%d bytes): %w", transport.ErrReceiveFailed, n, remainingSize, err)
+		return nil, c.readError(err)
 	}
 
-	fullMessage := append(headerBuf, remainingBuf...)
-	msg, err := codec.Unmarshal(fullMessage, TransportCRC)
+	start := time.Now()
+	envelope, err := codec.UnmarshalFrameKeyed(frame, c.crcType, c.macKeys)
+	c.observers.Notify(transport.CodecDecode, start, err)
 	if err != nil {
+		c.stats.AddDecodeError()
 		return nil, fmt.Errorf("%w: failed to unmarshal message: %w", transport.ErrReceiveFailed, err)
 	}
 
-	return msg, nil
+	c.stats.AddReceived(len(frame), envelope.Header.PacketID)
+	envelope.ReceivedAt = time.Now()
+	return envelope, nil
+}
+
+// contextErr returns ErrContextCanceled if either the call context or the
+// connection context is done, and nil otherwise.
+func (c *Connection) contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+	if err := c.ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+	return nil
+}
+
+// readError maps a frame reader error to the matching transport error.
+func (c *Connection) readError(err error) error {
+	if err == io.EOF {
+		err = fmt.Errorf("%w: connection closed by peer", transport.ErrConnectionClosed)
+		c.state.Set(transport.StateDisconnected, err)
+		return err
+	}
+	if errors.Is(err, errReadTimeout) {
+		c.stats.AddTimeout()
+		return fmt.Errorf("%w: %w", transport.ErrReadTimeout, err)
+	}
+	return fmt.Errorf("%w: failed to read frame: %w", transport.ErrReceiveFailed, err)
 }
 
-// State returns the current connection state based on context status.
+// SkippedBytes returns the number of received bytes discarded while resynchronizing
+// the stream after corrupt or misaligned input.
+func (c *Connection) SkippedBytes() uint64 {
+	return c.frames.Skipped()
+}
+
+// Stats returns a snapshot of the connection's traffic and error counters.
+func (c *Connection) Stats() transport.Stats {
+	return c.stats.Snapshot(c.frames.CRCErrors(), c.frames.Skipped())
+}
+
+// State returns the connection state. It is StateDisconnected once the context ends,
+// the connection is closed, the notification stream ends, or a write fails.
 func (c *Connection) State() transport.ConnectionState {
-	select {
-	case <-c.ctx.Done():
+	if c.ctx.Err() != nil {
 		return transport.StateDisconnected
-	default:
-		return transport.StateConnected
 	}
+	return c.state.State()
+}
+
+// Subscribe registers fn to be called on every state transition and returns a function
+// that removes it.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	return c.state.Subscribe(fn)
+}
+
+// ObserveCodec registers fn to be called after every encode and decode and returns a
+// function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	return c.observers.Observe(fn)
 }
 
 // Close gracefully terminates the BLE connection and cleans up resources.
 // It disables notifications, closes buffers, and disconnects from the device.
 func (c *Connection) Close() error {
+	c.state.Set(transport.StateClosing, nil)
+	defer c.state.Set(transport.StateDisconnected, nil)
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+
 	if c.notifyChar.UUID() != (bluetooth.UUID{}) {
 		_ = c.notifyChar.EnableNotifications(nil)
 	}
@@ -2,6 +2,8 @@ package ble
 
 import (
 	"context"
+	"errors"
+	"kinetica-protocol/protocol/codec"
 	"kinetica-protocol/protocol/message"
 	"kinetica-protocol/transport"
 	"testing"
@@ -205,3 +207,40 @@ func TestConstants(t *testing.T) {
 		t.Errorf("Expected MaxMessageSize to be 255, got %d", MaxMessageSize)
 	}
 }
+
+func TestBleReader_ReadCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := &bleReader{
+		rxBuffer:    make(chan []byte),
+		readTimeout: time.Second,
+	}
+	reader.call.Store(&ctx)
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := reader.Read(make([]byte, 10))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected read to stop on cancellation, took %v", elapsed)
+	}
+}
+
+func TestConnection_ReceiveContext_ConnectionCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := &bleReader{rxBuffer: make(chan []byte), ctx: ctx}
+	conn := &Connection{
+		ctx:    ctx,
+		reader: reader,
+		frames: codec.NewFrameReader(reader, TransportCRC, MaxMessageSize),
+	}
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err := conn.ReceiveContext(context.Background())
+	if !errors.Is(err, transport.ErrContextCanceled) {
+		t.Errorf("Expected ErrContextCanceled, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"sync"
+	"time"
+)
+
+// CodecOp identifies the codec operation of a CodecEvent.
+type CodecOp uint8
+
+// Codec operations.
+const (
+	CodecEncode CodecOp = 0x01 // A message was encoded into a frame for sending
+	CodecDecode CodecOp = 0x02 // A received frame was decoded into a message
+)
+
+// CodecEvent describes one encode or decode performed by a connection.
+type CodecEvent struct {
+	Op       CodecOp       // Operation performed
+	Duration time.Duration // Time spent in the codec
+	Err      error         // Codec error (nil on success)
+}
+
+// CodecNotifier is implemented by connections that report their codec operations.
+// The built-in transport connections implement it and connection wrappers forward it.
+type CodecNotifier interface {
+	// ObserveCodec registers fn to be called after every encode and decode and returns
+	// a function that removes it. Callbacks run synchronously on the I/O path and must
+	// not block.
+	ObserveCodec(fn func(CodecEvent)) (remove func())
+}
+
+// ObserveCodec registers fn for the codec operations of conn. It reports false, and
+// returns a no-op remove function, if conn does not report them.
+func ObserveCodec(conn Connection, fn func(CodecEvent)) (remove func(), ok bool) {
+	if n, ok := conn.(CodecNotifier); ok {
+		return n.ObserveCodec(fn), true
+	}
+	return func() {}, false
+}
+
+// CodecObservers holds the codec observers of a connection for transport
+// implementations. It is safe for concurrent use; the zero value is ready to use.
+type CodecObservers struct {
+	mu     sync.Mutex                  // Guards fns and nextID
+	fns    map[uint64]func(CodecEvent) // Observers by registration ID
+	nextID uint64                      // ID of the next observer
+}
+
+// Observe registers fn and returns a function that removes it.
+func (o *CodecObservers) Observe(fn func(CodecEvent)) (remove func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.fns == nil {
+		o.fns = make(map[uint64]func(CodecEvent))
+	}
+	id := o.nextID
+	o.nextID++
+	o.fns[id] = fn
+
+	return func() {
+		o.mu.Lock()
+		delete(o.fns, id)
+		o.mu.Unlock()
+	}
+}
+
+// Notify reports an operation that started at start and ended now with err.
+func (o *CodecObservers) Notify(op CodecOp, start time.Time, err error) {
+	if fns := o.observers(); len(fns) > 0 {
+		event := CodecEvent{Op: op, Duration: time.Since(start), Err: err}
+		for _, fn := range fns {
+			fn(event)
+		}
+	}
+}
+
+// Forward reports an event received from another connection, such as the current link
+// of a wrapper that replaces its links.
+func (o *CodecObservers) Forward(event CodecEvent) {
+	for _, fn := range o.observers() {
+		fn(event)
+	}
+}
+
+// observers returns the registered observers.
+func (o *CodecObservers) observers() []func(CodecEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.fns) == 0 {
+		return nil
+	}
+	fns := make([]func(CodecEvent), 0, len(o.fns))
+	for _, fn := range o.fns {
+		fns = append(fns, fn)
+	}
+	return fns
+}
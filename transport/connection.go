@@ -1,6 +1,9 @@
 package transport
 
-import "kinetica-protocol/protocol/message"
+import (
+	"context"
+	"kinetica-protocol/protocol/message"
+)
 
 // ConnectionState represents the current state of a transport connection.
 type ConnectionState uint8
@@ -9,6 +12,9 @@ type ConnectionState uint8
 const (
 	StateConnected    ConnectionState = 0x01 // Connection is active and ready for communication
 	StateDisconnected ConnectionState = 0x02 // Connection is closed or failed
+	StateConnecting   ConnectionState = 0x03 // Connection is being established
+	StateReconnecting ConnectionState = 0x04 // Link was lost and is being re-established
+	StateClosing      ConnectionState = 0x05 // Close was called and the connection is shutting down
 )
 
 // Connection represents an active communication channel between two endpoints.
@@ -19,13 +25,33 @@ type Connection interface {
 	// The message is automatically encoded with appropriate headers and CRC
 	// based on the transport type.
 	Send(msg message.Message, msgType message.MsgType) error
+
+	// SendPacket encodes and transmits a message like Send, but uses the caller-supplied
+	// PacketID instead of the connection's counter. Layers that correlate Acks with
+	// sent packets or retransmit them under the same PacketID use this method.
+	SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error
+
+	// SendContext encodes and transmits a message like Send, but gives up when ctx is
+	// canceled or its deadline passes. It returns an error wrapping ErrContextCanceled
+	// and ctx.Err() in that case.
+	SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error
 	
 	// Receive waits for and decodes an incoming protocol message.
 	// Returns the decoded message or an error if reception/validation fails.
 	// This method may block until a message arrives or timeout occurs.
 	Receive() (message.Message, error)
+
+	// ReceiveEnvelope waits for and decodes an incoming protocol message like Receive,
+	// but also returns the packet metadata (header, footer, raw bytes, receive time).
+	ReceiveEnvelope() (*message.Envelope, error)
+
+	// ReceiveContext waits for and decodes an incoming message like Receive, but returns
+	// early when ctx is canceled or its deadline passes, with an error wrapping
+	// ErrContextCanceled and ctx.Err().
+	ReceiveContext(ctx context.Context) (message.Message, error)
 	
-	// State returns the current connection state.
+	// State returns the current connection state. It never blocks or performs I/O;
+	// the state reflects the outcome of the most recent reads and writes.
 	State() ConnectionState
 	
 	// Close gracefully terminates the connection and releases resources.
@@ -0,0 +1,17 @@
+package transport
+
+// Flusher is implemented by connections that may buffer outgoing packets, such as
+// network connections with send batching enabled, and by wrappers around them.
+type Flusher interface {
+	// Flush writes all buffered packets to the peer.
+	Flush() error
+}
+
+// Flush writes the packets buffered by conn, if it buffers any.
+// It returns nil for connections that do not implement Flusher.
+func Flush(conn Connection) error {
+	if f, ok := conn.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
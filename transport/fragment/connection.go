@@ -0,0 +1,195 @@
+package fragment
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+)
+
+// Connection wraps a transport connection with automatic fragmentation and reassembly.
+// Messages whose encoded payload exceeds the fragment size are sent as a sequence of
+// Fragment packets; incoming fragments are reassembled before being returned by Receive.
+type Connection struct {
+	conn         transport.Connection // Underlying transport connection
+	fragmentSize int                  // Payload size above which messages are fragmented
+	fragmenter   *Fragmenter          // Splits outgoing oversized messages
+	reassembler  *Reassembler         // Rebuilds incoming fragmented messages
+}
+
+// NewConnection wraps an existing connection with fragmentation support.
+func NewConnection(conn transport.Connection, config Config) *Connection {
+	config = config.withDefaults()
+	return &Connection{
+		conn:         conn,
+		fragmentSize: config.FragmentSize,
+		fragmenter:   NewFragmenter(config.FragmentSize),
+		reassembler:  NewReassembler(config),
+	}
+}
+
+// Send transmits the message directly if it fits into a single fragment,
+// otherwise it splits the message and sends every fragment in order.
+func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	return c.send(context.Background(), msg, msgType, func(m message.Message, t message.MsgType) error {
+		return c.conn.Send(m, t)
+	})
+}
+
+// SendContext transmits the message like Send, stopping before the next fragment
+// once ctx is canceled or its deadline passes.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return c.send(ctx, msg, msgType, func(m message.Message, t message.MsgType) error {
+		return c.conn.SendContext(ctx, m, t)
+	})
+}
+
+// SendPacket transmits the message like Send using the given PacketID.
+// When the message is fragmented, the PacketID is used for the last fragment, so the
+// reassembled envelope on the receiving side carries it for Ack correlation.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	return c.send(context.Background(), msg, msgType, func(m message.Message, t message.MsgType) error {
+		return c.conn.SendPacket(m, t, packetID)
+	})
+}
+
+// send fragments the message if needed and transmits the last (or only) packet with last.
+func (c *Connection) send(ctx context.Context, msg message.Message, msgType message.MsgType, last func(message.Message, message.MsgType) error) error {
+	if msg == nil || msgType == message.MsgTypeFragment {
+		return last(msg, msgType)
+	}
+
+	data, err := encode(msg, msgType)
+	if err != nil {
+		return fmt.Errorf("%w: failed to marshal message: %w", transport.ErrSendFailed, err)
+	}
+
+	if len(data)-1 <= c.fragmentSize {
+		return last(msg, msgType)
+	}
+
+	fragments, err := c.fragmenter.split(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrSendFailed, err)
+	}
+
+	for i, frag := range fragments {
+		if i == len(fragments)-1 {
+			err = last(frag, message.MsgTypeFragment)
+		} else {
+			err = c.conn.SendContext(ctx, frag, message.MsgTypeFragment)
+		}
+		if err != nil {
+			return fmt.Errorf("fragment %d/%d of message %d: %w", frag.FragmentNum+1, frag.TotalFragments, frag.MessageID, err)
+		}
+	}
+
+	return nil
+}
+
+// Receive returns the next complete message, transparently collecting fragments.
+// Non-fragment messages are returned as they arrive.
+func (c *Connection) Receive() (message.Message, error) {
+	envelope, err := c.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveContext returns the next complete message like Receive, giving up when ctx is
+// canceled or its deadline passes. Fragments collected so far are kept for later calls.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	for {
+		msg, err := c.conn.ReceiveContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		full, err := c.reassemble(msg)
+		if err != nil || full != nil {
+			return full, err
+		}
+	}
+}
+
+// ReceiveEnvelope returns the next complete message with its packet metadata.
+// For a reassembled message the header, footer, and raw bytes are those of the
+// fragment that completed it; the payload is replaced by the full message and the
+// header type by its type, so the header always describes the payload.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	for {
+		envelope, err := c.conn.ReceiveEnvelope()
+		if err != nil {
+			return nil, err
+		}
+
+		full, err := c.reassemble(envelope.Payload)
+		if err != nil {
+			return nil, err
+		}
+		if full != nil {
+			if full != envelope.Payload {
+				envelope.Payload = full
+				envelope.Header.Type = full.MessageType()
+			}
+			return envelope, nil
+		}
+	}
+}
+
+// reassemble returns non-fragment messages unchanged and feeds fragments to the reassembler.
+// It returns nil without error while a fragmented message is still incomplete.
+func (c *Connection) reassemble(msg message.Message) (message.Message, error) {
+	frag, ok := msg.(*message.Fragment)
+	if !ok {
+		return msg, nil
+	}
+
+	full, err := c.reassembler.Add(frag)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", transport.ErrReceiveFailed, err)
+	}
+	return full, nil
+}
+
+// Stats returns the statistics of the underlying connection. Counters reflect
+// individual fragments as they cross the wire.
+func (c *Connection) Stats() transport.Stats {
+	return transport.StatsOf(c.conn)
+}
+
+// Flush writes packets buffered by the underlying connection.
+func (c *Connection) Flush() error {
+	return transport.Flush(c.conn)
+}
+
+// State returns the state of the underlying connection.
+func (c *Connection) State() transport.ConnectionState {
+	return c.conn.State()
+}
+
+// Subscribe registers fn for the state transitions of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	unsubscribe, _ = transport.SubscribeState(c.conn, fn)
+	return unsubscribe
+}
+
+// ObserveCodec registers fn for the codec operations of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	remove, _ = transport.ObserveCodec(c.conn, fn)
+	return remove
+}
+
+// PeerIdentity returns the verified certificate of the underlying connection's peer.
+func (c *Connection) PeerIdentity() (*x509.Certificate, bool) {
+	return transport.PeerIdentityOf(c.conn)
+}
+
+// Close closes the underlying connection.
+func (c *Connection) Close() error {
+	return c.conn.Close()
+}
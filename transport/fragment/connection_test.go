@@ -0,0 +1,218 @@
+package fragment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"testing"
+)
+
+type sentMessage struct {
+	msg      message.Message
+	msgType  message.MsgType
+	packetID uint8
+}
+
+type mockConnection struct {
+	sent   []sentMessage
+	inbox  []message.Message
+	closed bool
+}
+
+func (m *mockConnection) Send(msg message.Message, msgType message.MsgType) error {
+	m.sent = append(m.sent, sentMessage{msg: msg, msgType: msgType})
+	return nil
+}
+
+func (m *mockConnection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	m.sent = append(m.sent, sentMessage{msg: msg, msgType: msgType, packetID: packetID})
+	return nil
+}
+
+func (m *mockConnection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+	return m.Send(msg, msgType)
+}
+
+func (m *mockConnection) Receive() (message.Message, error) {
+	envelope, err := m.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+func (m *mockConnection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+	return m.Receive()
+}
+
+func (m *mockConnection) ReceiveEnvelope() (*message.Envelope, error) {
+	if len(m.inbox) == 0 {
+		return nil, transport.ErrConnectionClosed
+	}
+	msg := m.inbox[0]
+	m.inbox = m.inbox[1:]
+	return &message.Envelope{Header: message.NewHeader(1, msg.MessageType(), 0), Payload: msg}, nil
+}
+
+func (m *mockConnection) State() transport.ConnectionState {
+	return transport.StateConnected
+}
+
+func (m *mockConnection) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestConnection_SendSmallMessage(t *testing.T) {
+	mock := &mockConnection{}
+	conn := NewConnection(mock, Config{})
+
+	heartbeat := &message.SensorHeartbeat{SensorID: 1, Battery: 90, Status: message.Ok}
+	if err := conn.Send(heartbeat, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(mock.sent) != 1 || mock.sent[0].msgType != message.MsgTypeHeartbeat {
+		t.Fatalf("Expected message to be sent unfragmented, got %+v", mock.sent)
+	}
+}
+
+func TestConnection_SendLargeMessage(t *testing.T) {
+	mock := &mockConnection{}
+	conn := NewConnection(mock, Config{FragmentSize: 100})
+
+	if err := conn.Send(largeConfig(10), message.MsgTypeConfig); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(mock.sent) < 2 {
+		t.Fatalf("Expected multiple fragments, got %d", len(mock.sent))
+	}
+	for _, s := range mock.sent {
+		if s.msgType != message.MsgTypeFragment {
+			t.Errorf("Expected fragment message type, got %v", s.msgType)
+		}
+	}
+}
+
+func TestConnection_SendPacketLastFragment(t *testing.T) {
+	mock := &mockConnection{}
+	conn := NewConnection(mock, Config{FragmentSize: 100})
+
+	if err := conn.SendPacket(largeConfig(10), message.MsgTypeConfig, 42); err != nil {
+		t.Fatalf("SendPacket() error = %v", err)
+	}
+
+	for i, s := range mock.sent {
+		last := i == len(mock.sent)-1
+		if last && s.packetID != 42 {
+			t.Errorf("Expected last fragment to carry PacketID 42, got %d", s.packetID)
+		}
+		if !last && s.packetID != 0 {
+			t.Errorf("Expected fragment %d to use the connection counter", i)
+		}
+	}
+}
+
+func TestConnection_RoundTrip(t *testing.T) {
+	sender := &mockConnection{}
+	if err := NewConnection(sender, Config{FragmentSize: 100}).Send(largeConfig(10), message.MsgTypeConfig); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	receiver := &mockConnection{}
+	receiver.inbox = append(receiver.inbox, &message.SensorHeartbeat{SensorID: 2})
+	for _, s := range sender.sent {
+		receiver.inbox = append(receiver.inbox, s.msg)
+	}
+	conn := NewConnection(receiver, Config{})
+
+	msg, err := conn.Receive()
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if _, ok := msg.(*message.SensorHeartbeat); !ok {
+		t.Fatalf("Expected pass-through heartbeat, got %T", msg)
+	}
+
+	envelope, err := conn.ReceiveEnvelope()
+	if err != nil {
+		t.Fatalf("ReceiveEnvelope() error = %v", err)
+	}
+	cfg, ok := envelope.Payload.(*message.SensorConfig)
+	if !ok {
+		t.Fatalf("Expected SensorConfig, got %T", envelope.Payload)
+	}
+	if envelope.Header.Type != message.MsgTypeConfig {
+		t.Errorf("Expected the header type of the full message, got %v", envelope.Header.Type)
+	}
+	if len(cfg.Config) != 10 {
+		t.Errorf("Expected 10 config items, got %d", len(cfg.Config))
+	}
+
+	if _, err := conn.Receive(); !errors.Is(err, transport.ErrConnectionClosed) {
+		t.Errorf("Expected underlying error, got %v", err)
+	}
+}
+
+func TestConnection_ReceiveContext(t *testing.T) {
+	sender := &mockConnection{}
+	if err := NewConnection(sender, Config{FragmentSize: 100}).Send(largeConfig(10), message.MsgTypeConfig); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	receiver := &mockConnection{}
+	for _, s := range sender.sent {
+		receiver.inbox = append(receiver.inbox, s.msg)
+	}
+	conn := NewConnection(receiver, Config{})
+
+	msg, err := conn.ReceiveContext(context.Background())
+	if err != nil {
+		t.Fatalf("ReceiveContext() error = %v", err)
+	}
+	if _, ok := msg.(*message.SensorConfig); !ok {
+		t.Fatalf("Expected SensorConfig, got %T", msg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.ReceiveContext(ctx); !errors.Is(err, transport.ErrContextCanceled) {
+		t.Errorf("Expected ErrContextCanceled, got %v", err)
+	}
+}
+
+func TestConnection_SendContextCanceled(t *testing.T) {
+	mock := &mockConnection{}
+	conn := NewConnection(mock, Config{FragmentSize: 100})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := conn.SendContext(ctx, largeConfig(10), message.MsgTypeConfig); !errors.Is(err, transport.ErrContextCanceled) {
+		t.Fatalf("Expected ErrContextCanceled, got %v", err)
+	}
+	if len(mock.sent) != 0 {
+		t.Errorf("Expected no fragments to be sent, got %d", len(mock.sent))
+	}
+}
+
+func TestConnection_Close(t *testing.T) {
+	mock := &mockConnection{}
+	conn := NewConnection(mock, Config{})
+
+	if err := conn.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if !mock.closed {
+		t.Error("Expected underlying connection to be closed")
+	}
+}
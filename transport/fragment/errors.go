@@ -0,0 +1,13 @@
+package fragment
+
+import "errors"
+
+// Fragmentation error definitions for splitting and reassembling messages.
+var (
+	ErrFragmentFailed       = errors.New("fragmentation failed")             // Message could not be encoded for fragmentation
+	ErrTooManyFragments     = errors.New("too many fragments")               // Message needs more fragments than the protocol allows
+	ErrInvalidFragment      = errors.New("invalid fragment")                 // Fragment number or count out of range
+	ErrInconsistentFragment = errors.New("inconsistent fragment")            // Fragment disagrees with earlier fragments of the message
+	ErrBufferLimit          = errors.New("reassembly buffer limit exceeded") // Message does not fit into the reassembly memory cap
+	ErrReassemblyFailed     = errors.New("reassembly failed")                // Reassembled data could not be decoded
+)
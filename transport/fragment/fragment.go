@@ -0,0 +1,112 @@
+// Package fragment provides automatic fragmentation and reassembly of protocol messages
+// that are too large for a single packet on the underlying transport. It wraps any
+// transport.Connection, splitting oversized messages into message.Fragment packets on send
+// and putting them back together on receive.
+package fragment
+
+import (
+	"fmt"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"sync/atomic"
+	"time"
+)
+
+// Fragmentation defaults chosen to fit a single fragment into a BLE packet.
+const (
+	DefaultFragmentSize   = 200             // Fragment data bytes per packet (fits BLE MaxMessageSize with CRC)
+	DefaultTimeout        = 5 * time.Second // Maximum time to collect all fragments of a message
+	DefaultMaxPending     = 16              // Maximum messages being reassembled at the same time
+	DefaultMaxBufferBytes = 64 * 1024       // Maximum bytes buffered across all pending messages
+
+	// MaxFragments is the largest number of fragments a single message can be split into,
+	// limited by the 8-bit TotalFragments field.
+	MaxFragments = 255
+)
+
+// Config defines fragmentation and reassembly parameters.
+// Zero values are replaced with the package defaults.
+type Config struct {
+	FragmentSize   int           // Maximum fragment data bytes per Fragment message
+	Timeout        time.Duration // Time after which an incomplete message is discarded
+	MaxPending     int           // Maximum number of messages being reassembled concurrently
+	MaxBufferBytes int           // Maximum total bytes buffered across pending messages
+}
+
+// withDefaults returns a copy of the configuration with zero values replaced by defaults.
+func (c Config) withDefaults() Config {
+	if c.FragmentSize <= 0 {
+		c.FragmentSize = DefaultFragmentSize
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.MaxPending <= 0 {
+		c.MaxPending = DefaultMaxPending
+	}
+	if c.MaxBufferBytes <= 0 {
+		c.MaxBufferBytes = DefaultMaxBufferBytes
+	}
+	return c
+}
+
+// Fragmenter splits encoded messages into Fragment packets.
+// The reassembled data is the message type byte followed by the encoded payload,
+// so the receiver can decode the original message without any extra signalling.
+type Fragmenter struct {
+	fragmentSize int           // Maximum fragment data bytes per Fragment message
+	messageID    atomic.Uint32 // Atomic counter for unique fragmented message IDs
+}
+
+// NewFragmenter creates a fragmenter producing fragments of at most fragmentSize data bytes.
+func NewFragmenter(fragmentSize int) *Fragmenter {
+	if fragmentSize <= 0 {
+		fragmentSize = DefaultFragmentSize
+	}
+	return &Fragmenter{fragmentSize: fragmentSize}
+}
+
+// Split encodes the message and splits it into one or more fragments sharing a new MessageID.
+func (f *Fragmenter) Split(msg message.Message, msgType message.MsgType) ([]*message.Fragment, error) {
+	data, err := encode(msg, msgType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFragmentFailed, err)
+	}
+
+	return f.split(data)
+}
+
+// encode returns the data carried by the fragments of a message: the message type byte
+// followed by the encoded payload.
+func encode(msg message.Message, msgType message.MsgType) ([]byte, error) {
+	return codec.AppendMarshalPayload([]byte{byte(msgType)}, msg)
+}
+
+// split divides type-prefixed data into fragments of the configured size. The fragments
+// reference data.
+func (f *Fragmenter) split(data []byte) ([]*message.Fragment, error) {
+	total := (len(data) + f.fragmentSize - 1) / f.fragmentSize
+	if total > MaxFragments {
+		return nil, fmt.Errorf("%w: %d fragments of %d bytes needed, maximum %d", ErrTooManyFragments, total, f.fragmentSize, MaxFragments)
+	}
+
+	messageID := f.getNextMessageID()
+	fragments := make([]*message.Fragment, 0, total)
+
+	for i := 0; i < total; i++ {
+		end := min((i+1)*f.fragmentSize, len(data))
+		fragments = append(fragments, &message.Fragment{
+			MessageID:      messageID,
+			FragmentNum:    uint8(i),
+			TotalFragments: uint8(total),
+			Data:           data[i*f.fragmentSize : end],
+		})
+	}
+
+	return fragments, nil
+}
+
+// getNextMessageID generates a unique fragmented message ID with wraparound.
+func (f *Fragmenter) getNextMessageID() uint16 {
+	return uint16(f.messageID.Add(1) % 65536)
+}
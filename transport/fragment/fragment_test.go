@@ -0,0 +1,238 @@
+package fragment
+
+import (
+	"bytes"
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"testing"
+	"time"
+)
+
+func largeConfig(items int) *message.SensorConfig {
+	cfg := &message.SensorConfig{
+		SensorID:  7,
+		TimeStamp: 123456789,
+	}
+	for i := 0; i < items; i++ {
+		value := bytes.Repeat([]byte{byte(i)}, 40)
+		cfg.Config = append(cfg.Config, message.Item{
+			Key:    message.ConfigKeyCalibration,
+			Length: uint8(len(value)),
+			Value:  value,
+		})
+	}
+	return cfg
+}
+
+func TestFragmenter_Split(t *testing.T) {
+	f := NewFragmenter(50)
+
+	fragments, err := f.Split(largeConfig(10), message.MsgTypeConfig)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+
+	if len(fragments) < 2 {
+		t.Fatalf("Expected multiple fragments, got %d", len(fragments))
+	}
+
+	for i, frag := range fragments {
+		if int(frag.FragmentNum) != i {
+			t.Errorf("Expected fragment number %d, got %d", i, frag.FragmentNum)
+		}
+		if int(frag.TotalFragments) != len(fragments) {
+			t.Errorf("Expected total %d, got %d", len(fragments), frag.TotalFragments)
+		}
+		if frag.MessageID != fragments[0].MessageID {
+			t.Error("Expected all fragments to share a MessageID")
+		}
+		if len(frag.Data) > 50 {
+			t.Errorf("Fragment %d has %d bytes, maximum 50", i, len(frag.Data))
+		}
+	}
+
+	if fragments[0].Data[0] != byte(message.MsgTypeConfig) {
+		t.Errorf("Expected first data byte to be message type, got 0x%02x", fragments[0].Data[0])
+	}
+}
+
+func TestFragmenter_UniqueMessageIDs(t *testing.T) {
+	f := NewFragmenter(50)
+
+	a, _ := f.Split(largeConfig(3), message.MsgTypeConfig)
+	b, _ := f.Split(largeConfig(3), message.MsgTypeConfig)
+
+	if a[0].MessageID == b[0].MessageID {
+		t.Error("Expected different MessageIDs for different messages")
+	}
+}
+
+func TestFragmenter_TooManyFragments(t *testing.T) {
+	f := NewFragmenter(1)
+
+	_, err := f.Split(largeConfig(10), message.MsgTypeConfig)
+	if !errors.Is(err, ErrTooManyFragments) {
+		t.Errorf("Expected ErrTooManyFragments, got %v", err)
+	}
+}
+
+func TestReassembler_OutOfOrder(t *testing.T) {
+	original := largeConfig(10)
+	fragments, err := NewFragmenter(60).Split(original, message.MsgTypeConfig)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+
+	r := NewReassembler(Config{})
+
+	var result message.Message
+	for i := len(fragments) - 1; i >= 0; i-- {
+		msg, err := r.Add(fragments[i])
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if msg != nil && i != 0 {
+			t.Fatalf("Message completed early at fragment %d", i)
+		}
+		result = msg
+	}
+
+	cfg, ok := result.(*message.SensorConfig)
+	if !ok {
+		t.Fatalf("Expected SensorConfig, got %T", result)
+	}
+	if cfg.SensorID != original.SensorID || len(cfg.Config) != len(original.Config) {
+		t.Error("Reassembled config does not match original")
+	}
+	if !bytes.Equal(cfg.Config[9].Value, original.Config[9].Value) {
+		t.Error("Reassembled item value does not match original")
+	}
+	if r.Pending() != 0 {
+		t.Errorf("Expected no pending messages, got %d", r.Pending())
+	}
+}
+
+func TestReassembler_Duplicates(t *testing.T) {
+	fragments, _ := NewFragmenter(60).Split(largeConfig(4), message.MsgTypeConfig)
+	r := NewReassembler(Config{})
+
+	if msg, _ := r.Add(fragments[0]); msg != nil {
+		t.Fatal("Expected incomplete message")
+	}
+	if msg, err := r.Add(fragments[0]); msg != nil || err != nil {
+		t.Fatalf("Expected duplicate to be ignored, got %v, %v", msg, err)
+	}
+
+	var result message.Message
+	for _, frag := range fragments[1:] {
+		result, _ = r.Add(frag)
+	}
+	if result == nil {
+		t.Fatal("Expected completed message")
+	}
+
+	if msg, err := r.Add(fragments[len(fragments)-1]); msg != nil || err != nil {
+		t.Errorf("Expected late duplicate to be ignored, got %v, %v", msg, err)
+	}
+}
+
+func TestReassembler_ReusedMessageID(t *testing.T) {
+	r := NewReassembler(Config{})
+	first, _ := NewFragmenter(60).Split(largeConfig(4), message.MsgTypeConfig)
+	second, _ := NewFragmenter(60).Split(largeConfig(3), message.MsgTypeConfig)
+	if first[0].MessageID != second[0].MessageID {
+		t.Fatal("Expected restarted fragmenters to reuse the MessageID")
+	}
+
+	for i, fragments := range [][]*message.Fragment{first, second} {
+		var result message.Message
+		for _, frag := range fragments {
+			result, _ = r.Add(frag)
+		}
+		cfg, ok := result.(*message.SensorConfig)
+		if !ok {
+			t.Fatalf("Message %d: expected reassembled SensorConfig, got %v", i, result)
+		}
+		if want := 4 - i; len(cfg.Config) != want {
+			t.Errorf("Message %d: expected %d items, got %d", i, want, len(cfg.Config))
+		}
+	}
+}
+
+func TestReassembler_Timeout(t *testing.T) {
+	fragments, _ := NewFragmenter(60).Split(largeConfig(4), message.MsgTypeConfig)
+	r := NewReassembler(Config{Timeout: time.Second})
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	r.Add(fragments[0])
+	if r.Pending() != 1 {
+		t.Fatalf("Expected 1 pending message, got %d", r.Pending())
+	}
+
+	now = now.Add(2 * time.Second)
+	for _, frag := range fragments[1:] {
+		if msg, _ := r.Add(frag); msg != nil {
+			t.Fatal("Expected expired message not to complete")
+		}
+	}
+}
+
+func TestReassembler_MaxPending(t *testing.T) {
+	f := NewFragmenter(60)
+	r := NewReassembler(Config{MaxPending: 2})
+
+	now := time.Now()
+	r.now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	for i := 0; i < 3; i++ {
+		fragments, _ := f.Split(largeConfig(4), message.MsgTypeConfig)
+		r.Add(fragments[0])
+	}
+
+	if r.Pending() != 2 {
+		t.Errorf("Expected 2 pending messages, got %d", r.Pending())
+	}
+}
+
+func TestReassembler_BufferLimit(t *testing.T) {
+	fragments, _ := NewFragmenter(60).Split(largeConfig(4), message.MsgTypeConfig)
+	r := NewReassembler(Config{MaxBufferBytes: 100})
+
+	var err error
+	for _, frag := range fragments {
+		if _, err = r.Add(frag); err != nil {
+			break
+		}
+	}
+
+	if !errors.Is(err, ErrBufferLimit) {
+		t.Errorf("Expected ErrBufferLimit, got %v", err)
+	}
+	if r.Pending() != 0 {
+		t.Errorf("Expected dropped message, got %d pending", r.Pending())
+	}
+}
+
+func TestReassembler_InvalidFragment(t *testing.T) {
+	r := NewReassembler(Config{})
+
+	_, err := r.Add(&message.Fragment{MessageID: 1, FragmentNum: 2, TotalFragments: 2})
+	if !errors.Is(err, ErrInvalidFragment) {
+		t.Errorf("Expected ErrInvalidFragment, got %v", err)
+	}
+}
+
+func TestReassembler_InconsistentFragment(t *testing.T) {
+	r := NewReassembler(Config{})
+
+	r.Add(&message.Fragment{MessageID: 1, FragmentNum: 0, TotalFragments: 3, Data: []byte{0x02}})
+	_, err := r.Add(&message.Fragment{MessageID: 1, FragmentNum: 1, TotalFragments: 4, Data: []byte{0x00}})
+	if !errors.Is(err, ErrInconsistentFragment) {
+		t.Errorf("Expected ErrInconsistentFragment, got %v", err)
+	}
+}
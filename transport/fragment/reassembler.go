@@ -0,0 +1,200 @@
+package fragment
+
+import (
+	"fmt"
+	"hash/maphash"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"sync"
+	"time"
+)
+
+// pending holds the fragments received so far for a single fragmented message.
+type pending struct {
+	total    uint8     // Total number of fragments announced by the sender
+	parts    [][]byte  // Fragment data indexed by fragment number (nil if missing)
+	received int       // Number of distinct fragments received
+	size     int       // Total buffered data bytes
+	started  time.Time // Arrival time of the first fragment
+	digests  []uint64  // Hash of each fragment's data, indexed by fragment number
+}
+
+// completion records a reassembled message so its late duplicate fragments are ignored.
+type completion struct {
+	total   uint8     // Total number of fragments of the message
+	digests []uint64  // Hash of each fragment's data, indexed by fragment number
+	at      time.Time // Completion time
+}
+
+// Reassembler collects Fragment packets per MessageID and rebuilds the original messages.
+// Fragments may arrive out of order; duplicates are ignored, incomplete messages are
+// discarded after the timeout, and buffered memory is capped by evicting the oldest message.
+// A fragment is a late duplicate of a completed message only if its MessageID, fragment
+// count, and data all match, so a new message reusing the MessageID, for example after
+// the sender restarted its counter, is still reassembled.
+type Reassembler struct {
+	mu             sync.Mutex
+	pending        map[uint16]*pending    // Messages being reassembled by MessageID
+	completed      map[uint16]*completion // Recently completed messages for late duplicate detection
+	buffered       int                    // Total bytes buffered across pending messages
+	timeout        time.Duration          // Time after which an incomplete message is discarded
+	maxPending     int                    // Maximum number of pending messages
+	maxBufferBytes int                    // Maximum total buffered bytes
+	seed           maphash.Seed           // Seed of the fragment data hashes
+	now            func() time.Time       // Clock used for timeouts
+}
+
+// NewReassembler creates a reassembler with the specified limits.
+func NewReassembler(config Config) *Reassembler {
+	config = config.withDefaults()
+	return &Reassembler{
+		pending:        make(map[uint16]*pending),
+		completed:      make(map[uint16]*completion),
+		timeout:        config.Timeout,
+		maxPending:     config.MaxPending,
+		maxBufferBytes: config.MaxBufferBytes,
+		seed:           maphash.MakeSeed(),
+		now:            time.Now,
+	}
+}
+
+// Add stores a fragment and returns the original message once all fragments have arrived.
+// It returns a nil message while the message is still incomplete or when the fragment
+// is a duplicate of one already received.
+func (r *Reassembler) Add(frag *message.Fragment) (message.Message, error) {
+	if frag.TotalFragments == 0 || frag.FragmentNum >= frag.TotalFragments {
+		return nil, fmt.Errorf("%w: fragment %d of %d", ErrInvalidFragment, frag.FragmentNum, frag.TotalFragments)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.expire(now)
+
+	digest := maphash.Bytes(r.seed, frag.Data)
+	if c, done := r.completed[frag.MessageID]; done {
+		if c.total == frag.TotalFragments && c.digests[frag.FragmentNum] == digest {
+			return nil, nil
+		}
+		delete(r.completed, frag.MessageID)
+	}
+
+	p, ok := r.pending[frag.MessageID]
+	if !ok {
+		for len(r.pending) >= r.maxPending {
+			r.evictOldest()
+		}
+		p = &pending{
+			total:   frag.TotalFragments,
+			parts:   make([][]byte, frag.TotalFragments),
+			started: now,
+			digests: make([]uint64, frag.TotalFragments),
+		}
+		r.pending[frag.MessageID] = p
+	}
+
+	if p.total != frag.TotalFragments {
+		r.drop(frag.MessageID)
+		return nil, fmt.Errorf("%w: message %d announced %d fragments, got %d", ErrInconsistentFragment, frag.MessageID, p.total, frag.TotalFragments)
+	}
+
+	if p.parts[frag.FragmentNum] != nil {
+		return nil, nil
+	}
+
+	if len(frag.Data) > r.maxBufferBytes {
+		r.drop(frag.MessageID)
+		return nil, fmt.Errorf("%w: fragment of %d bytes, maximum %d", ErrBufferLimit, len(frag.Data), r.maxBufferBytes)
+	}
+
+	for r.buffered+len(frag.Data) > r.maxBufferBytes && len(r.pending) > 1 {
+		r.evictOldestExcept(int(frag.MessageID))
+	}
+	if r.buffered+len(frag.Data) > r.maxBufferBytes {
+		r.drop(frag.MessageID)
+		return nil, fmt.Errorf("%w: message %d exceeds %d bytes", ErrBufferLimit, frag.MessageID, r.maxBufferBytes)
+	}
+
+	part := make([]byte, len(frag.Data))
+	copy(part, frag.Data)
+	p.parts[frag.FragmentNum] = part
+	p.digests[frag.FragmentNum] = digest
+	p.received++
+	p.size += len(frag.Data)
+	r.buffered += len(frag.Data)
+
+	if p.received < int(p.total) {
+		return nil, nil
+	}
+
+	data := make([]byte, 0, p.size)
+	for _, part := range p.parts {
+		data = append(data, part...)
+	}
+	r.drop(frag.MessageID)
+	r.completed[frag.MessageID] = &completion{total: p.total, digests: p.digests, at: now}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: message %d is empty", ErrReassemblyFailed, frag.MessageID)
+	}
+
+	msg, err := codec.UnmarshalPayload(message.MsgType(data[0]), data[1:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: message %d: %w", ErrReassemblyFailed, frag.MessageID, err)
+	}
+
+	return msg, nil
+}
+
+// Pending returns the number of messages currently being reassembled.
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+// expire discards incomplete messages and completed IDs older than the timeout.
+func (r *Reassembler) expire(now time.Time) {
+	for id, p := range r.pending {
+		if now.Sub(p.started) > r.timeout {
+			r.drop(id)
+		}
+	}
+	for id, c := range r.completed {
+		if now.Sub(c.at) > r.timeout {
+			delete(r.completed, id)
+		}
+	}
+}
+
+// evictOldest discards the pending message that started first.
+func (r *Reassembler) evictOldest() {
+	r.evictOldestExcept(-1)
+}
+
+// evictOldestExcept discards the oldest pending message other than the given MessageID.
+// A negative keep value excludes nothing.
+func (r *Reassembler) evictOldestExcept(keep int) {
+	oldestID := -1
+	var oldest time.Time
+	for id, p := range r.pending {
+		if int(id) == keep {
+			continue
+		}
+		if oldestID < 0 || p.started.Before(oldest) {
+			oldestID, oldest = int(id), p.started
+		}
+	}
+	if oldestID >= 0 {
+		r.drop(uint16(oldestID))
+	}
+}
+
+// drop removes a pending message and releases its buffered bytes.
+func (r *Reassembler) drop(id uint16) {
+	if p, ok := r.pending[id]; ok {
+		r.buffered -= p.size
+		delete(r.pending, id)
+	}
+}
@@ -0,0 +1,22 @@
+package transport
+
+import "crypto/x509"
+
+// PeerIdentifier is implemented by connections that authenticate their peer, such as
+// TCP connections using TLS. Connection wrappers forward it to the connection they wrap.
+type PeerIdentifier interface {
+	// PeerIdentity returns the peer's leaf certificate after it was verified against
+	// the configured roots, or false if the peer presented no verified certificate.
+	// The certificate's subject alternative names (DNSNames, URIs, IPAddresses) and
+	// subject identify the peer.
+	PeerIdentity() (*x509.Certificate, bool)
+}
+
+// PeerIdentityOf returns the verified certificate of conn's peer. It reports false if
+// conn does not authenticate its peer or the peer presented no verified certificate.
+func PeerIdentityOf(conn Connection) (*x509.Certificate, bool) {
+	if p, ok := conn.(PeerIdentifier); ok {
+		return p.PeerIdentity()
+	}
+	return nil, false
+}
@@ -0,0 +1,189 @@
+// Package transporttest provides an in-memory connection for testing the connection
+// wrappers of the transport packages.
+package transporttest
+
+import (
+	"context"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"sync"
+	"sync/atomic"
+)
+
+// pipeBuffer is the number of packets a Pipe holds for its peer.
+const pipeBuffer = 64
+
+// Pipe is one end of an in-memory link. Outgoing packets are encoded, recorded, and
+// delivered to the peer unless the drop filter discards them or the peer's buffer is
+// full.
+type Pipe struct {
+	in        chan *message.Envelope       // Packets sent by the peer
+	out       chan *message.Envelope       // Packets for the peer
+	drop      func(*message.Envelope) bool // Filter discarding outgoing packets (nil keeps all)
+	packetID  atomic.Uint32                // Packet ID of the last Send
+	mu        sync.Mutex                   // Guards drop and sent
+	sent      []*message.Envelope          // Every packet sent, including dropped ones
+	closed    chan struct{}                // Closed by Close
+	closeOnce sync.Once                    // Guards closing closed
+}
+
+// NewPipe creates the two ends of a link.
+func NewPipe() (*Pipe, *Pipe) {
+	a := make(chan *message.Envelope, pipeBuffer)
+	b := make(chan *message.Envelope, pipeBuffer)
+	return &Pipe{in: a, out: b, closed: make(chan struct{})},
+		&Pipe{in: b, out: a, closed: make(chan struct{})}
+}
+
+// Send sends a message with the next packet ID.
+func (p *Pipe) Send(msg message.Message, msgType message.MsgType) error {
+	return p.SendPacket(msg, msgType, uint8(p.packetID.Add(1)))
+}
+
+// SendPacket encodes a message with the given packet ID, records it, and delivers it
+// to the peer unless the drop filter discards it. It fails once the pipe is closed.
+func (p *Pipe) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	select {
+	case <-p.closed:
+		return transport.ErrConnectionClosed
+	default:
+	}
+
+	data, err := codec.Marshal(msg, packetID, msgType, message.TransportCRC8)
+	if err != nil {
+		return err
+	}
+	envelope, err := codec.UnmarshalFrame(data, message.TransportCRC8)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.sent = append(p.sent, envelope)
+	drop := p.drop
+	p.mu.Unlock()
+
+	if drop != nil && drop(envelope) {
+		return nil
+	}
+	select {
+	case p.out <- envelope:
+	default:
+	}
+	return nil
+}
+
+// SendContext sends a message like Send unless ctx is already done.
+func (p *Pipe) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.Send(msg, msgType)
+}
+
+// Receive returns the payload of the next packet from the peer.
+func (p *Pipe) Receive() (message.Message, error) {
+	envelope, err := p.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveContext returns the payload of the next packet like Receive, giving up when
+// ctx is done.
+func (p *Pipe) ReceiveContext(ctx context.Context) (message.Message, error) {
+	select {
+	case envelope := <-p.in:
+		return envelope.Payload, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.closed:
+		return nil, transport.ErrConnectionClosed
+	}
+}
+
+// ReceiveEnvelope returns the next packet from the peer, blocking until one arrives or
+// the pipe is closed.
+func (p *Pipe) ReceiveEnvelope() (*message.Envelope, error) {
+	select {
+	case envelope := <-p.in:
+		return envelope, nil
+	case <-p.closed:
+		return nil, transport.ErrConnectionClosed
+	}
+}
+
+// State reports StateConnected until the pipe is closed and StateDisconnected after.
+func (p *Pipe) State() transport.ConnectionState {
+	select {
+	case <-p.closed:
+		return transport.StateDisconnected
+	default:
+		return transport.StateConnected
+	}
+}
+
+// Close closes this end of the pipe. The peer is not affected.
+func (p *Pipe) Close() error {
+	p.closeOnce.Do(func() { close(p.closed) })
+	return nil
+}
+
+// SetDrop sets the filter discarding outgoing packets; nil delivers them all.
+// Discarded packets are still recorded as sent.
+func (p *Pipe) SetDrop(drop func(*message.Envelope) bool) {
+	p.mu.Lock()
+	p.drop = drop
+	p.mu.Unlock()
+}
+
+// Incoming returns the packets sent by the peer, for tests that read the link below
+// the connection under test.
+func (p *Pipe) Incoming() <-chan *message.Envelope {
+	return p.in
+}
+
+// Inject delivers a packet to the peer as if it had been sent, bypassing the filter.
+func (p *Pipe) Inject(envelope *message.Envelope) {
+	p.out <- envelope
+}
+
+// LastSent returns the most recently sent packet, or nil if none was sent.
+func (p *Pipe) LastSent() *message.Envelope {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.sent) == 0 {
+		return nil
+	}
+	return p.sent[len(p.sent)-1]
+}
+
+// SentOfType returns the number of packets of the given type sent so far.
+func (p *Pipe) SentOfType(msgType message.MsgType) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, e := range p.sent {
+		if e.Header.Type == msgType {
+			n++
+		}
+	}
+	return n
+}
+
+// DropAll is a filter that discards every packet.
+func DropAll(*message.Envelope) bool {
+	return true
+}
+
+// DropFirst returns a filter that discards the first n packets of the given type.
+func DropFirst(msgType message.MsgType, n int) func(*message.Envelope) bool {
+	var count atomic.Int32
+	return func(e *message.Envelope) bool {
+		return e.Header.Type == msgType && int(count.Add(1)) <= n
+	}
+}
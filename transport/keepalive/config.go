@@ -0,0 +1,45 @@
+// Package keepalive provides opt-in heartbeats and dead-peer detection on top of any
+// transport.Connection. The wrapper sends a SensorHeartbeat whenever the connection has
+// been idle for an interval, tracks the time of the last inbound message, and closes
+// the connection once the peer has been silent for a number of intervals. Closing the
+// link makes a reconnect.Connection built on a wrapped transport dial again.
+package keepalive
+
+import (
+	"kinetica-protocol/protocol/message"
+	"time"
+)
+
+// Keepalive defaults.
+const (
+	DefaultInterval         = 5 * time.Second // Idle time before a heartbeat is sent
+	DefaultMissedHeartbeats = 3               // Silent intervals before the peer is declared dead
+	DefaultQueueSize        = 64              // Incoming messages buffered for Receive
+)
+
+// Config defines keepalive parameters. Zero values are replaced with defaults.
+// Interval and MissedHeartbeats should match the peer's registry configuration. A server
+// typically runs Passive so that it only answers the heartbeats its clients send.
+type Config struct {
+	SensorID         uint8                           // SensorID placed in outgoing heartbeats
+	Interval         time.Duration                   // Idle time before a heartbeat is sent
+	MissedHeartbeats int                             // Silent intervals before the peer is declared dead
+	Passive          bool                            // Answer the peer's heartbeats instead of sending them when idle
+	Heartbeat        func() *message.SensorHeartbeat // Builds each outgoing heartbeat (nil for a default with Status Ok)
+	OnDead           func(err error)                 // Called once when the peer is declared dead, before the connection is closed
+	QueueSize        int                             // Incoming messages buffered for Receive
+}
+
+// withDefaults returns a copy of the configuration with zero values replaced by defaults.
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.MissedHeartbeats <= 0 {
+		c.MissedHeartbeats = DefaultMissedHeartbeats
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	return c
+}
@@ -0,0 +1,247 @@
+package keepalive
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Connection wraps a transport connection with heartbeats and dead-peer detection.
+// A background goroutine reads from the underlying connection, records inbound
+// traffic, and queues messages for Receive; another sends heartbeats while the
+// connection is idle and closes it when the peer falls silent.
+type Connection struct {
+	conn      transport.Connection    // Underlying transport connection
+	config    Config                  // Keepalive parameters
+	lastSeen  atomic.Int64            // Time of the last inbound message (Unix nanoseconds)
+	lastSent  atomic.Int64            // Time of the last outbound message (Unix nanoseconds)
+	dead      atomic.Pointer[error]   // Error recorded when the peer was declared dead
+	incoming  *transport.ReceiveQueue // Messages and errors queued for Receive
+	done      chan struct{}           // Closed when the connection is closed
+	closeOnce sync.Once               // Ensures Close runs once
+	now       func() time.Time        // Clock used for idle and silence checks
+}
+
+// NewConnection wraps an existing connection with keepalive and starts its read loop
+// and monitor. The underlying connection must not be read by anyone else afterwards.
+func NewConnection(conn transport.Connection, config Config) *Connection {
+	config = config.withDefaults()
+
+	done := make(chan struct{})
+	c := &Connection{
+		conn:     conn,
+		config:   config,
+		incoming: transport.NewReceiveQueue(config.QueueSize, done),
+		done:     done,
+		now:      time.Now,
+	}
+
+	now := c.now().UnixNano()
+	c.lastSeen.Store(now)
+	c.lastSent.Store(now)
+
+	go c.readLoop()
+	go c.monitor()
+
+	return c
+}
+
+// Send transmits the message and records outbound traffic.
+func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	return c.sent(c.conn.Send(msg, msgType))
+}
+
+// SendContext transmits the message like Send, giving up when ctx is canceled or its
+// deadline passes.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return c.sent(c.conn.SendContext(ctx, msg, msgType))
+}
+
+// SendPacket transmits the message like Send using the given PacketID.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	return c.sent(c.conn.SendPacket(msg, msgType, packetID))
+}
+
+// sent records outbound traffic after a successful send.
+func (c *Connection) sent(err error) error {
+	if err == nil {
+		c.lastSent.Store(c.now().UnixNano())
+	}
+	return err
+}
+
+// LastSeen returns the time the last message was received from the peer, or the time
+// the connection was wrapped if nothing has been received yet.
+func (c *Connection) LastSeen() time.Time {
+	return time.Unix(0, c.lastSeen.Load())
+}
+
+// Receive returns the next message delivered by the read loop.
+func (c *Connection) Receive() (message.Message, error) {
+	envelope, err := c.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveContext returns the next message like Receive, giving up when ctx is canceled
+// or its deadline passes.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	envelope, err := c.incoming.ReceiveContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveEnvelope returns the next message with its packet metadata. Heartbeats from
+// the peer are delivered like any other message; a Passive connection has already
+// answered them. Once the peer is declared dead it
+// returns an error wrapping both ErrPeerDead and transport.ErrConnectionClosed.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	return c.incoming.Receive()
+}
+
+// Stats returns the statistics of the underlying connection. Heartbeats are counted
+// as regular packets.
+func (c *Connection) Stats() transport.Stats {
+	return transport.StatsOf(c.conn)
+}
+
+// Flush writes packets buffered by the underlying connection.
+func (c *Connection) Flush() error {
+	return transport.Flush(c.conn)
+}
+
+// State returns the state of the underlying connection, or disconnected after Close.
+func (c *Connection) State() transport.ConnectionState {
+	select {
+	case <-c.done:
+		return transport.StateDisconnected
+	default:
+		return c.conn.State()
+	}
+}
+
+// Subscribe registers fn for the state transitions of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	unsubscribe, _ = transport.SubscribeState(c.conn, fn)
+	return unsubscribe
+}
+
+// ObserveCodec registers fn for the codec operations of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	remove, _ = transport.ObserveCodec(c.conn, fn)
+	return remove
+}
+
+// PeerIdentity returns the verified certificate of the underlying connection's peer.
+func (c *Connection) PeerIdentity() (*x509.Certificate, bool) {
+	return transport.PeerIdentityOf(c.conn)
+}
+
+// Close stops the monitor and read loop and closes the underlying connection.
+func (c *Connection) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// monitor sends heartbeats while the connection is idle and declares the peer dead
+// once it has been silent for MissedHeartbeats intervals.
+func (c *Connection) monitor() {
+	deadAfter := c.config.Interval * time.Duration(c.config.MissedHeartbeats)
+	timer := time.NewTimer(c.config.Interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-timer.C:
+		}
+
+		now := c.now()
+		silent := now.Sub(c.LastSeen())
+		if silent >= deadAfter {
+			c.declareDead(silent)
+			return
+		}
+		next := deadAfter - silent
+
+		if !c.config.Passive {
+			idle := now.Sub(time.Unix(0, c.lastSent.Load()))
+			if idle >= c.config.Interval {
+				c.heartbeat()
+				idle = 0
+			}
+			next = min(next, c.config.Interval-idle)
+		}
+
+		timer.Reset(next)
+	}
+}
+
+// heartbeat sends a SensorHeartbeat. Send errors are ignored because a failing link
+// is detected by the read loop or by the peer falling silent.
+func (c *Connection) heartbeat() {
+	var msg *message.SensorHeartbeat
+	if c.config.Heartbeat != nil {
+		msg = c.config.Heartbeat()
+	} else {
+		msg = &message.SensorHeartbeat{
+			SensorID:  c.config.SensorID,
+			TimeStamp: uint32(c.now().Unix()),
+			Status:    message.Ok,
+		}
+	}
+
+	_ = c.Send(msg, message.MsgTypeHeartbeat)
+}
+
+// declareDead records the peer as dead, reports it, and closes the underlying
+// connection so the read loop ends with the recorded error.
+func (c *Connection) declareDead(silent time.Duration) {
+	err := fmt.Errorf("%w: %w: no traffic for %v", transport.ErrConnectionClosed, ErrPeerDead, silent.Round(time.Millisecond))
+	c.dead.Store(&err)
+
+	if c.config.OnDead != nil {
+		c.config.OnDead(err)
+	}
+	_ = c.conn.Close()
+}
+
+// readLoop receives from the underlying connection until it fails or is closed.
+// A link closed after the peer was declared dead ends with the recorded error.
+func (c *Connection) readLoop() {
+	err := c.incoming.ReadLoop(c.conn, c.handle, transport.IsTerminal)
+	if dead := c.dead.Load(); err != nil && dead != nil {
+		err = *dead
+	}
+	if err == nil {
+		err = fmt.Errorf("%w: %w", transport.ErrConnectionClosed, ErrClosed)
+	}
+	c.incoming.Close(err)
+}
+
+// handle records inbound traffic, answers heartbeats in passive mode, and queues the
+// envelope for Receive, blocking until there is room. It reports false once the
+// connection is closed.
+func (c *Connection) handle(envelope *message.Envelope) bool {
+	c.lastSeen.Store(c.now().UnixNano())
+	if c.config.Passive && envelope.Header.Type == message.MsgTypeHeartbeat {
+		c.heartbeat()
+	}
+	return c.incoming.Deliver(envelope, nil)
+}
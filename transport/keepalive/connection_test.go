@@ -0,0 +1,322 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"kinetica-protocol/transport/internal/transporttest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockTransport hands out a prepared connection and its listener counterpart.
+type mockTransport struct {
+	conn     transport.Connection
+	accepted chan transport.Connection
+}
+
+func (m *mockTransport) Connection() (transport.Connection, error) {
+	return m.conn, nil
+}
+
+func (m *mockTransport) Listen() (<-chan transport.Connection, error) {
+	conns := make(chan transport.Connection, 1)
+	conns <- m.conn
+	close(conns)
+	m.accepted = conns
+	return conns, nil
+}
+
+func (m *mockTransport) Close() error {
+	return nil
+}
+
+func testConfig() Config {
+	return Config{SensorID: 7, Interval: 20 * time.Millisecond, MissedHeartbeats: 3}
+}
+
+func command() *message.SensorCommand {
+	return &message.SensorCommand{SensorID: 1, Command: 0x01}
+}
+
+func TestConnection_HeartbeatWhenIdle(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	conn := NewConnection(a, testConfig())
+	defer conn.Close()
+
+	select {
+	case envelope := <-b.Incoming():
+		hb, ok := envelope.Payload.(*message.SensorHeartbeat)
+		if !ok {
+			t.Fatalf("expected heartbeat, got %T", envelope.Payload)
+		}
+		if hb.SensorID != 7 || hb.Status != message.Ok {
+			t.Errorf("unexpected heartbeat: %+v", hb)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no heartbeat sent on an idle connection")
+	}
+}
+
+func TestConnection_CustomHeartbeat(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	config := testConfig()
+	config.Heartbeat = func() *message.SensorHeartbeat {
+		return &message.SensorHeartbeat{SensorID: 9, Battery: 80, Status: message.LowBattery}
+	}
+	conn := NewConnection(a, config)
+	defer conn.Close()
+
+	select {
+	case envelope := <-b.Incoming():
+		hb := envelope.Payload.(*message.SensorHeartbeat)
+		if hb.SensorID != 9 || hb.Battery != 80 || hb.Status != message.LowBattery {
+			t.Errorf("unexpected heartbeat: %+v", hb)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no heartbeat sent")
+	}
+}
+
+func TestConnection_TrafficSuppressesHeartbeats(t *testing.T) {
+	a, _ := transporttest.NewPipe()
+	config := testConfig()
+	config.MissedHeartbeats = 100
+	conn := NewConnection(a, config)
+	defer conn.Close()
+
+	deadline := time.Now().Add(150 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if err := conn.Send(command(), message.MsgTypeCommand); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n := a.SentOfType(message.MsgTypeHeartbeat); n != 0 {
+		t.Errorf("expected no heartbeats while sending, got %d", n)
+	}
+}
+
+func TestConnection_Passive(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	config := testConfig()
+	config.Passive = true
+	config.MissedHeartbeats = 100
+	conn := NewConnection(a, config)
+	defer conn.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	if n := a.SentOfType(message.MsgTypeHeartbeat); n != 0 {
+		t.Errorf("passive connection sent %d heartbeats", n)
+	}
+
+	if err := b.Send(&message.SensorHeartbeat{SensorID: 1, Status: message.Ok}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if _, err := conn.Receive(); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if n := a.SentOfType(message.MsgTypeHeartbeat); n != 1 {
+		t.Errorf("expected one heartbeat reply, got %d", n)
+	}
+}
+
+func TestConnection_PeerDead(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	b.SetDrop(transporttest.DropAll)
+
+	var deadErr atomic.Pointer[error]
+	config := testConfig()
+	config.OnDead = func(err error) { deadErr.Store(&err) }
+	conn := NewConnection(a, config)
+	defer conn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Receive()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrPeerDead) {
+			t.Errorf("expected ErrPeerDead, got %v", err)
+		}
+		if !errors.Is(err, transport.ErrConnectionClosed) {
+			t.Errorf("expected ErrConnectionClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("peer was not declared dead")
+	}
+
+	if p := deadErr.Load(); p == nil || !errors.Is(*p, ErrPeerDead) {
+		t.Error("OnDead not called with ErrPeerDead")
+	}
+	if a.State() != transport.StateDisconnected {
+		t.Error("underlying connection not closed")
+	}
+}
+
+func TestConnection_InboundTrafficKeepsAlive(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	config := testConfig()
+	config.Passive = true
+	conn := NewConnection(a, config)
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_ = b.Send(command(), message.MsgTypeCommand)
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, err := conn.ReceiveContext(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("ReceiveContext failed: %v", err)
+		}
+	}
+	close(stop)
+
+	if time.Since(conn.LastSeen()) > 100*time.Millisecond {
+		t.Errorf("LastSeen not updated: %v", conn.LastSeen())
+	}
+	if conn.State() != transport.StateConnected {
+		t.Error("connection closed despite inbound traffic")
+	}
+}
+
+func TestConnection_BothEnds(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	client := NewConnection(a, testConfig())
+	defer client.Close()
+	server := NewConnection(b, Config{Interval: 20 * time.Millisecond, Passive: true})
+	defer server.Close()
+
+	go func() {
+		for {
+			if _, err := server.Receive(); err != nil {
+				return
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		msg, err := client.ReceiveContext(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("client ReceiveContext failed: %v", err)
+		}
+		if _, ok := msg.(*message.SensorHeartbeat); !ok {
+			t.Fatalf("expected heartbeat reply, got %T", msg)
+		}
+	}
+	if server.State() != transport.StateConnected {
+		t.Error("server declared the client dead")
+	}
+}
+
+func TestConnection_Close(t *testing.T) {
+	a, _ := transporttest.NewPipe()
+	conn := NewConnection(a, testConfig())
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := conn.Receive(); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if conn.State() != transport.StateDisconnected {
+		t.Error("expected disconnected state after Close")
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+}
+
+func TestWrapTransport(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	tr := WrapTransport(&mockTransport{conn: a}, testConfig())
+
+	conn, err := tr.Connection()
+	if err != nil {
+		t.Fatalf("Connection failed: %v", err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*Connection); !ok {
+		t.Fatalf("expected keepalive connection, got %T", conn)
+	}
+
+	select {
+	case envelope := <-b.Incoming():
+		if envelope.Header.Type != message.MsgTypeHeartbeat {
+			t.Errorf("expected heartbeat, got type %v", envelope.Header.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no heartbeat from wrapped connection")
+	}
+
+	conns, err := tr.Listen()
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	accepted := <-conns
+	defer accepted.Close()
+	if _, ok := accepted.(*Connection); !ok {
+		t.Errorf("expected keepalive connection, got %T", accepted)
+	}
+}
+
+func TestTransport_CloseStopsListen(t *testing.T) {
+	a, _ := transporttest.NewPipe()
+	mock := &mockTransport{conn: a}
+	tr := WrapTransport(mock, testConfig())
+
+	conns, err := tr.Listen()
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(mock.accepted) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("accepted connection was not taken")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := tr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for a.State() != transport.StateDisconnected {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the connection nobody took to be closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case conn, ok := <-conns:
+		if ok {
+			_ = conn.Close()
+			t.Error("expected no connection after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Listen to stop after Close")
+	}
+}
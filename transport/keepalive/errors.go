@@ -0,0 +1,9 @@
+package keepalive
+
+import "errors"
+
+// Keepalive error definitions.
+var (
+	ErrPeerDead = errors.New("peer not responding")         // No inbound traffic for MissedHeartbeats intervals
+	ErrClosed   = errors.New("keepalive connection closed") // Connection was closed by the caller
+)
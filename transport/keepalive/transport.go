@@ -0,0 +1,76 @@
+package keepalive
+
+import (
+	"kinetica-protocol/transport"
+	"sync"
+)
+
+// Transport wraps a transport.Transport so every connection it establishes or accepts
+// has keepalive enabled. Passing it to reconnect.Dial replaces links whose peer has
+// gone silent.
+type Transport struct {
+	transport transport.Transport // Underlying transport
+	config    Config              // Keepalive parameters for every connection
+	done      chan struct{}       // Closed by Close to stop forwarding accepted connections
+	closeOnce sync.Once           // Ensures done is closed once
+}
+
+// WrapTransport enables keepalive with the given configuration on all connections of t.
+func WrapTransport(t transport.Transport, config Config) *Transport {
+	return &Transport{
+		transport: t,
+		config:    config,
+		done:      make(chan struct{}),
+	}
+}
+
+// Connection establishes a client connection with keepalive.
+func (t *Transport) Connection() (transport.Connection, error) {
+	conn, err := t.transport.Connection()
+	if err != nil {
+		return nil, err
+	}
+	return NewConnection(conn, t.config), nil
+}
+
+// Listen starts listening and delivers connections with keepalive until the underlying
+// transport stops or Close is called. A connection accepted but not yet taken from the
+// channel when Close is called is closed, which stops its heartbeats.
+func (t *Transport) Listen() (<-chan transport.Connection, error) {
+	conns, err := t.transport.Listen()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan transport.Connection)
+	go func() {
+		defer close(out)
+		for {
+			var conn transport.Connection
+			var ok bool
+			select {
+			case conn, ok = <-conns:
+				if !ok {
+					return
+				}
+			case <-t.done:
+				return
+			}
+
+			wrapped := NewConnection(conn, t.config)
+			select {
+			case out <- wrapped:
+			case <-t.done:
+				_ = wrapped.Close()
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Close stops delivering connections and closes the underlying transport.
+func (t *Transport) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return t.transport.Close()
+}
@@ -0,0 +1,103 @@
+package net
+
+import (
+	"sync"
+	"time"
+)
+
+// batchWriter coalesces encoded frames into a single write. A batch is written when
+// the next frame would exceed maxBytes, when the oldest frame has waited maxDelay,
+// or on an explicit flush. For UDP each batch becomes one datagram.
+type batchWriter struct {
+	mu       sync.Mutex                      // Guards all fields below
+	write    func(data []byte, frames []int) // Writes a batch; frames holds the size of each frame
+	buf      []byte                          // Pending frames
+	frames   []int                           // Sizes of the pending frames
+	maxBytes int                             // Largest batch written at once
+	maxDelay time.Duration                   // Longest time a frame waits before being written
+	timer    *time.Timer                     // Fires maxDelay after the first pending frame
+	err      error                           // Error of a timed flush, reported by the next call
+}
+
+// newBatchWriter creates a batch writer that passes full batches to write.
+func newBatchWriter(maxBytes int, maxDelay time.Duration, write func(data []byte, frames []int) error) *batchWriter {
+	b := &batchWriter{
+		maxBytes: maxBytes,
+		maxDelay: maxDelay,
+		buf:      make([]byte, 0, maxBytes),
+	}
+	b.write = func(data []byte, frames []int) {
+		if err := write(data, frames); err != nil && b.err == nil {
+			b.err = err
+		}
+	}
+	return b
+}
+
+// add appends a frame to the batch, writing the batch first if the frame does not fit.
+// Frames larger than maxBytes are written on their own. It returns the error of a
+// write that failed since the previous call.
+func (b *batchWriter) add(frame []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.buf)+len(frame) > b.maxBytes {
+		b.flushLocked()
+	}
+
+	if len(frame) >= b.maxBytes {
+		b.write(frame, []int{len(frame)})
+		return b.takeErr()
+	}
+
+	b.buf = append(b.buf, frame...)
+	b.frames = append(b.frames, len(frame))
+
+	if len(b.frames) == 1 {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.maxDelay, b.timedFlush)
+		} else {
+			b.timer.Reset(b.maxDelay)
+		}
+	}
+
+	return b.takeErr()
+}
+
+// flush writes the pending batch and returns the first write error since the previous call.
+func (b *batchWriter) flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushLocked()
+	return b.takeErr()
+}
+
+// timedFlush writes the pending batch once the oldest frame has waited maxDelay.
+func (b *batchWriter) timedFlush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushLocked()
+}
+
+// flushLocked writes the pending frames, if any. The caller holds mu.
+func (b *batchWriter) flushLocked() {
+	if len(b.frames) == 0 {
+		return
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+
+	b.write(b.buf, b.frames)
+	b.buf = b.buf[:0]
+	b.frames = b.frames[:0]
+}
+
+// takeErr returns and clears the stored write error. The caller holds mu.
+func (b *batchWriter) takeErr() error {
+	err := b.err
+	b.err = nil
+	return err
+}
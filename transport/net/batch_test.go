@@ -0,0 +1,108 @@
+package net
+
+import (
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"net"
+	"testing"
+	"time"
+)
+
+// batchedUDP returns a UDP client connection with batching and the raw socket it sends to.
+func batchedUDP(t *testing.T, delay time.Duration, maxBytes int) (*Connection, *net.UDPConn) {
+	t.Helper()
+
+	raw, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	t.Cleanup(func() { raw.Close() })
+
+	client := NewUDP(Config{Address: raw.LocalAddr().String(), BatchDelay: delay, BatchBytes: maxBytes})
+	conn, err := client.Connection()
+	if err != nil {
+		t.Fatalf("Connection() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.(*Connection), raw
+}
+
+// readDatagram reads one datagram and returns the frames it holds.
+func readDatagram(t *testing.T, raw *net.UDPConn, timeout time.Duration) [][]byte {
+	t.Helper()
+
+	buf := make([]byte, UDPMaxMessageSize)
+	_ = raw.SetReadDeadline(time.Now().Add(timeout))
+	n, _, err := raw.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("ReadFromUDP() error = %v", err)
+	}
+
+	frames, err := codec.SplitFrames(buf[:n], UDPTransportCRC, UDPMaxMessageSize)
+	if err != nil {
+		t.Fatalf("SplitFrames() error = %v", err)
+	}
+	return frames
+}
+
+func sendHeartbeats(t *testing.T, conn *Connection, count int) {
+	t.Helper()
+	for i := range count {
+		if err := conn.Send(&message.SensorHeartbeat{SensorID: uint8(i)}, message.MsgTypeHeartbeat); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+}
+
+func TestConnection_BatchFlush(t *testing.T) {
+	conn, raw := batchedUDP(t, time.Hour, 0)
+
+	sendHeartbeats(t, conn, 3)
+
+	if stats := conn.Stats(); stats.MessagesSent != 0 {
+		t.Fatalf("Expected no messages written before Flush, got %d", stats.MessagesSent)
+	}
+
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if frames := readDatagram(t, raw, time.Second); len(frames) != 3 {
+		t.Errorf("Expected 3 frames in one datagram, got %d", len(frames))
+	}
+	if stats := conn.Stats(); stats.MessagesSent != 3 {
+		t.Errorf("Expected 3 messages sent, got %d", stats.MessagesSent)
+	}
+}
+
+func TestConnection_BatchDelay(t *testing.T) {
+	conn, raw := batchedUDP(t, 20*time.Millisecond, 0)
+
+	sendHeartbeats(t, conn, 2)
+
+	if frames := readDatagram(t, raw, time.Second); len(frames) != 2 {
+		t.Errorf("Expected 2 frames after the batch delay, got %d", len(frames))
+	}
+}
+
+func TestConnection_BatchMaxBytes(t *testing.T) {
+	frame, err := codec.Marshal(&message.SensorHeartbeat{}, 1, message.MsgTypeHeartbeat, UDPTransportCRC)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	conn, raw := batchedUDP(t, time.Hour, 2*len(frame))
+
+	sendHeartbeats(t, conn, 3)
+
+	if frames := readDatagram(t, raw, time.Second); len(frames) != 2 {
+		t.Errorf("Expected a full batch of 2 frames, got %d", len(frames))
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if frames := readDatagram(t, raw, time.Second); len(frames) != 1 {
+		t.Errorf("Expected Close to flush 1 frame, got %d", len(frames))
+	}
+}
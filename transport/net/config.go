@@ -3,11 +3,55 @@
 // TCP uses no CRC validation (relying on TCP's built-in reliability), while UDP uses 8-bit CRC.
 package net
 
-import "time"
+import (
+	"crypto/tls"
+	"kinetica-protocol/protocol/message"
+	"time"
+)
+
+// Default UDP server limits applied when the corresponding Config fields are zero.
+const (
+	DefaultMaxPeers        = 1024            // Maximum concurrent UDP peers
+	DefaultPeerIdleTimeout = 2 * time.Minute // Inactivity after which a UDP peer is dropped
+)
+
+// DefaultHandshakeTimeout bounds the TLS handshake when Config.HandshakeTimeout is zero.
+const DefaultHandshakeTimeout = 10 * time.Second
 
 // Config defines network transport configuration parameters for TCP and UDP connections.
 type Config struct {
-	Address      string        // Network address to bind/connect (e.g., ":8080", "192.168.1.100:8080")
-	WriteTimeout time.Duration // Timeout for write operations (0 = no timeout)
-	ReadTimeout  time.Duration // Timeout for read operations (0 = no timeout)
+	Address          string               // Network address to bind/connect (e.g., ":8080", "192.168.1.100:8080")
+	WriteTimeout     time.Duration        // Timeout for write operations (0 = no timeout)
+	ReadTimeout      time.Duration        // Timeout for read operations (0 = no timeout)
+	MaxPeers         int                  // Maximum concurrent peers of a UDP server (0 = DefaultMaxPeers)
+	PeerIdleTimeout  time.Duration        // Inactivity before a UDP server peer is closed (0 = DefaultPeerIdleTimeout)
+	BatchDelay       time.Duration        // Longest time a frame waits to be batched with others (0 = no batching); a queued frame ignores its send context
+	BatchBytes       int                  // Largest batched write (0 or above the transport maximum = transport maximum)
+	TLSConfig        *tls.Config          // TLS settings for TCP; nil disables TLS. Set ClientAuth and ClientCAs on servers for mutual TLS
+	HandshakeTimeout time.Duration        // TLS handshake timeout (0 = DefaultHandshakeTimeout)
+	CRC              message.TransportCRC // Footer type (0 = TCPTransportCRC for TCP, UDPTransportCRC for UDP)
+	MACKeys          *message.MACKeyring  // Keys of HMAC footers, required when CRC is TransportHMAC64 or TransportHMAC128
+}
+
+// withDefaults returns a copy of the configuration with zero UDP server limits and
+// handshake timeout replaced by defaults.
+func (c Config) withDefaults() Config {
+	if c.MaxPeers <= 0 {
+		c.MaxPeers = DefaultMaxPeers
+	}
+	if c.PeerIdleTimeout <= 0 {
+		c.PeerIdleTimeout = DefaultPeerIdleTimeout
+	}
+	if c.HandshakeTimeout <= 0 {
+		c.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	return c
+}
+
+// transportCRC returns the configured footer type, or def if none is set.
+func (c Config) transportCRC(def message.TransportCRC) message.TransportCRC {
+	if c.CRC == 0 {
+		return def
+	}
+	return c.CRC
 }
@@ -19,27 +19,78 @@ import (
 type Connection struct {
 	conn           net.Conn                 // Underlying network connection
 	ctx            context.Context          // Context for operation cancellation
-	reader         *bufio.Reader            // Buffered reader for efficient message parsing
+	reader         *bufio.Reader            // Buffered reader for stream connections (nil for datagrams)
+	frames         frameReader              // Frame reader over the stream or datagrams
 	writeTimeout   time.Duration            // Timeout for write operations
 	readTimeout    time.Duration            // Timeout for read operations
 	packetID       atomic.Uint32            // Atomic counter for unique packet IDs
 	transportCRC   message.TransportCRC     // CRC type for this transport
+	macKeys        *message.MACKeyring      // Keys of HMAC footers (nil for none)
 	maxMessageSize int                      // Maximum message size for this transport
+	stats          transport.Counters       // Traffic and error counters
+	observers      transport.CodecObservers // Observers of encode and decode operations
+	batch          *batchWriter             // Send batch (nil when batching is disabled)
+	state          transport.StateTracker   // Connection state updated by I/O outcomes
+	stopWatch      func() bool              // Stops watching ctx for cancellation
+}
+
+// frameReader yields validated frames from the underlying connection.
+type frameReader interface {
+	ReadFrame() ([]byte, error)             // Returns the next frame with a valid header and footer
+	Skipped() uint64                        // Bytes discarded as invalid input
+	CRCErrors() uint64                      // Input rejected because a footer did not match
+	SetMACKeyring(keys *message.MACKeyring) // Sets the keys used to verify HMAC footers
 }
 
 // NewConnection creates a new network connection wrapper with protocol support.
 // It configures timeouts, CRC validation, and message size limits for the connection.
 func NewConnection(conn net.Conn, ctx context.Context, writeTimeout, readTimeout time.Duration, transportCRC message.TransportCRC, maxMessageSize int) *Connection {
-	return &Connection{
+	reader := bufio.NewReaderSize(conn, codec.FrameBufferSize(maxMessageSize))
+	c := &Connection{
 		conn:           conn,
 		ctx:            ctx,
-		reader:         bufio.NewReader(conn),
+		reader:         reader,
+		frames:         codec.NewFrameReader(reader, transportCRC, maxMessageSize),
 		writeTimeout:   writeTimeout,
 		readTimeout:    readTimeout,
 		packetID:       atomic.Uint32{},
 		transportCRC:   transportCRC,
 		maxMessageSize: maxMessageSize,
 	}
+	c.watch()
+	return c
+}
+
+// NewDatagramConnection creates a network connection wrapper for a datagram-oriented
+// net.Conn such as a UDP socket. Every datagram is parsed on its own as one or more
+// concatenated frames and dropped as a whole if any of them is invalid.
+func NewDatagramConnection(conn net.Conn, ctx context.Context, writeTimeout, readTimeout time.Duration, transportCRC message.TransportCRC, maxMessageSize int) *Connection {
+	c := &Connection{
+		conn:           conn,
+		ctx:            ctx,
+		frames:         newDatagramReader(conn, transportCRC, maxMessageSize),
+		writeTimeout:   writeTimeout,
+		readTimeout:    readTimeout,
+		transportCRC:   transportCRC,
+		maxMessageSize: maxMessageSize,
+	}
+	c.watch()
+	return c
+}
+
+// useMACKeys sets the keys used to compute and verify HMAC footers and returns c.
+// It must be called before the connection is used.
+func (c *Connection) useMACKeys(keys *message.MACKeyring) *Connection {
+	c.macKeys = keys
+	c.frames.SetMACKeyring(keys)
+	return c
+}
+
+// watch moves the connection to StateDisconnected when its context ends.
+func (c *Connection) watch() {
+	c.stopWatch = context.AfterFunc(c.ctx, func() {
+		c.state.Set(transport.StateDisconnected, c.ctx.Err())
+	})
 }
 
 // getNextPacketID generates a unique packet ID using atomic increment with wraparound.
@@ -49,13 +100,36 @@ func (c *Connection) getNextPacketID() uint8 {
 }
 
 // Send encodes and transmits a protocol message over the network connection.
-// It handles timeouts, validates message size, and manages partial writes.
+// A new PacketID is taken from the connection's counter. With batching enabled, Send
+// returns once the frame is queued and write errors are reported by a later Send or Flush.
 func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	return c.send(context.Background(), msg, msgType, c.getNextPacketID())
+}
+
+// SendContext encodes and transmits a protocol message like Send, aborting the write
+// when ctx is canceled or its deadline passes. The write timeout still applies.
+// With batching enabled, ctx is only checked before the frame is queued: a queued
+// frame is written with its batch even if ctx ends before then.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return c.send(ctx, msg, msgType, c.getNextPacketID())
+}
+
+// SendPacket encodes and transmits a protocol message over the network connection
+// using the given PacketID.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	return c.send(context.Background(), msg, msgType, packetID)
+}
+
+// send encodes and writes a message with the given PacketID, or adds it to the send
+// batch if batching is enabled. It validates the message size.
+func (c *Connection) send(ctx context.Context, msg message.Message, msgType message.MsgType, packetID uint8) error {
 	if msg == nil {
 		return fmt.Errorf("%w: message is nil", transport.ErrInvalidMessageSize)
 	}
 
-	binaryMsg, err := codec.Marshal(msg, c.getNextPacketID(), msgType, c.transportCRC)
+	start := time.Now()
+	binaryMsg, err := codec.MarshalKeyed(msg, packetID, msgType, c.transportCRC, c.macKeys)
+	c.observers.Notify(transport.CodecEncode, start, err)
 	if err != nil {
 		return fmt.Errorf("%w: failed to marshal message: %w", transport.ErrSendFailed, err)
 	}
@@ -64,114 +138,253 @@ func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
 		return fmt.Errorf("%w: message size %d exceeds maximum %d bytes", transport.ErrMsgLarge, len(binaryMsg), c.maxMessageSize)
 	}
 
-	select {
-	case <-c.ctx.Done():
-		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, c.ctx.Err())
-	default:
+	if err := c.contextErr(ctx); err != nil {
+		return err
 	}
 
-	if c.writeTimeout > 0 {
-		if err := c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
-			return fmt.Errorf("%w: failed to set write deadline: %w", transport.ErrSendFailed, err)
-		}
+	if c.batch != nil {
+		return c.batch.add(binaryMsg)
 	}
 
-	n, err := c.conn.Write(binaryMsg)
+	if err := c.write(ctx, binaryMsg); err != nil {
+		return err
+	}
+
+	c.stats.AddSent(len(binaryMsg))
+	return nil
+}
+
+// write writes data to the connection in one call.
+// It handles timeouts and cancellation and manages partial writes.
+func (c *Connection) write(ctx context.Context, data []byte) error {
+	if err := c.conn.SetWriteDeadline(deadline(ctx, c.writeTimeout)); err != nil {
+		return fmt.Errorf("%w: failed to set write deadline: %w", transport.ErrSendFailed, err)
+	}
+
+	stop := c.interruptOnDone(ctx, func() { _ = c.conn.SetWriteDeadline(time.Now()) })
+	n, err := c.conn.Write(data)
+	stop()
+
 	if err != nil {
+		if ctxErr := c.contextErr(ctx); ctxErr != nil {
+			return ctxErr
+		}
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
+			c.stats.AddTimeout()
 			return fmt.Errorf("%w: %w", transport.ErrWriteTimeout, err)
 		}
 		if errors.Is(err, net.ErrClosed) {
-			return fmt.Errorf("%w: %w", transport.ErrConnectionClosed, err)
+			err = fmt.Errorf("%w: %w", transport.ErrConnectionClosed, err)
+			c.state.Set(transport.StateDisconnected, err)
+			return err
 		}
-		return fmt.Errorf("%w: failed to write %d bytes: %w", transport.ErrSendFailed, len(binaryMsg), err)
+		err = fmt.Errorf("%w: failed to write %d bytes: %w", transport.ErrSendFailed, len(data), err)
+		c.linkError(err)
+		return err
 	}
 
-	if n != len(binaryMsg) {
-		return fmt.Errorf("%w: partial write: wrote %d of %d bytes", transport.ErrSendFailed, n, len(binaryMsg))
+	if n != len(data) {
+		return fmt.Errorf("%w: partial write: wrote %d of %d bytes", transport.ErrSendFailed, n, len(data))
 	}
 
 	return nil
 }
 
-// Receive reads and decodes a protocol message from the network connection.
-// It handles timeouts, validates message integrity, and manages partial reads.
-func (c *Connection) Receive() (message.Message, error) {
-	select {
-	case <-c.ctx.Done():
-		return nil, fmt.Errorf("%w: %w", transport.ErrContextCanceled, c.ctx.Err())
-	default:
+// writeBatch writes a batch of frames in one call and counts each frame as sent.
+func (c *Connection) writeBatch(data []byte, frames []int) error {
+	if err := c.write(context.Background(), data); err != nil {
+		return err
 	}
 
-	if c.readTimeout > 0 {
-		if err := c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
-			return nil, fmt.Errorf("%w: failed to set read deadline: %w", transport.ErrReceiveFailed, err)
-		}
+	for _, size := range frames {
+		c.stats.AddSent(size)
+	}
+	return nil
+}
+
+// enableBatching makes sends coalesce frames into writes of up to maxBytes (capped at
+// the maximum message size), delayed by at most maxDelay. A zero maxDelay leaves
+// batching disabled.
+func (c *Connection) enableBatching(maxDelay time.Duration, maxBytes int) *Connection {
+	if maxDelay <= 0 {
+		return c
+	}
+	if maxBytes <= 0 || maxBytes > c.maxMessageSize {
+		maxBytes = c.maxMessageSize
+	}
+
+	c.batch = newBatchWriter(maxBytes, maxDelay, c.writeBatch)
+	return c
+}
+
+// Flush writes frames waiting in the send batch. It returns the first write error
+// since the previous Send or Flush, including errors of batches written in the
+// background. Without batching it does nothing.
+func (c *Connection) Flush() error {
+	if c.batch == nil {
+		return nil
+	}
+	return c.batch.flush()
+}
+
+// Receive reads and decodes a protocol message, discarding the packet metadata.
+func (c *Connection) Receive() (message.Message, error) {
+	envelope, err := c.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
 	}
+	return envelope.Payload, nil
+}
 
-	headerBuf := make([]byte, message.HeaderSize)
-	n, err := io.ReadFull(c.reader, headerBuf)
+// ReceiveContext reads and decodes a protocol message like Receive, returning early
+// when ctx is canceled or its deadline passes. The read timeout still applies.
+// A partially received frame stays buffered for the next call.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	envelope, err := c.receive(ctx)
 	if err != nil {
-		if err == io.EOF {
-			return nil, fmt.Errorf("%w: connection closed by peer", transport.ErrConnectionClosed)
-		}
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			return nil, fmt.Errorf("%w: %w", transport.ErrReadTimeout, err)
-		}
-		return nil, fmt.Errorf("%w: failed to read header (%d/%d bytes): %w", transport.ErrReceiveFailed, n, message.HeaderSize, err)
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveEnvelope reads and decodes a protocol message from the network connection.
+// The returned envelope includes the packet header, footer, raw bytes, and receive time.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	return c.receive(context.Background())
+}
+
+// receive reads the next frame and decodes it into an envelope.
+// It handles timeouts and cancellation, validates message integrity, and manages partial reads.
+func (c *Connection) receive(ctx context.Context) (*message.Envelope, error) {
+	if err := c.contextErr(ctx); err != nil {
+		return nil, err
 	}
 
-	payloadLength := headerBuf[5]
-	if int(payloadLength) > c.maxMessageSize {
-		return nil, fmt.Errorf("%w: payload length %d exceeds maximum %d", transport.ErrMsgLarge, payloadLength, c.maxMessageSize)
+	if err := c.conn.SetReadDeadline(deadline(ctx, c.readTimeout)); err != nil {
+		return nil, fmt.Errorf("%w: failed to set read deadline: %w", transport.ErrReceiveFailed, err)
 	}
 
-	footerSize := message.GetFooterSize(c.transportCRC)
-	remainingSize := int(payloadLength) + footerSize
-	remainingBuf := make([]byte, remainingSize)
+	stop := c.interruptOnDone(ctx, func() { _ = c.conn.SetReadDeadline(time.Now()) })
+	frame, err := c.frames.ReadFrame()
+	stop()
 
-	n, err = io.ReadFull(c.reader, remainingBuf)
 	if err != nil {
-		if err == io.EOF {
-			return nil, fmt.Errorf("%w: connection closed while reading payload", transport.ErrConnectionClosed)
-		}
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			return nil, fmt.Errorf("%w: %w", transport.ErrReadTimeout, err)
+		if ctxErr := c.contextErr(ctx); ctxErr != nil {
+			return nil, ctxErr
 		}
-		return nil, fmt.Errorf("%w: failed to read payload (%d/%d bytes): %w", transport.ErrReceiveFailed, n, remainingSize, err)
+		return nil, c.readError(err)
 	}
 
-	fullMessage := append(headerBuf, remainingBuf...)
-	msg, err := codec.Unmarshal(fullMessage, c.transportCRC)
+	start := time.Now()
+	envelope, err := codec.UnmarshalFrameKeyed(frame, c.transportCRC, c.macKeys)
+	c.observers.Notify(transport.CodecDecode, start, err)
 	if err != nil {
+		c.stats.AddDecodeError()
 		return nil, fmt.Errorf("%w: failed to unmarshal message: %w", transport.ErrReceiveFailed, err)
 	}
 
-	return msg, nil
+	c.stats.AddReceived(len(frame), envelope.Header.PacketID)
+	envelope.ReceivedAt = time.Now()
+	return envelope, nil
 }
 
-// State returns the current connection state by checking context and connection health.
-func (c *Connection) State() transport.ConnectionState {
-	select {
-	case <-c.ctx.Done():
-		return transport.StateDisconnected
-	default:
+// contextErr returns ErrContextCanceled if either the call context or the
+// connection context is done, and nil otherwise. A passed ctx deadline counts as done
+// even before the context's own timer fires, since the I/O deadline may fire first.
+func (c *Connection) contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, context.DeadlineExceeded)
+	}
+	if err := c.ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+	return nil
+}
+
+// interruptOnDone runs interrupt once the call context or the connection context is done,
+// unblocking a pending read or write. The returned function cancels the arrangement.
+func (c *Connection) interruptOnDone(ctx context.Context, interrupt func()) (stop func()) {
+	stopCall := context.AfterFunc(ctx, interrupt)
+	stopConn := context.AfterFunc(c.ctx, interrupt)
+	return func() {
+		stopCall()
+		stopConn()
 	}
+}
 
-	_, err := c.reader.Peek(1)
-	if err == nil {
-		return transport.StateConnected
+// deadline returns the earlier of the context deadline and now plus timeout.
+// A zero time, meaning no deadline, is returned if neither is set.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var d time.Time
+	if timeout > 0 {
+		d = time.Now().Add(timeout)
 	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (d.IsZero() || ctxDeadline.Before(d)) {
+		d = ctxDeadline
+	}
+	return d
+}
 
+// readError maps a frame reader error to the matching transport error.
+func (c *Connection) readError(err error) error {
+	if err == io.EOF {
+		err = fmt.Errorf("%w: connection closed by peer", transport.ErrConnectionClosed)
+		c.state.Set(transport.StateDisconnected, err)
+		return err
+	}
+	if errors.Is(err, net.ErrClosed) {
+		err = fmt.Errorf("%w: %w", transport.ErrConnectionClosed, err)
+		c.state.Set(transport.StateDisconnected, err)
+		return err
+	}
 	var netErr net.Error
 	if errors.As(err, &netErr) && netErr.Timeout() {
-		return transport.StateConnected
+		c.stats.AddTimeout()
+		return fmt.Errorf("%w: %w", transport.ErrReadTimeout, err)
+	}
+	err = fmt.Errorf("%w: failed to read frame: %w", transport.ErrReceiveFailed, err)
+	c.linkError(err)
+	return err
+}
+
+// linkError records an unexpected I/O error. A stream cannot recover from one, so
+// the connection becomes disconnected; datagram sockets report transient errors,
+// such as an ICMP port unreachable from an absent peer, and stay connected.
+func (c *Connection) linkError(err error) {
+	if c.reader != nil {
+		c.state.Set(transport.StateDisconnected, err)
+	}
+}
+
+// SkippedBytes returns the number of received bytes discarded while resynchronizing
+// the stream after corrupt or misaligned input, or in dropped datagrams.
+func (c *Connection) SkippedBytes() uint64 {
+	return c.frames.Skipped()
+}
+
+// State returns the connection state. It is StateDisconnected once the context ends,
+// the connection is closed, or a read or write fails in a way the link cannot recover from.
+func (c *Connection) State() transport.ConnectionState {
+	if c.ctx.Err() != nil {
+		return transport.StateDisconnected
 	}
+	return c.state.State()
+}
+
+// Subscribe registers fn to be called on every state transition and returns a function
+// that removes it.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	return c.state.Subscribe(fn)
+}
 
-	return transport.StateDisconnected
+// ObserveCodec registers fn to be called after every encode and decode and returns a
+// function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	return c.observers.Observe(fn)
 }
 
 // RemoteAddr returns the remote network address of the connection.
@@ -184,12 +397,21 @@ func (c *Connection) LocalAddr() net.Addr {
 	return c.conn.LocalAddr()
 }
 
-// Stats returns connection statistics (currently not implemented).
-func (c *Connection) Stats() (bytesSent, bytesReceived, messagesSent, messagesReceived uint64) {
-	return 0, 0, 0, 0
+// Stats returns a snapshot of the connection's traffic and error counters.
+func (c *Connection) Stats() transport.Stats {
+	return c.stats.Snapshot(c.frames.CRCErrors(), c.frames.Skipped())
 }
 
-// Close terminates the network connection and releases resources.
+// Close writes any batched frames, then terminates the network connection and
+// releases resources.
 func (c *Connection) Close() error {
-	return c.conn.Close()
+	c.state.Set(transport.StateClosing, nil)
+	defer c.state.Set(transport.StateDisconnected, nil)
+	c.stopWatch()
+
+	flushErr := c.Flush()
+	if err := c.conn.Close(); err != nil {
+		return err
+	}
+	return flushErr
 }
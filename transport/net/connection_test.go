@@ -2,7 +2,9 @@ package net
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"kinetica-protocol/protocol/codec"
 	"kinetica-protocol/protocol/message"
 	"kinetica-protocol/transport"
 	"net"
@@ -279,9 +281,355 @@ func TestConnection_Stats(t *testing.T) {
 	ctx := context.Background()
 	conn := NewConnection(mock, ctx, 5*time.Second, 10*time.Second, message.TransportCRC8, 1024)
 
-	sentBytes, recvBytes, sentMsgs, recvMsgs := conn.Stats()
+	if stats := conn.Stats(); stats != (transport.Stats{}) {
+		t.Errorf("Expected all stats to be zero initially, got %+v", stats)
+	}
+}
+
+func TestConnection_Stats_Counting(t *testing.T) {
+	frame := func(packetID uint8) []byte {
+		data, err := codec.Marshal(&message.SensorHeartbeat{SensorID: 1, Status: message.Ok}, packetID, message.MsgTypeHeartbeat, message.TransportCRC8)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		return data
+	}
+
+	corrupt := frame(2)
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	var stream []byte
+	stream = append(stream, frame(1)...)
+	stream = append(stream, corrupt...)
+	stream = append(stream, frame(4)...)
+
+	mock := &mockNetConn{readData: stream}
+	conn := NewConnection(mock, context.Background(), 0, 0, message.TransportCRC8, 1024)
+
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Receive(); err != nil {
+			t.Fatalf("Receive() error = %v", err)
+		}
+	}
+	if err := conn.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	stats := conn.Stats()
+	if stats.MessagesReceived != 2 || stats.BytesReceived != uint64(2*len(corrupt)) {
+		t.Errorf("Expected 2 messages and %d bytes received, got %+v", 2*len(corrupt), stats)
+	}
+	if stats.MessagesSent != 1 || stats.BytesSent != uint64(len(mock.writeData)) {
+		t.Errorf("Expected 1 message and %d bytes sent, got %+v", len(mock.writeData), stats)
+	}
+	if stats.CRCErrors != 1 || stats.SkippedBytes != uint64(len(corrupt)) {
+		t.Errorf("Expected 1 CRC error and %d skipped bytes, got %+v", len(corrupt), stats)
+	}
+	if stats.PacketIDGaps != 2 {
+		t.Errorf("Expected 2 missing packets, got %d", stats.PacketIDGaps)
+	}
+}
+
+func TestConnection_ObserveCodec(t *testing.T) {
+	data, err := codec.Marshal(&message.SensorHeartbeat{SensorID: 1, Status: message.Ok}, 1, message.MsgTypeHeartbeat, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	conn := NewConnection(&mockNetConn{readData: data}, context.Background(), 0, 0, message.TransportCRC8, 1024)
+
+	var events []transport.CodecEvent
+	remove, ok := transport.ObserveCodec(conn, func(e transport.CodecEvent) { events = append(events, e) })
+	if !ok {
+		t.Fatal("Expected the connection to report codec operations")
+	}
+
+	if _, err := conn.Receive(); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if err := conn.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	remove()
+	if err := conn.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(events) != 2 || events[0].Op != transport.CodecDecode || events[1].Op != transport.CodecEncode {
+		t.Fatalf("Expected a decode and an encode event, got %+v", events)
+	}
+	if events[0].Err != nil || events[1].Err != nil {
+		t.Errorf("Expected successful codec operations, got %+v", events)
+	}
+}
+
+func TestConnection_Receive_V2Frame(t *testing.T) {
+	value := make([]byte, 200)
+	custom := &message.CustomData{
+		SensorID: 1,
+		DataType: message.CustomTypeBinary,
+		Data: []message.Item{
+			{Key: message.ConfigKeyCalibration, Length: 200, Value: value},
+			{Key: message.ConfigKeyCalibration, Length: 200, Value: value},
+		},
+	}
+
+	frame, err := codec.Marshal(custom, 1, message.MsgTypeCustom, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	mock := &mockNetConn{readData: frame}
+	conn := NewConnection(mock, context.Background(), 0, 0, message.TransportCRC8, TCPMaxMessageSize)
+
+	msg, err := conn.Receive()
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+
+	received, ok := msg.(*message.CustomData)
+	if !ok {
+		t.Fatalf("Expected CustomData, got %T", msg)
+	}
+	if len(received.Data) != 2 {
+		t.Errorf("Expected 2 items, got %d", len(received.Data))
+	}
+}
+
+// vendorMessage is an application-defined message carrying raw bytes.
+type vendorMessage struct {
+	Data []byte
+}
+
+func (*vendorMessage) MessageType() message.MsgType {
+	return message.MsgTypeVendorMax
+}
+
+func TestConnection_RegisteredType(t *testing.T) {
+	err := codec.Register(message.MsgTypeVendorMax, codec.TypeCodec{
+		New: func() message.Message { return &vendorMessage{} },
+		Append: func(dst []byte, msg message.Message) ([]byte, error) {
+			return append(dst, msg.(*vendorMessage).Data...), nil
+		},
+		Decode: func(payload []byte, msg message.Message) error {
+			msg.(*vendorMessage).Data = append([]byte(nil), payload...)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	defer codec.Unregister(message.MsgTypeVendorMax)
+
+	sender := &mockNetConn{}
+	conn := NewConnection(sender, context.Background(), 0, 0, message.TransportCRC8, 1024)
+	if err := conn.Send(&vendorMessage{Data: []byte("emg")}, message.MsgTypeVendorMax); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	conn = NewConnection(&mockNetConn{readData: sender.writeData}, context.Background(), 0, 0, message.TransportCRC8, 1024)
+	msg, err := conn.Receive()
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if received, ok := msg.(*vendorMessage); !ok || string(received.Data) != "emg" {
+		t.Errorf("Expected vendor message with data \"emg\", got %+v", msg)
+	}
+}
+
+func TestConnection_Receive_UnknownType(t *testing.T) {
+	err := codec.Register(message.MsgTypeVendorMax, codec.TypeCodec{
+		New: func() message.Message { return &vendorMessage{} },
+		Append: func(dst []byte, msg message.Message) ([]byte, error) {
+			return append(dst, msg.(*vendorMessage).Data...), nil
+		},
+		Decode: func(payload []byte, msg message.Message) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	unknown, err := codec.Marshal(&vendorMessage{Data: []byte("emg")}, 1, message.MsgTypeVendorMax, message.TransportCRC8)
+	codec.Unregister(message.MsgTypeVendorMax)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	heartbeat, err := codec.Marshal(&message.SensorHeartbeat{SensorID: 4, Status: message.Ok}, 2, message.MsgTypeHeartbeat, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	mock := &mockNetConn{readData: append(unknown, heartbeat...)}
+	conn := NewConnection(mock, context.Background(), 0, 0, message.TransportCRC8, 1024)
+
+	envelope, err := conn.ReceiveEnvelope()
+	if !errors.Is(err, transport.ErrReceiveFailed) || !errors.Is(err, codec.ErrUnknownMessageType) {
+		t.Fatalf("Expected ErrReceiveFailed wrapping ErrUnknownMessageType, got %v", err)
+	}
+	if envelope != nil {
+		t.Errorf("Expected no envelope for an unknown type, got %+v", envelope)
+	}
+
+	msg, err := conn.Receive()
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if _, ok := msg.(*message.SensorHeartbeat); !ok {
+		t.Errorf("Expected heartbeat after the unknown frame, got %T", msg)
+	}
+}
+
+func TestConnection_Receive_Resync(t *testing.T) {
+	frame, err := codec.Marshal(&message.SensorHeartbeat{SensorID: 4, Battery: 50, Status: message.Ok}, 1, message.MsgTypeHeartbeat, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	mock := &mockNetConn{readData: append([]byte{0xAA, 'K', 0x55}, frame...)}
+	conn := NewConnection(mock, context.Background(), 0, 0, message.TransportCRC8, 1024)
+
+	msg, err := conn.Receive()
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if hb, ok := msg.(*message.SensorHeartbeat); !ok || hb.SensorID != 4 {
+		t.Fatalf("Expected heartbeat from sensor 4, got %+v", msg)
+	}
+	if conn.SkippedBytes() != 3 {
+		t.Errorf("Expected 3 skipped bytes, got %d", conn.SkippedBytes())
+	}
+}
+
+func TestConnection_ReceiveEnvelope(t *testing.T) {
+	frame, err := codec.Marshal(&message.Registration{SensorID: 9, DeviceType: message.DeviceType6Axis}, 77, message.MsgTypeRegister, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	mock := &mockNetConn{readData: frame}
+	conn := NewConnection(mock, context.Background(), 0, 0, message.TransportCRC8, 1024)
+
+	before := time.Now()
+	envelope, err := conn.ReceiveEnvelope()
+	if err != nil {
+		t.Fatalf("ReceiveEnvelope() error = %v", err)
+	}
+
+	if envelope.Header.PacketID != 77 {
+		t.Errorf("Expected PacketID 77, got %d", envelope.Header.PacketID)
+	}
+	if envelope.Header.Type != message.MsgTypeRegister {
+		t.Errorf("Expected registration type, got %v", envelope.Header.Type)
+	}
+	if envelope.ReceivedAt.Before(before) {
+		t.Error("Expected ReceivedAt to be set")
+	}
+	if _, ok := envelope.Payload.(*message.Registration); !ok {
+		t.Errorf("Expected Registration payload, got %T", envelope.Payload)
+	}
+}
+
+func TestConnection_ReceiveContext_Canceled(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := NewConnection(client, context.Background(), 0, 0, message.TransportCRC8, 1024)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err := conn.ReceiveContext(ctx)
+	if !errors.Is(err, transport.ErrContextCanceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected ErrContextCanceled, got %v", err)
+	}
+
+	frame, err := codec.Marshal(&message.SensorHeartbeat{SensorID: 5}, 1, message.MsgTypeHeartbeat, message.TransportCRC8)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	go server.Write(frame)
+
+	msg, err := conn.ReceiveContext(context.Background())
+	if err != nil {
+		t.Fatalf("Expected connection to remain usable, got %v", err)
+	}
+	if hb, ok := msg.(*message.SensorHeartbeat); !ok || hb.SensorID != 5 {
+		t.Errorf("Expected heartbeat from sensor 5, got %+v", msg)
+	}
+}
+
+func TestConnection_ReceiveContext_Deadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := NewConnection(client, context.Background(), 0, time.Minute, message.TransportCRC8, 1024)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := conn.ReceiveContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestConnection_SendContext_Canceled(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := NewConnection(client, context.Background(), 0, 0, message.TransportCRC8, 1024)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	err := conn.SendContext(ctx, &message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat)
+	if !errors.Is(err, transport.ErrContextCanceled) {
+		t.Errorf("Expected ErrContextCanceled for a write nobody reads, got %v", err)
+	}
+}
+
+func TestConnection_StateTracksIO(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewConnection(client, context.Background(), time.Second, time.Second, message.TransportCRC8, 1024)
+
+	var changes []transport.StateChange
+	conn.Subscribe(func(c transport.StateChange) { changes = append(changes, c) })
+
+	if state := conn.State(); state != transport.StateConnected {
+		t.Fatalf("Expected StateConnected, got %v", state)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { _ = server.Close() })
+	if _, err := conn.Receive(); !errors.Is(err, transport.ErrConnectionClosed) {
+		t.Fatalf("Expected ErrConnectionClosed, got %v", err)
+	}
+
+	if state := conn.State(); state != transport.StateDisconnected {
+		t.Errorf("Expected StateDisconnected after EOF, got %v", state)
+	}
+	if len(changes) != 1 || changes[0].To != transport.StateDisconnected || !errors.Is(changes[0].Err, transport.ErrConnectionClosed) {
+		t.Errorf("Expected one transition to disconnected with its cause, got %+v", changes)
+	}
+
+	_ = conn.Close()
+	if len(changes) != 1 {
+		t.Errorf("Expected no transitions after disconnect, got %+v", changes)
+	}
+}
+
+func TestConnection_StateClose(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := NewConnection(client, context.Background(), time.Second, time.Second, message.TransportCRC8, 1024)
+
+	var states []transport.ConnectionState
+	conn.Subscribe(func(c transport.StateChange) { states = append(states, c.To) })
+
+	_ = conn.Close()
 
-	if sentBytes != 0 || recvBytes != 0 || sentMsgs != 0 || recvMsgs != 0 {
-		t.Error("Expected all stats to be zero initially")
+	if len(states) != 2 || states[0] != transport.StateClosing || states[1] != transport.StateDisconnected {
+		t.Errorf("Expected closing then disconnected, got %v", states)
 	}
 }
@@ -0,0 +1,78 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"net"
+	"sync/atomic"
+)
+
+// datagramReader extracts frames from a datagram connection. Each Read of the
+// underlying connection returns one whole datagram, which must consist of one or more
+// complete frames; a datagram that fails validation is dropped as a whole, so a short
+// or corrupt datagram never affects the next one.
+type datagramReader struct {
+	conn         net.Conn             // Datagram connection returning one datagram per Read
+	buf          []byte               // Receive buffer large enough for any datagram
+	frames       [][]byte             // Frames of the last datagram not yet returned
+	transportCRC message.TransportCRC // Footer type used to validate frames
+	keys         *message.MACKeyring  // Keys of HMAC footers (nil for none)
+	maxPayload   int                  // Largest payload length accepted
+	skipped      atomic.Uint64        // Bytes of dropped datagrams
+	crcErrors    atomic.Uint64        // Datagrams dropped because a footer did not match
+}
+
+// newDatagramReader creates a frame reader over a datagram connection.
+func newDatagramReader(conn net.Conn, transportCRC message.TransportCRC, maxPayload int) *datagramReader {
+	return &datagramReader{
+		conn:         conn,
+		buf:          make([]byte, maxDatagramSize),
+		transportCRC: transportCRC,
+		maxPayload:   maxPayload,
+	}
+}
+
+// ReadFrame returns the next frame, reading a new datagram once all frames of the
+// previous one have been returned. Invalid datagrams are dropped and counted.
+// Errors from the underlying connection are returned unchanged.
+func (r *datagramReader) ReadFrame() ([]byte, error) {
+	for len(r.frames) == 0 {
+		n, err := r.conn.Read(r.buf)
+		if err != nil {
+			return nil, err
+		}
+
+		datagram := bytes.Clone(r.buf[:n])
+		frames, err := codec.SplitFramesKeyed(datagram, r.transportCRC, r.maxPayload, r.keys)
+		if err != nil {
+			if errors.Is(err, codec.ErrInvalidFooter) || errors.Is(err, codec.ErrInvalidMAC) {
+				r.crcErrors.Add(1)
+			}
+			r.skipped.Add(uint64(n))
+			continue
+		}
+		r.frames = frames
+	}
+
+	frame := r.frames[0]
+	r.frames = r.frames[1:]
+	return frame, nil
+}
+
+// SetMACKeyring sets the keys used to verify HMAC footers.
+func (r *datagramReader) SetMACKeyring(keys *message.MACKeyring) {
+	r.keys = keys
+}
+
+// Skipped returns the total number of bytes in dropped datagrams.
+func (r *datagramReader) Skipped() uint64 {
+	return r.skipped.Load()
+}
+
+// CRCErrors returns the number of datagrams dropped because a frame footer, checksum
+// or HMAC, did not match.
+func (r *datagramReader) CRCErrors() uint64 {
+	return r.crcErrors.Load()
+}
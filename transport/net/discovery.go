@@ -0,0 +1,256 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default discovery settings applied when the corresponding DiscoveryConfig fields are zero.
+const (
+	DefaultDiscoveryInterval = 5 * time.Second // Time between discovery probes
+	DefaultDiscoveryExpiry   = 3               // Probe intervals without an answer before an endpoint is lost
+)
+
+// DiscoveryConfig defines how sensors are discovered on the local network.
+type DiscoveryConfig struct {
+	Address  string                  // Multicast group or broadcast address with port (e.g. "239.0.0.77:8090", "255.255.255.255:8090")
+	Interval time.Duration           // Time between probes (0 = DefaultDiscoveryInterval)
+	Expiry   time.Duration           // Time without an answer before an endpoint is lost (0 = DefaultDiscoveryExpiry intervals)
+	OnFound  func(endpoint Endpoint) // Called when a sensor answers for the first time or from a new address
+	OnLost   func(endpoint Endpoint) // Called when a sensor has not answered within Expiry
+}
+
+// withDefaults returns a copy of the configuration with zero values replaced by defaults.
+func (c DiscoveryConfig) withDefaults() DiscoveryConfig {
+	if c.Interval <= 0 {
+		c.Interval = DefaultDiscoveryInterval
+	}
+	if c.Expiry <= 0 {
+		c.Expiry = DefaultDiscoveryExpiry * c.Interval
+	}
+	return c
+}
+
+// Endpoint is a sensor found by discovery.
+type Endpoint struct {
+	Addr         *net.UDPAddr         // Address the sensor answered from
+	Registration message.Registration // Registration the sensor answered with
+	LastSeen     time.Time            // Time of the last answer
+}
+
+// Discovery periodically sends a discovery probe, a SensorCommand with CommandDiscover
+// addressed to BroadcastSensorID, to a multicast group or broadcast address. Sensors
+// answer with a Registration sent to the probe's source address, and Discovery keeps
+// the list of answering sensors and their addresses. Probes and answers use the footer
+// type configured for the transport.
+type Discovery struct {
+	transport *UDPTransport        // Transport whose configuration is used for connections
+	config    DiscoveryConfig      // Discovery settings with defaults applied
+	crc       message.TransportCRC // Footer type of probes and answers
+	macKeys   *message.MACKeyring  // Keys of HMAC footers of probes and answers
+	target    *net.UDPAddr         // Probe destination
+	conn      *net.UDPConn         // Socket sending probes and receiving answers
+	ctx       context.Context      // Canceled by Close or when the transport closes
+	cancel    context.CancelFunc   // Stops discovery
+	packetID  atomic.Uint32        // PacketID counter for probes
+	mu        sync.Mutex           // Guards endpoints
+	endpoints map[uint8]*Endpoint  // Discovered endpoints by SensorID
+	wg        sync.WaitGroup       // Tracks the probe and receive goroutines
+	now       func() time.Time     // Clock used for LastSeen and expiry
+}
+
+// Discover starts discovering sensors. A probe is sent immediately and then every
+// Interval until the Discovery or the transport is closed.
+func (t *UDPTransport) Discover(config DiscoveryConfig) (*Discovery, error) {
+	config = config.withDefaults()
+
+	target, err := net.ResolveUDPAddr("udp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve discovery address '%s': %w", config.Address, err)
+	}
+
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open discovery socket: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	d := &Discovery{
+		transport: t,
+		config:    config,
+		crc:       t.config.transportCRC(UDPTransportCRC),
+		macKeys:   t.config.MACKeys,
+		target:    target,
+		conn:      conn,
+		ctx:       ctx,
+		cancel:    cancel,
+		endpoints: make(map[uint8]*Endpoint),
+		now:       time.Now,
+	}
+
+	context.AfterFunc(ctx, func() { _ = conn.Close() })
+
+	d.wg.Add(2)
+	go d.receiveLoop()
+	go d.probeLoop()
+
+	return d, nil
+}
+
+// Probe sends a discovery probe immediately.
+func (d *Discovery) Probe() error {
+	probe := &message.SensorCommand{
+		SensorID:  message.BroadcastSensorID,
+		TimeStamp: uint32(d.now().Unix()),
+		Command:   message.CommandDiscover,
+	}
+
+	data, err := codec.MarshalKeyed(probe, uint8(d.packetID.Add(1)), message.MsgTypeCommand, d.crc, d.macKeys)
+	if err != nil {
+		return fmt.Errorf("%w: failed to marshal probe: %w", transport.ErrSendFailed, err)
+	}
+
+	if _, err := d.conn.WriteToUDP(data, d.target); err != nil {
+		return fmt.Errorf("%w: failed to send probe to %s: %w", transport.ErrSendFailed, d.target, err)
+	}
+	return nil
+}
+
+// Endpoints returns the discovered endpoints ordered by SensorID.
+func (d *Discovery) Endpoints() []Endpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	endpoints := make([]Endpoint, 0, len(d.endpoints))
+	for _, e := range d.endpoints {
+		endpoints = append(endpoints, *e)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Registration.SensorID < endpoints[j].Registration.SensorID
+	})
+	return endpoints
+}
+
+// Endpoint returns the discovered endpoint of a sensor.
+func (d *Discovery) Endpoint(sensorID uint8) (Endpoint, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.endpoints[sensorID]
+	if !ok {
+		return Endpoint{}, false
+	}
+	return *e, true
+}
+
+// Connection opens a UDP connection to a discovered sensor using the transport configuration.
+func (d *Discovery) Connection(sensorID uint8) (transport.Connection, error) {
+	e, ok := d.Endpoint(sensorID)
+	if !ok {
+		return nil, fmt.Errorf("%w: sensor %d", ErrUnknownEndpoint, sensorID)
+	}
+	return d.transport.dial(e.Addr)
+}
+
+// Close stops discovery and releases its socket. Connections opened to discovered
+// endpoints are not affected.
+func (d *Discovery) Close() error {
+	d.cancel()
+	d.wg.Wait()
+	return nil
+}
+
+// probeLoop sends probes and expires silent endpoints until discovery stops.
+func (d *Discovery) probeLoop() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		_ = d.Probe()
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			d.expire()
+		}
+	}
+}
+
+// receiveLoop records Registration answers until the socket is closed.
+// Datagrams that fail validation and other message types are ignored.
+func (d *Discovery) receiveLoop() {
+	defer d.wg.Done()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			if d.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		frames, err := codec.SplitFramesKeyed(buf[:n], d.crc, UDPMaxMessageSize, d.macKeys)
+		if err != nil {
+			continue
+		}
+
+		for _, frame := range frames {
+			envelope, err := codec.UnmarshalFrameKeyed(frame, d.crc, d.macKeys)
+			if err != nil {
+				continue
+			}
+			if reg, ok := envelope.Payload.(*message.Registration); ok {
+				d.found(*reg, addr)
+			}
+		}
+	}
+}
+
+// found records an answer and reports new endpoints and address changes.
+func (d *Discovery) found(reg message.Registration, addr *net.UDPAddr) {
+	d.mu.Lock()
+	e, known := d.endpoints[reg.SensorID]
+	moved := known && e.Addr.String() != addr.String()
+	e = &Endpoint{Addr: addr, Registration: reg, LastSeen: d.now()}
+	d.endpoints[reg.SensorID] = e
+	snapshot := *e
+	d.mu.Unlock()
+
+	if (!known || moved) && d.config.OnFound != nil {
+		d.config.OnFound(snapshot)
+	}
+}
+
+// expire removes endpoints that have not answered within Expiry and reports them lost.
+func (d *Discovery) expire() {
+	now := d.now()
+
+	d.mu.Lock()
+	var lost []Endpoint
+	for id, e := range d.endpoints {
+		if now.Sub(e.LastSeen) >= d.config.Expiry {
+			lost = append(lost, *e)
+			delete(d.endpoints, id)
+		}
+	}
+	d.mu.Unlock()
+
+	if d.config.OnLost != nil {
+		for _, e := range lost {
+			d.config.OnLost(e)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package net
+
+import (
+	"errors"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeSensor answers discovery probes with a Registration and records other messages.
+type fakeSensor struct {
+	conn     *net.UDPConn
+	reg      message.Registration
+	crc      message.TransportCRC
+	received chan message.Message
+}
+
+func newFakeSensor(t *testing.T, sensorID uint8) *fakeSensor {
+	t.Helper()
+	return newFakeSensorCRC(t, sensorID, UDPTransportCRC)
+}
+
+func newFakeSensorCRC(t *testing.T, sensorID uint8, crc message.TransportCRC) *fakeSensor {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+
+	s := &fakeSensor{
+		conn:     conn,
+		reg:      message.Registration{SensorID: sensorID, DeviceType: message.DeviceType9Axis, FWVersion: 0x0102},
+		crc:      crc,
+		received: make(chan message.Message, 8),
+	}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSensor) serve() {
+	buf := make([]byte, UDPMaxMessageSize)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		msg, err := codec.Unmarshal(buf[:n], s.crc)
+		if err != nil {
+			continue
+		}
+
+		if cmd, ok := msg.(*message.SensorCommand); ok && cmd.Command == message.CommandDiscover && cmd.SensorID == message.BroadcastSensorID {
+			data, _ := codec.Marshal(&s.reg, 1, message.MsgTypeRegister, s.crc)
+			_, _ = s.conn.WriteToUDP(data, addr)
+			continue
+		}
+		s.received <- msg
+	}
+}
+
+func TestDiscovery_FindsSensor(t *testing.T) {
+	sensor := newFakeSensor(t, 42)
+
+	udpTransport := NewUDP(Config{})
+	defer udpTransport.Close()
+
+	found := make(chan Endpoint, 1)
+	discovery, err := udpTransport.Discover(DiscoveryConfig{
+		Address:  sensor.conn.LocalAddr().String(),
+		Interval: time.Hour,
+		OnFound:  func(e Endpoint) { found <- e },
+	})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	defer discovery.Close()
+
+	select {
+	case e := <-found:
+		if e.Registration != sensor.reg {
+			t.Errorf("Expected registration %+v, got %+v", sensor.reg, e.Registration)
+		}
+		if e.Addr.String() != sensor.conn.LocalAddr().String() {
+			t.Errorf("Expected address %v, got %v", sensor.conn.LocalAddr(), e.Addr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected sensor to be discovered")
+	}
+
+	if endpoints := discovery.Endpoints(); len(endpoints) != 1 || endpoints[0].Registration.SensorID != 42 {
+		t.Fatalf("Expected one endpoint for sensor 42, got %+v", endpoints)
+	}
+
+	conn, err := discovery.Connection(42)
+	if err != nil {
+		t.Fatalf("Connection() error = %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Send(&message.TimeSync{SensorID: 42, ServerTime: 1}, message.MsgTypeTimeSync); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	select {
+	case msg := <-sensor.received:
+		if _, ok := msg.(*message.TimeSync); !ok {
+			t.Errorf("Expected TimeSync at sensor, got %T", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected message at discovered sensor")
+	}
+
+	if _, err := discovery.Connection(7); !errors.Is(err, ErrUnknownEndpoint) {
+		t.Errorf("Expected ErrUnknownEndpoint, got %v", err)
+	}
+}
+
+func TestDiscovery_ConfiguredCRC(t *testing.T) {
+	sensor := newFakeSensorCRC(t, 9, message.TransportCRC32)
+
+	udpTransport := NewUDP(Config{CRC: message.TransportCRC32})
+	defer udpTransport.Close()
+
+	found := make(chan Endpoint, 1)
+	discovery, err := udpTransport.Discover(DiscoveryConfig{
+		Address:  sensor.conn.LocalAddr().String(),
+		Interval: time.Hour,
+		OnFound:  func(e Endpoint) { found <- e },
+	})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	defer discovery.Close()
+
+	select {
+	case e := <-found:
+		if e.Registration.SensorID != 9 {
+			t.Errorf("Expected sensor 9, got %+v", e.Registration)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected sensor to be discovered with the configured CRC")
+	}
+}
+
+func TestDiscovery_ExpiresSilentSensor(t *testing.T) {
+	sensor := newFakeSensor(t, 3)
+
+	udpTransport := NewUDP(Config{})
+	defer udpTransport.Close()
+
+	found := make(chan Endpoint, 1)
+	lost := make(chan Endpoint, 1)
+	discovery, err := udpTransport.Discover(DiscoveryConfig{
+		Address:  sensor.conn.LocalAddr().String(),
+		Interval: 20 * time.Millisecond,
+		Expiry:   50 * time.Millisecond,
+		OnFound:  func(e Endpoint) { found <- e },
+		OnLost:   func(e Endpoint) { lost <- e },
+	})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	defer discovery.Close()
+
+	select {
+	case <-found:
+	case <-time.After(time.Second):
+		t.Fatal("Expected sensor to be discovered")
+	}
+
+	sensor.conn.Close()
+
+	select {
+	case e := <-lost:
+		if e.Registration.SensorID != 3 {
+			t.Errorf("Expected sensor 3 to be lost, got %d", e.Registration.SensorID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected silent sensor to expire")
+	}
+
+	if endpoints := discovery.Endpoints(); len(endpoints) != 0 {
+		t.Errorf("Expected no endpoints, got %+v", endpoints)
+	}
+}
+
+func TestDiscovery_InvalidAddress(t *testing.T) {
+	udpTransport := NewUDP(Config{})
+	defer udpTransport.Close()
+
+	if _, err := udpTransport.Discover(DiscoveryConfig{Address: "invalid:address:format"}); err == nil {
+		t.Fatal("Expected error for invalid address")
+	}
+}
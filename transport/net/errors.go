@@ -0,0 +1,8 @@
+package net
+
+import "errors"
+
+// Network transport error definitions.
+var (
+	ErrUnknownEndpoint = errors.New("endpoint not discovered") // No discovered endpoint for the sensor
+)
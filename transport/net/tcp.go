@@ -2,11 +2,13 @@ package net
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"kinetica-protocol/protocol/message"
 	"kinetica-protocol/transport"
 	"net"
+	"sync"
 )
 
 // TCP transport constants for protocol configuration.
@@ -35,6 +37,7 @@ func NewTCP(config Config) *TCPTransport {
 }
 
 // Connection establishes a TCP client connection to the configured address.
+// With Config.TLSConfig set, the TLS handshake completes before the connection is returned.
 func (t *TCPTransport) Connection() (transport.Connection, error) {
 	tcpAddr, err := net.ResolveTCPAddr("tcp", t.config.Address)
 	if err != nil {
@@ -50,11 +53,21 @@ func (t *TCPTransport) Connection() (transport.Connection, error) {
 		return nil, fmt.Errorf("failed to connect to %s: %w", tcpAddr.String(), err)
 	}
 
-	return NewConnection(conn, t.ctx, t.config.WriteTimeout, t.config.ReadTimeout, TCPTransportCRC, TCPMaxMessageSize), nil
+	if t.config.TLSConfig == nil {
+		return t.newConnection(conn), nil
+	}
+
+	tlsConn := tls.Client(conn, clientTLSConfig(t.config.TLSConfig, t.config.Address))
+	if err := t.handshake(tlsConn); err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s failed: %w", tcpAddr.String(), err)
+	}
+	return t.newConnection(tlsConn), nil
 }
 
 // Listen starts a TCP server and returns a channel of incoming connections.
-// Each accepted connection is wrapped in a protocol Connection interface.
+// Each accepted connection is wrapped in a protocol Connection interface. With
+// Config.TLSConfig set, connections are delivered after a successful TLS handshake;
+// clients failing the handshake, including client-certificate verification, are dropped.
 func (t *TCPTransport) Listen() (<-chan transport.Connection, error) {
 	tcpAddr, err := net.ResolveTCPAddr("tcp", t.config.Address)
 	if err != nil {
@@ -70,7 +83,10 @@ func (t *TCPTransport) Listen() (<-chan transport.Connection, error) {
 	ch := make(chan transport.Connection)
 
 	go func() {
+		var handshakes sync.WaitGroup
 		defer close(ch)
+		defer handshakes.Wait()
+
 		for {
 			conn, err := listener.Accept()
 			if errors.Is(err, net.ErrClosed) {
@@ -79,13 +95,55 @@ func (t *TCPTransport) Listen() (<-chan transport.Connection, error) {
 			if err != nil {
 				continue
 			}
-			ch <- NewConnection(conn, t.ctx, t.config.WriteTimeout, t.config.ReadTimeout, TCPTransportCRC, TCPMaxMessageSize)
+
+			if t.config.TLSConfig == nil {
+				ch <- t.newConnection(conn)
+				continue
+			}
+
+			handshakes.Add(1)
+			go func() {
+				defer handshakes.Done()
+				t.accept(tls.Server(conn, t.config.TLSConfig), ch)
+			}()
 		}
 	}()
 
 	return ch, nil
 }
 
+// accept completes the server side of a TLS handshake and delivers the connection.
+// Connections failing the handshake are closed.
+func (t *TCPTransport) accept(conn *tls.Conn, ch chan<- transport.Connection) {
+	if err := t.handshake(conn); err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	select {
+	case ch <- t.newConnection(conn):
+	case <-t.ctx.Done():
+		_ = conn.Close()
+	}
+}
+
+// handshake runs the TLS handshake bounded by the handshake timeout and the transport context.
+func (t *TCPTransport) handshake(conn *tls.Conn) error {
+	ctx, cancel := context.WithTimeout(t.ctx, t.config.withDefaults().HandshakeTimeout)
+	defer cancel()
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	return nil
+}
+
+// newConnection wraps an established TCP or TLS connection.
+func (t *TCPTransport) newConnection(conn net.Conn) *Connection {
+	return NewConnection(conn, t.ctx, t.config.WriteTimeout, t.config.ReadTimeout, t.config.transportCRC(TCPTransportCRC), TCPMaxMessageSize).useMACKeys(t.config.MACKeys).enableBatching(t.config.BatchDelay, t.config.BatchBytes)
+}
+
 // Close shuts down the TCP transport and stops accepting new connections.
 func (t *TCPTransport) Close() error {
 	t.cancel()
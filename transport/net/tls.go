@@ -0,0 +1,57 @@
+package net
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+// clientTLSConfig returns the client TLS configuration for a connection to address.
+// If the configuration names no server, the host part of address is used so the
+// server certificate is verified against it.
+func clientTLSConfig(config *tls.Config, address string) *tls.Config {
+	if config.ServerName != "" || config.InsecureSkipVerify {
+		return config
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return config
+	}
+
+	config = config.Clone()
+	config.ServerName = host
+	return config
+}
+
+// TLSState returns the TLS connection state and true if the connection uses TLS.
+func (c *Connection) TLSState() (tls.ConnectionState, bool) {
+	tlsConn, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tlsConn.ConnectionState(), true
+}
+
+// PeerCertificates returns the certificate chain presented by the peer, leaf first.
+// It is empty for plain connections and for TLS servers that do not request client
+// certificates.
+func (c *Connection) PeerCertificates() []*x509.Certificate {
+	state, ok := c.TLSState()
+	if !ok {
+		return nil
+	}
+	return state.PeerCertificates
+}
+
+// PeerIdentity returns the peer's leaf certificate if the handshake verified it against
+// the configured roots, and false for plain connections, unverified certificates, and
+// TLS servers that do not request client certificates. Servers using mutual TLS can map
+// its subject alternative names or subject to a SensorID.
+func (c *Connection) PeerIdentity() (*x509.Certificate, bool) {
+	state, ok := c.TLSState()
+	if !ok || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return state.VerifiedChains[0][0], true
+}
@@ -0,0 +1,210 @@
+package net
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue creates a leaf certificate for the common name, valid for 127.0.0.1.
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// listenTLS starts a mutual-TLS TCP server and returns it with its address.
+func listenTLS(t *testing.T, ca *testCA) (*TCPTransport, <-chan transport.Connection, string) {
+	t.Helper()
+
+	server := NewTCP(Config{
+		Address: "127.0.0.1:0",
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{ca.issue(t, "server", x509.ExtKeyUsageServerAuth)},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    ca.pool,
+		},
+		HandshakeTimeout: time.Second,
+	})
+	ch, err := server.Listen()
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	return server, ch, server.listener.Addr().String()
+}
+
+func TestTCPTransport_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	_, ch, addr := listenTLS(t, ca)
+
+	client := NewTCP(Config{
+		Address: addr,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{ca.issue(t, "sensor-17", x509.ExtKeyUsageClientAuth)},
+			RootCAs:      ca.pool,
+		},
+		ReadTimeout: time.Second,
+	})
+	defer client.Close()
+
+	conn, err := client.Connection()
+	if err != nil {
+		t.Fatalf("Connection() error = %v", err)
+	}
+	defer conn.Close()
+
+	if cert, ok := transport.PeerIdentityOf(conn); !ok || cert.Subject.CommonName != "server" {
+		t.Errorf("Expected verified server identity, got %v", cert)
+	}
+
+	var serverConn *Connection
+	select {
+	case c := <-ch:
+		serverConn = c.(*Connection)
+	case <-time.After(time.Second):
+		t.Fatal("Expected accepted TLS connection")
+	}
+	defer serverConn.Close()
+
+	cert, ok := serverConn.PeerIdentity()
+	if !ok || cert.Subject.CommonName != "sensor-17" {
+		t.Fatalf("Expected verified client identity sensor-17, got %v", cert)
+	}
+	if len(cert.IPAddresses) != 1 || !cert.IPAddresses[0].Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected the certificate's IP SAN, got %v", cert.IPAddresses)
+	}
+	if state, ok := serverConn.TLSState(); !ok || !state.HandshakeComplete {
+		t.Error("Expected completed TLS handshake")
+	}
+
+	if err := conn.Send(&message.SensorHeartbeat{SensorID: 17}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	msg, err := serverConn.Receive()
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if hb, ok := msg.(*message.SensorHeartbeat); !ok || hb.SensorID != 17 {
+		t.Errorf("Expected heartbeat from sensor 17, got %+v", msg)
+	}
+}
+
+func TestTCPTransport_MutualTLS_RejectsClientWithoutCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	_, ch, addr := listenTLS(t, ca)
+
+	client := NewTCP(Config{
+		Address:     addr,
+		TLSConfig:   &tls.Config{RootCAs: ca.pool},
+		ReadTimeout: time.Second,
+	})
+	defer client.Close()
+
+	// With TLS 1.3 the client learns about the rejected certificate on its first read.
+	conn, err := client.Connection()
+	if err == nil {
+		defer conn.Close()
+		if _, err := conn.Receive(); err == nil {
+			t.Fatal("Expected server to reject client without certificate")
+		}
+	}
+
+	select {
+	case c := <-ch:
+		t.Fatalf("Expected no connection to be delivered, got %v", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTCPTransport_TLS_UntrustedServer(t *testing.T) {
+	_, _, addr := listenTLS(t, newTestCA(t))
+
+	client := NewTCP(Config{
+		Address:   addr,
+		TLSConfig: &tls.Config{RootCAs: newTestCA(t).pool},
+	})
+	defer client.Close()
+
+	if _, err := client.Connection(); err == nil {
+		t.Fatal("Expected handshake to fail for an untrusted server certificate")
+	}
+}
+
+func TestConnection_PeerIdentity_PlainTCP(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := NewConnection(client, t.Context(), 0, 0, TCPTransportCRC, TCPMaxMessageSize)
+	if _, ok := conn.TLSState(); ok {
+		t.Error("Expected no TLS state for a plain connection")
+	}
+	if cert, ok := conn.PeerIdentity(); ok {
+		t.Errorf("Expected no identity, got %v", cert)
+	}
+}
@@ -40,16 +40,25 @@ func (t *UDPTransport) Connection() (transport.Connection, error) {
 		return nil, fmt.Errorf("failed to resolve address '%s': %w", t.config.Address, err)
 	}
 
+	return t.dial(udpAddr)
+}
+
+// dial establishes a UDP client connection to the given address.
+func (t *UDPTransport) dial(udpAddr *net.UDPAddr) (transport.Connection, error) {
 	conn, err := net.DialUDP("udp", nil, udpAddr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to %s: %w", udpAddr.String(), err)
 	}
 
-	return NewConnection(conn, t.ctx, t.config.WriteTimeout, t.config.ReadTimeout, UDPTransportCRC, UDPMaxMessageSize), nil
+	return NewDatagramConnection(conn, t.ctx, t.config.WriteTimeout, t.config.ReadTimeout, t.config.transportCRC(UDPTransportCRC), UDPMaxMessageSize).useMACKeys(t.config.MACKeys).enableBatching(t.config.BatchDelay, t.config.BatchBytes), nil
 }
 
-// Listen creates a UDP server socket and returns a single connection for all clients.
-// Unlike TCP, UDP uses a single connection that handles all client communications.
+// Listen creates a UDP server socket and demultiplexes incoming datagrams by remote
+// address. The first valid datagram from a new address delivers a Connection for that
+// peer; datagrams with no valid frame never create one. Replies are sent back to the
+// peer's address. Peers are closed after
+// Config.PeerIdleTimeout without traffic, and datagrams from new addresses are dropped
+// while Config.MaxPeers peers are active.
 func (t *UDPTransport) Listen() (<-chan transport.Connection, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", t.config.Address)
 	if err != nil {
@@ -62,9 +71,11 @@ func (t *UDPTransport) Listen() (<-chan transport.Connection, error) {
 	}
 
 	t.conn = conn
-	ch := make(chan transport.Connection, 1)
-	ch <- NewConnection(conn, t.ctx, t.config.WriteTimeout, t.config.ReadTimeout, UDPTransportCRC, UDPMaxMessageSize)
-	close(ch)
+	listener := newUDPListener(conn, t.ctx, t.config.withDefaults())
+	ch := make(chan transport.Connection)
+
+	go listener.serve(ch)
+	go listener.expire()
 
 	return ch, nil
 }
@@ -0,0 +1,361 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/transport"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Internal limits of the UDP server demultiplexer.
+const (
+	maxDatagramSize  = 64 * 1024 // Receive buffer size, large enough for any UDP datagram
+	peerQueueSize    = 64        // Datagrams buffered per peer before new ones are dropped
+	minSweepInterval = time.Second
+)
+
+// udpListener reads datagrams from a server socket and routes them to one peerConn
+// per remote address. Replies from every peer are written to the shared socket.
+type udpListener struct {
+	conn    *net.UDPConn                 // Shared server socket
+	ctx     context.Context              // Transport lifecycle context
+	config  Config                       // Transport configuration with defaults applied
+	mu      sync.Mutex                   // Guards peers
+	peers   map[netip.AddrPort]*peerConn // Active peers by remote address
+	writeMu sync.Mutex                   // Serializes writes so per-peer write deadlines apply
+}
+
+// newUDPListener creates a demultiplexer over a bound server socket.
+func newUDPListener(conn *net.UDPConn, ctx context.Context, config Config) *udpListener {
+	return &udpListener{
+		conn:   conn,
+		ctx:    ctx,
+		config: config,
+		peers:  make(map[netip.AddrPort]*peerConn),
+	}
+}
+
+// serve reads datagrams until the socket is closed, delivering a new Connection on ch
+// for every previously unseen remote address that sends a valid datagram. Connections are handed off in the
+// background, so routing to known peers continues while the caller is not accepting.
+// It closes all peers and ch on return.
+func (l *udpListener) serve(ch chan<- transport.Connection) {
+	var handoffs sync.WaitGroup
+	defer close(ch)
+	defer handoffs.Wait()
+	defer l.closePeers()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := l.conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || l.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		peer, created := l.peer(addr, buf[:n])
+		if peer == nil {
+			continue
+		}
+		peer.deliver(bytes.Clone(buf[:n]))
+
+		if created {
+			conn := NewDatagramConnection(peer, l.ctx, l.config.WriteTimeout, l.config.ReadTimeout, l.config.transportCRC(UDPTransportCRC), UDPMaxMessageSize).useMACKeys(l.config.MACKeys).enableBatching(l.config.BatchDelay, l.config.BatchBytes)
+			handoffs.Add(1)
+			go func() {
+				defer handoffs.Done()
+				select {
+				case ch <- conn:
+				case <-l.ctx.Done():
+					_ = conn.Close()
+				}
+			}()
+		}
+	}
+}
+
+// peer returns the peer for addr, creating it if there is room and the datagram holds
+// valid frames, so that spoofed or garbage datagrams cannot use up MaxPeers. It returns
+// nil if the datagram is dropped and reports whether the peer was created. Only serve
+// creates peers.
+func (l *udpListener) peer(addr netip.AddrPort, datagram []byte) (*peerConn, bool) {
+	if p := l.lookup(addr); p != nil {
+		return p, false
+	}
+
+	crc := l.config.transportCRC(UDPTransportCRC)
+	if _, err := codec.SplitFramesKeyed(datagram, crc, UDPMaxMessageSize, l.config.MACKeys); err != nil {
+		return nil, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.peers) >= l.config.MaxPeers {
+		return nil, false
+	}
+
+	p := newPeerConn(l, addr)
+	l.peers[addr] = p
+	return p, true
+}
+
+// lookup returns the active peer for addr, or nil if there is none.
+func (l *udpListener) lookup(addr netip.AddrPort) *peerConn {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.peers[addr]
+}
+
+// remove forgets a closed peer so a later datagram from its address starts a new connection.
+func (l *udpListener) remove(p *peerConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.peers[p.addr] == p {
+		delete(l.peers, p.addr)
+	}
+}
+
+// closePeers closes every active peer.
+func (l *udpListener) closePeers() {
+	for _, p := range l.snapshot() {
+		_ = p.Close()
+	}
+}
+
+// snapshot returns the active peers.
+func (l *udpListener) snapshot() []*peerConn {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	peers := make([]*peerConn, 0, len(l.peers))
+	for _, p := range l.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+// expire closes peers that have not sent a datagram within the idle timeout
+// until the transport context ends.
+func (l *udpListener) expire() {
+	ticker := time.NewTicker(max(l.config.PeerIdleTimeout/2, minSweepInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, p := range l.snapshot() {
+				if now.Sub(p.lastActive()) >= l.config.PeerIdleTimeout {
+					_ = p.Close()
+				}
+			}
+		}
+	}
+}
+
+// writeTo sends a datagram to addr, applying the given write deadline.
+func (l *udpListener) writeTo(b []byte, addr netip.AddrPort, deadline time.Time) (int, error) {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+
+	if err := l.conn.SetWriteDeadline(deadline); err != nil {
+		return 0, err
+	}
+	return l.conn.WriteToUDPAddrPort(b, addr)
+}
+
+// peerConn is a virtual net.Conn for one remote address of a UDP server. Reads return
+// the datagrams routed to it by the listener; writes are sent to the peer with WriteTo.
+type peerConn struct {
+	listener      *udpListener   // Listener owning the shared socket
+	addr          netip.AddrPort // Remote address of the peer
+	datagrams     chan []byte    // Datagrams received from the peer
+	pending       []byte         // Unread remainder of the current datagram
+	readDeadline  *deadlineTimer // Read deadline signal
+	mu            sync.Mutex     // Guards writeDeadline
+	writeDeadline time.Time      // Deadline applied to writes
+	active        atomic.Int64   // Unix nanoseconds of the last received datagram
+	done          chan struct{}  // Closed when the peer is closed
+	closeOnce     sync.Once      // Ensures the peer is closed once
+}
+
+// newPeerConn creates a peer for addr.
+func newPeerConn(l *udpListener, addr netip.AddrPort) *peerConn {
+	p := &peerConn{
+		listener:     l,
+		addr:         addr,
+		datagrams:    make(chan []byte, peerQueueSize),
+		readDeadline: newDeadlineTimer(),
+		done:         make(chan struct{}),
+	}
+	p.active.Store(time.Now().UnixNano())
+	return p
+}
+
+// deliver queues a datagram for reading, dropping it if the queue is full.
+func (p *peerConn) deliver(datagram []byte) {
+	p.active.Store(time.Now().UnixNano())
+	select {
+	case p.datagrams <- datagram:
+	default:
+	}
+}
+
+// lastActive returns the time the last datagram was received from the peer.
+func (p *peerConn) lastActive() time.Time {
+	return time.Unix(0, p.active.Load())
+}
+
+// Read implements net.Conn, returning data from the peer's datagrams in arrival order.
+func (p *peerConn) Read(b []byte) (int, error) {
+	if len(p.pending) == 0 {
+		select {
+		case <-p.done:
+			return 0, net.ErrClosed
+		case <-p.readDeadline.expired():
+			return 0, os.ErrDeadlineExceeded
+		default:
+		}
+
+		select {
+		case datagram := <-p.datagrams:
+			p.pending = datagram
+		case <-p.done:
+			return 0, net.ErrClosed
+		case <-p.readDeadline.expired():
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+
+	n := copy(b, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+// Write implements net.Conn, sending b to the peer as one datagram.
+func (p *peerConn) Write(b []byte) (int, error) {
+	select {
+	case <-p.done:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	p.mu.Lock()
+	deadline := p.writeDeadline
+	p.mu.Unlock()
+
+	return p.listener.writeTo(b, p.addr, deadline)
+}
+
+// Close implements net.Conn. It detaches the peer from the listener without
+// closing the shared socket.
+func (p *peerConn) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.listener.remove(p)
+	})
+	return nil
+}
+
+// LocalAddr returns the address of the server socket.
+func (p *peerConn) LocalAddr() net.Addr {
+	return p.listener.conn.LocalAddr()
+}
+
+// RemoteAddr returns the address of the peer.
+func (p *peerConn) RemoteAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(p.addr)
+}
+
+// SetDeadline sets the read and write deadlines.
+func (p *peerConn) SetDeadline(t time.Time) error {
+	_ = p.SetReadDeadline(t)
+	return p.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline, interrupting a blocked Read if it has passed.
+func (p *peerConn) SetReadDeadline(t time.Time) error {
+	p.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline applied to subsequent writes.
+func (p *peerConn) SetWriteDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.writeDeadline = t
+	return nil
+}
+
+// deadlineTimer signals the expiry of a settable deadline through a channel.
+type deadlineTimer struct {
+	mu     sync.Mutex    // Guards timer and cancel
+	timer  *time.Timer   // Pending expiry timer
+	cancel chan struct{} // Closed once the deadline has passed
+}
+
+// newDeadlineTimer creates a timer without a deadline.
+func newDeadlineTimer() *deadlineTimer {
+	return &deadlineTimer{cancel: make(chan struct{})}
+}
+
+// set arms the deadline. A zero time clears it; a past time expires it immediately.
+func (d *deadlineTimer) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancel // Wait for the timer callback to close cancel
+	}
+	d.timer = nil
+
+	closed := isClosed(d.cancel)
+	if t.IsZero() {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		return
+	}
+
+	if dur := time.Until(t); dur > 0 {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		cancel := d.cancel
+		d.timer = time.AfterFunc(dur, func() { close(cancel) })
+		return
+	}
+
+	if !closed {
+		close(d.cancel)
+	}
+}
+
+// expired returns a channel that is closed once the deadline has passed.
+func (d *deadlineTimer) expired() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
+
+// isClosed reports whether ch is closed.
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
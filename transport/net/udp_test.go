@@ -1,6 +1,11 @@
 package net
 
 import (
+	"errors"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"net"
 	"testing"
 	"time"
 )
@@ -71,20 +76,226 @@ func TestUDPTransport_Listen(t *testing.T) {
 	if ch == nil {
 		t.Fatal("Expected non-nil channel")
 	}
-	
+
+	select {
+	case conn := <-ch:
+		t.Fatalf("Expected no connection before any datagram, got %v", conn)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	client := dialUDP(t, udpTransport)
+	defer client.Close()
+	if err := client.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
 	select {
 	case conn := <-ch:
 		if conn == nil {
 			t.Fatal("Expected non-nil connection")
 		}
 		conn.Close()
-	case <-time.After(100 * time.Millisecond):
+	case <-time.After(time.Second):
 		t.Fatal("Expected connection from channel")
 	}
 	
 	udpTransport.Close()
 }
 
+func TestUDPTransport_Listen_DemultiplexesPeers(t *testing.T) {
+	udpTransport := NewUDP(Config{Address: "127.0.0.1:0", ReadTimeout: time.Second})
+	defer udpTransport.Close()
+
+	ch, err := udpTransport.Listen()
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	clients := []*Connection{dialUDP(t, udpTransport), dialUDP(t, udpTransport)}
+	for i, client := range clients {
+		defer client.Close()
+		if err := client.Send(&message.SensorHeartbeat{SensorID: uint8(i + 1)}, message.MsgTypeHeartbeat); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	for range clients {
+		conn := acceptUDP(t, ch)
+		msg, err := conn.Receive()
+		if err != nil {
+			t.Fatalf("Receive() error = %v", err)
+		}
+		id := msg.(*message.SensorHeartbeat).SensorID
+
+		if err := conn.Send(&message.Ack{SensorID: id, Status: message.AckOK}, message.MsgTypeAck); err != nil {
+			t.Fatalf("reply error = %v", err)
+		}
+
+		reply, err := clients[id-1].Receive()
+		if err != nil {
+			t.Fatalf("client Receive() error = %v", err)
+		}
+		if ack := reply.(*message.Ack); ack.SensorID != id {
+			t.Errorf("client %d got reply for sensor %d", id, ack.SensorID)
+		}
+	}
+}
+
+func TestUDPTransport_Listen_PendingPeerDoesNotBlock(t *testing.T) {
+	udpTransport := NewUDP(Config{Address: "127.0.0.1:0", ReadTimeout: time.Second})
+	defer udpTransport.Close()
+
+	ch, err := udpTransport.Listen()
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	first := dialUDP(t, udpTransport)
+	defer first.Close()
+	_ = first.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat)
+	conn := acceptUDP(t, ch)
+	if _, err := conn.Receive(); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+
+	second := dialUDP(t, udpTransport)
+	defer second.Close()
+	_ = second.Send(&message.SensorHeartbeat{SensorID: 2}, message.MsgTypeHeartbeat)
+	time.Sleep(20 * time.Millisecond)
+
+	_ = first.Send(&message.SensorHeartbeat{SensorID: 1, Battery: 50}, message.MsgTypeHeartbeat)
+	msg, err := conn.Receive()
+	if err != nil {
+		t.Fatalf("Expected the accepted peer to keep receiving, got %v", err)
+	}
+	if hb := msg.(*message.SensorHeartbeat); hb.Battery != 50 {
+		t.Errorf("Unexpected heartbeat %+v", hb)
+	}
+
+	if _, err := acceptUDP(t, ch).Receive(); err != nil {
+		t.Errorf("Expected the pending peer to be delivered later, got %v", err)
+	}
+}
+
+func TestUDPTransport_Listen_MaxPeers(t *testing.T) {
+	udpTransport := NewUDP(Config{Address: "127.0.0.1:0", MaxPeers: 1})
+	defer udpTransport.Close()
+
+	ch, err := udpTransport.Listen()
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	first := dialUDP(t, udpTransport)
+	defer first.Close()
+	_ = first.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat)
+	conn := acceptUDP(t, ch)
+
+	second := dialUDP(t, udpTransport)
+	defer second.Close()
+	_ = second.Send(&message.SensorHeartbeat{SensorID: 2}, message.MsgTypeHeartbeat)
+
+	select {
+	case extra := <-ch:
+		t.Fatalf("Expected peer limit to drop new peer, got %v", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	conn.Close()
+	_ = second.Send(&message.SensorHeartbeat{SensorID: 2}, message.MsgTypeHeartbeat)
+	acceptUDP(t, ch)
+}
+
+func TestUDPTransport_Listen_InvalidDatagramsCreateNoPeer(t *testing.T) {
+	udpTransport := NewUDP(Config{Address: "127.0.0.1:0", MaxPeers: 1})
+	defer udpTransport.Close()
+
+	ch, err := udpTransport.Listen()
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	frame, err := codec.Marshal(&message.SensorHeartbeat{SensorID: 1}, 1, message.MsgTypeHeartbeat, UDPTransportCRC)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	frame[len(frame)-1] ^= 0xFF
+
+	spoofer, err := net.DialUDP("udp", nil, udpTransport.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("DialUDP failed: %v", err)
+	}
+	defer spoofer.Close()
+	for _, datagram := range [][]byte{[]byte("garbage"), frame} {
+		if _, err := spoofer.Write(datagram); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	select {
+	case extra := <-ch:
+		t.Fatalf("Expected invalid datagrams not to create a peer, got %v", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	client := dialUDP(t, udpTransport)
+	defer client.Close()
+	_ = client.Send(&message.SensorHeartbeat{SensorID: 2}, message.MsgTypeHeartbeat)
+	if _, err := acceptUDP(t, ch).Receive(); err != nil {
+		t.Errorf("Expected the sensor to get the free peer slot, got %v", err)
+	}
+}
+
+func TestUDPTransport_Listen_IdleTimeout(t *testing.T) {
+	udpTransport := NewUDP(Config{Address: "127.0.0.1:0", PeerIdleTimeout: 50 * time.Millisecond})
+	defer udpTransport.Close()
+
+	ch, err := udpTransport.Listen()
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	client := dialUDP(t, udpTransport)
+	defer client.Close()
+	_ = client.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat)
+
+	conn := acceptUDP(t, ch)
+	if _, err := conn.Receive(); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+
+	_, err = conn.Receive()
+	if !errors.Is(err, transport.ErrConnectionClosed) {
+		t.Fatalf("Expected ErrConnectionClosed after idle timeout, got %v", err)
+	}
+
+	_ = client.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat)
+	acceptUDP(t, ch)
+}
+
+// dialUDP connects a client to the listening transport.
+func dialUDP(t *testing.T, server *UDPTransport) *Connection {
+	t.Helper()
+	client := NewUDP(Config{Address: server.conn.LocalAddr().String(), ReadTimeout: time.Second})
+	conn, err := client.Connection()
+	if err != nil {
+		t.Fatalf("Connection() error = %v", err)
+	}
+	return conn.(*Connection)
+}
+
+// acceptUDP waits for the next peer connection.
+func acceptUDP(t *testing.T, ch <-chan transport.Connection) transport.Connection {
+	t.Helper()
+	select {
+	case conn := <-ch:
+		return conn
+	case <-time.After(time.Second):
+		t.Fatal("Expected peer connection")
+		return nil
+	}
+}
+
 func TestUDPTransport_Listen_InvalidAddress(t *testing.T) {
 	config := Config{
 		Address: "invalid:address:format",
@@ -175,4 +386,164 @@ func TestUDPTransport_AddressResolution(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestUDPConnection_DatagramFrames(t *testing.T) {
+	raw, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	defer raw.Close()
+
+	client := NewUDP(Config{Address: raw.LocalAddr().String(), ReadTimeout: time.Second})
+	defer client.Close()
+	conn, err := client.Connection()
+	if err != nil {
+		t.Fatalf("Connection() error = %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	buf := make([]byte, UDPMaxMessageSize)
+	_, addr, err := raw.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("ReadFromUDP() error = %v", err)
+	}
+
+	frame := func(id uint8) []byte {
+		data, err := codec.Marshal(&message.SensorHeartbeat{SensorID: id}, id, message.MsgTypeHeartbeat, UDPTransportCRC)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		return data
+	}
+
+	corrupt := frame(2)
+	corrupt[len(corrupt)-1] ^= 0xFF
+	short := frame(3)
+
+	datagrams := [][]byte{
+		append(frame(4), corrupt...),  // Dropped: second frame has a bad footer
+		short[:len(short)-1],          // Dropped: truncated frame
+		append(frame(5), 0x00),        // Dropped: trailing byte
+		append(frame(6), frame(7)...), // Two frames in one datagram
+		frame(8),
+	}
+	for _, d := range datagrams {
+		if _, err := raw.WriteToUDP(d, addr); err != nil {
+			t.Fatalf("WriteToUDP() error = %v", err)
+		}
+	}
+
+	for _, want := range []uint8{6, 7, 8} {
+		msg, err := conn.Receive()
+		if err != nil {
+			t.Fatalf("Receive() error = %v", err)
+		}
+		if got := msg.(*message.SensorHeartbeat).SensorID; got != want {
+			t.Errorf("Expected sensor %d, got %d", want, got)
+		}
+	}
+
+	stats := conn.(*Connection).Stats()
+	if stats.CRCErrors != 1 {
+		t.Errorf("Expected 1 CRC error, got %d", stats.CRCErrors)
+	}
+	if want := uint64(len(datagrams[0]) + len(datagrams[1]) + len(datagrams[2])); stats.SkippedBytes != want {
+		t.Errorf("Expected %d skipped bytes, got %d", want, stats.SkippedBytes)
+	}
+}
+
+func TestUDPConnection_ConfiguredCRC(t *testing.T) {
+	raw, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	defer raw.Close()
+
+	client := NewUDP(Config{Address: raw.LocalAddr().String(), ReadTimeout: time.Second, CRC: message.TransportCRC16Modbus})
+	defer client.Close()
+	conn, err := client.Connection()
+	if err != nil {
+		t.Fatalf("Connection() error = %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	buf := make([]byte, UDPMaxMessageSize)
+	n, addr, err := raw.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("ReadFromUDP() error = %v", err)
+	}
+	if _, err := codec.Unmarshal(buf[:n], message.TransportCRC16Modbus); err != nil {
+		t.Errorf("Expected a CRC-16/MODBUS footer: %v", err)
+	}
+
+	reply, err := codec.Marshal(&message.Ack{SensorID: 1, Status: message.AckOK}, 1, message.MsgTypeAck, message.TransportCRC16Modbus)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if _, err := raw.WriteToUDP(reply, addr); err != nil {
+		t.Fatalf("WriteToUDP() error = %v", err)
+	}
+	if _, err := conn.Receive(); err != nil {
+		t.Errorf("Receive() error = %v", err)
+	}
+}
+
+func TestUDPConnection_MACKeys(t *testing.T) {
+	raw, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	defer raw.Close()
+
+	keys := message.NewMACKeyring()
+	keys.Set(1, []byte("sensor-1-key"))
+	client := NewUDP(Config{Address: raw.LocalAddr().String(), ReadTimeout: time.Second, CRC: message.TransportHMAC64, MACKeys: keys})
+	defer client.Close()
+	conn, err := client.Connection()
+	if err != nil {
+		t.Fatalf("Connection() error = %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Send(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	buf := make([]byte, UDPMaxMessageSize)
+	n, addr, err := raw.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("ReadFromUDP() error = %v", err)
+	}
+	if _, err := codec.UnmarshalFrameKeyed(buf[:n], message.TransportHMAC64, keys); err != nil {
+		t.Errorf("Expected an HMAC footer under the sensor key: %v", err)
+	}
+
+	other := message.NewMACKeyring()
+	other.Set(1, []byte("wrong-key"))
+	forged, err := codec.MarshalKeyed(&message.Ack{SensorID: 1, Status: message.AckError}, 1, message.MsgTypeAck, message.TransportHMAC64, other)
+	if err != nil {
+		t.Fatalf("MarshalKeyed() error = %v", err)
+	}
+	reply, err := codec.MarshalKeyed(&message.Ack{SensorID: 1, Status: message.AckOK}, 2, message.MsgTypeAck, message.TransportHMAC64, keys)
+	if err != nil {
+		t.Fatalf("MarshalKeyed() error = %v", err)
+	}
+	for _, datagram := range [][]byte{forged, reply} {
+		if _, err := raw.WriteToUDP(datagram, addr); err != nil {
+			t.Fatalf("WriteToUDP() error = %v", err)
+		}
+	}
+
+	msg, err := conn.Receive()
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if ack, ok := msg.(*message.Ack); !ok || ack.Status != message.AckOK {
+		t.Errorf("Expected the authentic Ack, got %+v", msg)
+	}
+}
@@ -0,0 +1,135 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+)
+
+// received is a single result queued for Receive.
+type received struct {
+	envelope *message.Envelope // Received message with metadata (nil on error)
+	err      error             // Non-fatal receive error
+}
+
+// ReceiveQueue passes the messages and errors read by the read loop of a connection
+// wrapper to its Receive methods. The read loop queues results with Deliver, or runs
+// ReadLoop, and calls Close with the terminal error when it ends; Receive then drains
+// the queue and returns that error.
+type ReceiveQueue struct {
+	items chan received   // Results waiting for Receive
+	done  <-chan struct{} // Closed when the owner is closed
+	drop  bool            // Deliver fails instead of blocking when the queue is full
+	err   error           // Terminal error, set before items closes
+}
+
+// NewReceiveQueue creates a queue holding up to size results whose Deliver blocks until
+// there is room or done is closed.
+func NewReceiveQueue(size int, done <-chan struct{}) *ReceiveQueue {
+	return &ReceiveQueue{items: make(chan received, size), done: done}
+}
+
+// NewDroppingReceiveQueue creates a queue holding up to size results whose Deliver never
+// blocks, for read loops that must keep running while the caller is not receiving.
+func NewDroppingReceiveQueue(size int, done <-chan struct{}) *ReceiveQueue {
+	return &ReceiveQueue{items: make(chan received, size), done: done, drop: true}
+}
+
+// Deliver queues a message or a non-fatal error for Receive and reports whether it was
+// queued. It fails once done is closed, and when the queue is full if it drops results.
+func (q *ReceiveQueue) Deliver(envelope *message.Envelope, err error) bool {
+	if q.drop {
+		select {
+		case q.items <- received{envelope: envelope, err: err}:
+			return true
+		default:
+			return false
+		}
+	}
+
+	select {
+	case q.items <- received{envelope: envelope, err: err}:
+		return true
+	case <-q.done:
+		return false
+	}
+}
+
+// Close records the terminal error returned by Receive once the queue is drained.
+// Only the read loop calls it, once, after its last Deliver.
+func (q *ReceiveQueue) Close(err error) {
+	q.err = err
+	close(q.items)
+}
+
+// Receive returns the next queued message or error, blocking until one is available.
+// Once the queue is closed and drained it returns the terminal error.
+func (q *ReceiveQueue) Receive() (*message.Envelope, error) {
+	r, ok := <-q.items
+	if !ok {
+		return nil, q.err
+	}
+	return r.envelope, r.err
+}
+
+// ReceiveContext returns the next queued message or error like Receive, giving up when
+// ctx is canceled or its deadline passes.
+func (q *ReceiveQueue) ReceiveContext(ctx context.Context) (*message.Envelope, error) {
+	select {
+	case r, ok := <-q.items:
+		if !ok {
+			return nil, q.err
+		}
+		return r.envelope, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %w", ErrContextCanceled, ctx.Err())
+	}
+}
+
+// Len returns the number of results waiting for Receive.
+func (q *ReceiveQueue) Len() int {
+	return len(q.items)
+}
+
+// ReadLoop receives from conn, passing every message to handle and queueing every
+// receive error for which fatal reports false, until the link ends or the owner stops
+// it. It returns nil if done was closed, handle returned false, or a blocking Deliver
+// failed, and the error that ended the link otherwise. The caller closes the queue.
+func (q *ReceiveQueue) ReadLoop(conn Connection, handle func(*message.Envelope) bool, fatal func(error) bool) error {
+	for {
+		envelope, err := conn.ReceiveEnvelope()
+		if err != nil {
+			if q.stopped() {
+				return nil
+			}
+			if fatal(err) {
+				return err
+			}
+			if !q.Deliver(nil, err) && !q.drop {
+				return nil
+			}
+			continue
+		}
+
+		if !handle(envelope) {
+			return nil
+		}
+	}
+}
+
+// stopped reports whether done is closed.
+func (q *ReceiveQueue) stopped() bool {
+	select {
+	case <-q.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTerminal reports whether a receive error ends a connection: it was closed or its
+// context ended. Other receive errors concern a single message.
+func IsTerminal(err error) bool {
+	return errors.Is(err, ErrConnectionClosed) || errors.Is(err, ErrContextCanceled)
+}
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"testing"
+	"time"
+)
+
+// scriptedConnection returns scripted receive results, then ErrConnectionClosed.
+type scriptedConnection struct {
+	Connection
+	results []error
+}
+
+func (s *scriptedConnection) ReceiveEnvelope() (*message.Envelope, error) {
+	if len(s.results) == 0 {
+		return nil, ErrConnectionClosed
+	}
+	err := s.results[0]
+	s.results = s.results[1:]
+	if err != nil {
+		return nil, err
+	}
+	return &message.Envelope{Payload: &message.SensorHeartbeat{SensorID: 1}}, nil
+}
+
+func TestReceiveQueue_ReadLoop(t *testing.T) {
+	errBad := errors.New("bad frame")
+	conn := &scriptedConnection{results: []error{nil, errBad, nil}}
+	q := NewReceiveQueue(8, make(chan struct{}))
+
+	var handled int
+	err := q.ReadLoop(conn, func(e *message.Envelope) bool {
+		handled++
+		return q.Deliver(e, nil)
+	}, IsTerminal)
+	if !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("ReadLoop() error = %v, want ErrConnectionClosed", err)
+	}
+	q.Close(err)
+
+	if handled != 2 || q.Len() != 3 {
+		t.Errorf("Expected 2 messages and 3 results, got %d and %d", handled, q.Len())
+	}
+	for i, want := range []error{nil, errBad, nil, ErrConnectionClosed} {
+		if _, err := q.Receive(); !errors.Is(err, want) || (want == nil && err != nil) {
+			t.Errorf("Receive() %d error = %v, want %v", i, err, want)
+		}
+	}
+}
+
+func TestReceiveQueue_Stop(t *testing.T) {
+	done := make(chan struct{})
+	q := NewReceiveQueue(1, done)
+	if !q.Deliver(nil, errors.New("first")) {
+		t.Fatal("Expected room for the first result")
+	}
+
+	stopped := make(chan bool)
+	go func() { stopped <- q.Deliver(nil, errors.New("second")) }()
+	select {
+	case <-stopped:
+		t.Fatal("Deliver returned while the queue was full")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(done)
+	if <-stopped {
+		t.Error("Expected Deliver to fail once done is closed")
+	}
+
+	dropping := NewDroppingReceiveQueue(1, make(chan struct{}))
+	dropping.Deliver(nil, nil)
+	if dropping.Deliver(nil, nil) {
+		t.Error("Expected a full dropping queue to refuse results")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	empty := NewReceiveQueue(1, make(chan struct{}))
+	if _, err := empty.ReceiveContext(ctx); !errors.Is(err, ErrContextCanceled) {
+		t.Errorf("Expected ErrContextCanceled, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Package reconnect provides a client connection that survives link loss. It dials
+// through any transport.Transport, and when the link fails it redials with exponential
+// backoff and jitter, runs a hook on every new link so the client can register again,
+// and optionally queues outgoing messages while disconnected.
+package reconnect
+
+import (
+	"kinetica-protocol/transport"
+	"time"
+)
+
+// Reconnect defaults.
+const (
+	DefaultInitialBackoff = 100 * time.Millisecond // Wait before the first redial
+	DefaultMaxBackoff     = 30 * time.Second       // Upper bound of the wait between redials
+	DefaultMultiplier     = 2.0                    // Multiplier applied to the wait after each failed redial
+	DefaultJitter         = 0.2                    // Random fraction added to or subtracted from each wait
+	DefaultReceiveQueue   = 64                     // Incoming messages buffered for Receive
+)
+
+// Config defines reconnect parameters. Zero values are replaced with defaults.
+type Config struct {
+	InitialBackoff time.Duration // Wait before the first redial
+	MaxBackoff     time.Duration // Upper bound of the wait between redials
+	Multiplier     float64       // Multiplier applied to the wait after each failed redial
+	Jitter         float64       // Random fraction added to or subtracted from each wait (negative for none)
+	MaxAttempts    int           // Consecutive failed redials before giving up (0 for unlimited)
+	SendQueue      int           // Messages queued while disconnected (0 to fail sends instead)
+	ReceiveQueue   int           // Incoming messages buffered for Receive
+
+	OnConnect     func(conn transport.Connection) error            // Called on every new link before it is used; an error drops the link
+	OnStateChange func(state transport.ConnectionState, err error) // Called on every state transition with the error that caused it, in order and without locks held; it may call Close
+}
+
+// withDefaults returns a copy of the configuration with zero values replaced by defaults.
+func (c Config) withDefaults() Config {
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = DefaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	if c.MaxBackoff < c.InitialBackoff {
+		c.MaxBackoff = c.InitialBackoff
+	}
+	if c.Multiplier < 1 {
+		c.Multiplier = DefaultMultiplier
+	}
+	if c.Jitter == 0 {
+		c.Jitter = DefaultJitter
+	} else if c.Jitter < 0 {
+		c.Jitter = 0
+	}
+	c.Jitter = min(c.Jitter, 1)
+	if c.MaxAttempts < 0 {
+		c.MaxAttempts = 0
+	}
+	if c.SendQueue < 0 {
+		c.SendQueue = 0
+	}
+	if c.ReceiveQueue <= 0 {
+		c.ReceiveQueue = DefaultReceiveQueue
+	}
+	return c
+}
+
+// backoff returns the wait before redial attempt n (starting at 0) for a random value r
+// in [0, 1).
+func (c Config) backoff(n int, r float64) time.Duration {
+	d := float64(c.InitialBackoff)
+	for i := 0; i < n && d < float64(c.MaxBackoff); i++ {
+		d *= c.Multiplier
+	}
+	d = min(d, float64(c.MaxBackoff))
+	d *= 1 + c.Jitter*(2*r-1)
+	return time.Duration(d)
+}
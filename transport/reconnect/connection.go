@@ -0,0 +1,428 @@
+package reconnect
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// pending is a message queued while the link is down.
+type pending struct {
+	msg      message.Message // Message to send
+	msgType  message.MsgType // Message type
+	packetID uint8           // PacketID requested with SendPacket
+	explicit bool            // Whether packetID was requested by the caller
+}
+
+// Connection is a client connection that redials its transport whenever the link is lost.
+// A background goroutine reads from the current link and queues messages for Receive;
+// when the link fails it moves to StateReconnecting, waits with exponential backoff and
+// jitter, and dials again. Receive blocks across reconnects and only reports the loss of
+// the link through state notifications, until the connection is closed or gives up, which
+// leaves it in StateDisconnected.
+type Connection struct {
+	transport transport.Transport      // Transport used to dial new links
+	config    Config                   // Reconnect parameters
+	sendMu    sync.Mutex               // Serializes sends with the flush of queued messages; guards queue
+	queue     []pending                // Messages queued while the link is down
+	mu        sync.Mutex               // Guards conn, unobserve, stats, and err
+	conn      transport.Connection     // Current link (nil while disconnected)
+	unobserve func()                   // Stops forwarding codec events of the current link
+	observers transport.CodecObservers // Observers of the codec operations of all links
+	stats     transport.Stats          // Accumulated counters of links that have ended
+	err       error                    // Terminal error, set before incoming closes
+	state     *transport.StateTracker  // Connecting, connected, reconnecting, closing, or disconnected
+	incoming  *transport.ReceiveQueue  // Messages and errors queued for Receive
+	done      chan struct{}            // Closed when the connection is closed
+	closeOnce sync.Once                // Ensures Close runs once
+	random    func() float64           // Source of backoff jitter in [0, 1)
+}
+
+// Dial establishes the first link through the transport and returns a connection that
+// keeps it alive. The first attempt is not retried: if it or the OnConnect hook fails,
+// Dial returns the error.
+func Dial(t transport.Transport, config Config) (*Connection, error) {
+	config = config.withDefaults()
+
+	done := make(chan struct{})
+	c := &Connection{
+		transport: t,
+		config:    config,
+		incoming:  transport.NewReceiveQueue(config.ReceiveQueue, done),
+		done:      done,
+		random:    rand.Float64,
+		state:     transport.NewStateTracker(transport.StateConnecting),
+	}
+
+	if config.OnStateChange != nil {
+		c.state.Subscribe(func(change transport.StateChange) {
+			config.OnStateChange(change.To, change.Err)
+		})
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	c.attach(conn)
+
+	go c.run(conn)
+
+	return c, nil
+}
+
+// Send transmits the message on the current link, or queues it while the link is down.
+func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	return c.send(context.Background(), pending{msg: msg, msgType: msgType})
+}
+
+// SendContext transmits the message like Send, giving up when ctx is canceled or its
+// deadline passes. Queued messages are not affected by ctx once queued.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return c.send(ctx, pending{msg: msg, msgType: msgType})
+}
+
+// SendPacket transmits the message like Send using the given PacketID.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	return c.send(context.Background(), pending{msg: msg, msgType: msgType, packetID: packetID, explicit: true})
+}
+
+// send transmits p on the current link or queues it. A send failing because the link
+// is lost closes the link so that the read loop reconnects; the message is not queued.
+func (c *Connection) send(ctx context.Context, p pending) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.Lock()
+	conn, err := c.conn, c.err
+	c.mu.Unlock()
+
+	switch {
+	case err != nil:
+		return err
+	case conn == nil:
+		return c.enqueue(p)
+	}
+
+	if err := write(ctx, conn, p); err != nil {
+		if linkLost(conn, err) {
+			_ = conn.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// write transmits a message on a link.
+func write(ctx context.Context, conn transport.Connection, p pending) error {
+	if p.explicit {
+		return conn.SendPacket(p.msg, p.msgType, p.packetID)
+	}
+	return conn.SendContext(ctx, p.msg, p.msgType)
+}
+
+// enqueue queues a message until the link is back. The caller holds sendMu.
+func (c *Connection) enqueue(p pending) error {
+	if c.config.SendQueue == 0 {
+		return fmt.Errorf("%w: %w", transport.ErrSendFailed, ErrDisconnected)
+	}
+	if len(c.queue) >= c.config.SendQueue {
+		return fmt.Errorf("%w: %w: %d messages", transport.ErrSendFailed, ErrQueueFull, len(c.queue))
+	}
+	c.queue = append(c.queue, p)
+	return nil
+}
+
+// Receive returns the next message received on any link.
+func (c *Connection) Receive() (message.Message, error) {
+	envelope, err := c.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveContext returns the next message like Receive, giving up when ctx is canceled
+// or its deadline passes.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	envelope, err := c.incoming.ReceiveContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveEnvelope returns the next message with its packet metadata. Errors that end a
+// link are not returned; after Close or once reconnecting gives up, it returns an error
+// wrapping transport.ErrConnectionClosed.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	return c.incoming.Receive()
+}
+
+// Stats returns the counters of the current link added to those of all earlier links.
+func (c *Connection) Stats() transport.Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return c.stats
+	}
+	return c.stats.Add(transport.StatsOf(c.conn))
+}
+
+// Flush writes packets buffered by the current link.
+func (c *Connection) Flush() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return transport.Flush(conn)
+}
+
+// State returns StateConnected while a link is up, StateReconnecting while the link is
+// being re-established, and StateDisconnected once the connection is closed or gave up.
+func (c *Connection) State() transport.ConnectionState {
+	return c.state.State()
+}
+
+// Subscribe registers fn to be called on every state transition and returns a function
+// that removes it. Transitions of the individual links are not reported.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	return c.state.Subscribe(fn)
+}
+
+// ObserveCodec registers fn for the codec operations of the current and all later
+// links and returns a function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	return c.observers.Observe(fn)
+}
+
+// PeerIdentity returns the verified certificate of the current link's peer, or false
+// while the link is down.
+func (c *Connection) PeerIdentity() (*x509.Certificate, bool) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil, false
+	}
+	return transport.PeerIdentityOf(conn)
+}
+
+// Close stops reconnecting, closes the current link, and drops queued messages.
+// StateClosing is announced before closing starts, so a state callback may call Close.
+func (c *Connection) Close() error {
+	if !c.closed() {
+		c.state.Set(transport.StateClosing, nil)
+	}
+
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+
+		if conn != nil {
+			err = conn.Close()
+		}
+	})
+	return err
+}
+
+// run serves links until the connection is closed or reconnecting gives up.
+func (c *Connection) run(conn transport.Connection) {
+	defer func() { c.incoming.Close(c.terminalErr()) }()
+
+	for {
+		err := c.serve(conn)
+		c.detach(conn)
+
+		if c.closed() {
+			c.finish(fmt.Errorf("%w: %w", transport.ErrConnectionClosed, ErrClosed))
+			return
+		}
+		c.state.Set(transport.StateReconnecting, err)
+
+		conn, err = c.redial()
+		if err != nil {
+			c.finish(err)
+			return
+		}
+		if !c.attach(conn) {
+			c.finish(fmt.Errorf("%w: %w", transport.ErrConnectionClosed, ErrClosed))
+			return
+		}
+	}
+}
+
+// finish records the terminal error and moves to StateDisconnected. The error is
+// reported as the cause unless the connection was closed by the caller.
+func (c *Connection) finish(err error) {
+	c.setErr(err)
+
+	if c.closed() {
+		c.state.Set(transport.StateDisconnected, nil)
+		return
+	}
+	c.state.Set(transport.StateDisconnected, err)
+}
+
+// serve receives from a link until it fails or the connection is closed, and returns
+// the error that ended the link.
+func (c *Connection) serve(conn transport.Connection) error {
+	deliver := func(envelope *message.Envelope) bool {
+		return c.incoming.Deliver(envelope, nil)
+	}
+	lost := func(err error) bool {
+		return linkLost(conn, err)
+	}
+	if err := c.incoming.ReadLoop(conn, deliver, lost); err != nil {
+		return err
+	}
+	return ErrClosed
+}
+
+// redial dials with backoff until a link is established, the attempt budget is spent,
+// or the connection is closed.
+func (c *Connection) redial() (transport.Connection, error) {
+	var last error
+
+	for attempt := 0; c.config.MaxAttempts == 0 || attempt < c.config.MaxAttempts; attempt++ {
+		timer := time.NewTimer(c.config.backoff(attempt, c.random()))
+		select {
+		case <-timer.C:
+		case <-c.done:
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %w", transport.ErrConnectionClosed, ErrClosed)
+		}
+
+		conn, err := c.connect()
+		if err == nil {
+			return conn, nil
+		}
+		last = err
+	}
+
+	return nil, fmt.Errorf("%w: %w after %d attempts: %w", transport.ErrConnectionClosed, ErrGaveUp, c.config.MaxAttempts, last)
+}
+
+// connect dials a link and runs the OnConnect hook on it.
+func (c *Connection) connect() (transport.Connection, error) {
+	conn, err := c.transport.Connection()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.OnConnect != nil {
+		if err := c.config.OnConnect(conn); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("connect hook failed: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// attach makes conn the current link and sends the queued messages on it before any new
+// ones. Messages failing for other reasons than link loss are dropped; if the link is
+// lost, the rest stay queued for the next one. It returns false, closing conn, if the
+// connection was closed meanwhile.
+func (c *Connection) attach(conn transport.Connection) bool {
+	c.sendMu.Lock()
+
+	c.mu.Lock()
+	if c.closed() {
+		c.mu.Unlock()
+		c.sendMu.Unlock()
+		_ = conn.Close()
+		return false
+	}
+	c.conn = conn
+	c.unobserve, _ = transport.ObserveCodec(conn, c.observers.Forward)
+	c.mu.Unlock()
+
+	for len(c.queue) > 0 {
+		if err := write(context.Background(), conn, c.queue[0]); err != nil && linkLost(conn, err) {
+			_ = conn.Close()
+			break
+		}
+		c.queue = c.queue[1:]
+	}
+
+	c.sendMu.Unlock()
+
+	c.state.Set(transport.StateConnected, nil)
+	return true
+}
+
+// detach retires a failed link and folds its counters into the totals.
+func (c *Connection) detach(conn transport.Connection) {
+	c.mu.Lock()
+	c.conn = nil
+	c.unobserve()
+	c.stats = c.stats.Add(transport.StatsOf(conn))
+	c.mu.Unlock()
+
+	_ = conn.Close()
+}
+
+// closed reports whether Close has been called.
+func (c *Connection) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// setErr records the terminal error.
+func (c *Connection) setErr(err error) {
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+}
+
+// terminalErr returns the terminal error.
+func (c *Connection) terminalErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// linkLost reports whether err, returned by an operation on conn, means the link itself
+// is gone rather than a single operation having failed. Serial and BLE links report a
+// lost port or device as an ordinary receive or send error, so a link that moved to
+// StateDisconnected counts as lost whatever the error.
+func linkLost(conn transport.Connection, err error) bool {
+	return isLinkError(err) || conn.State() == transport.StateDisconnected
+}
+
+// isLinkError reports whether err by itself means the link is gone.
+func isLinkError(err error) bool {
+	return errors.Is(err, transport.ErrConnectionClosed) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
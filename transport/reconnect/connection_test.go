@@ -0,0 +1,556 @@
+package reconnect
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"go.bug.st/serial"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	serialTransport "kinetica-protocol/transport/serial"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockConnection is a link whose incoming messages are injected by the test.
+// Closing it makes pending and later receives fail with ErrConnectionClosed.
+type mockConnection struct {
+	in        chan *message.Envelope
+	mu        sync.Mutex
+	sent      []message.MsgType
+	cert      *x509.Certificate
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newMockConnection() *mockConnection {
+	return &mockConnection{in: make(chan *message.Envelope, 16), closed: make(chan struct{})}
+}
+
+func (m *mockConnection) Send(msg message.Message, msgType message.MsgType) error {
+	return m.SendPacket(msg, msgType, 0)
+}
+
+func (m *mockConnection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	select {
+	case <-m.closed:
+		return transport.ErrConnectionClosed
+	default:
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msgType)
+	return nil
+}
+
+func (m *mockConnection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return m.Send(msg, msgType)
+}
+
+func (m *mockConnection) Receive() (message.Message, error) {
+	envelope, err := m.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+func (m *mockConnection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	return m.Receive()
+}
+
+func (m *mockConnection) ReceiveEnvelope() (*message.Envelope, error) {
+	select {
+	case envelope := <-m.in:
+		return envelope, nil
+	case <-m.closed:
+		return nil, transport.ErrConnectionClosed
+	}
+}
+
+func (m *mockConnection) State() transport.ConnectionState {
+	return transport.StateConnected
+}
+
+func (m *mockConnection) Stats() transport.Stats {
+	return transport.Stats{MessagesSent: uint64(len(m.sentTypes()))}
+}
+
+func (m *mockConnection) Close() error {
+	m.closeOnce.Do(func() { close(m.closed) })
+	return nil
+}
+
+func (m *mockConnection) PeerIdentity() (*x509.Certificate, bool) {
+	return m.cert, m.cert != nil
+}
+
+func (m *mockConnection) sentTypes() []message.MsgType {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]message.MsgType(nil), m.sent...)
+}
+
+// mockTransport hands out a new mockConnection per dial and fails dials while failing is set.
+type mockTransport struct {
+	mu      sync.Mutex
+	conns   []*mockConnection
+	failing bool
+	dials   atomic.Int32
+	dialed  chan *mockConnection
+}
+
+func newMockTransport() *mockTransport {
+	return &mockTransport{dialed: make(chan *mockConnection, 16)}
+}
+
+func (t *mockTransport) Connection() (transport.Connection, error) {
+	t.dials.Add(1)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.failing {
+		return nil, transport.ErrConn
+	}
+	conn := newMockConnection()
+	t.conns = append(t.conns, conn)
+	t.dialed <- conn
+	return conn, nil
+}
+
+func (t *mockTransport) Listen() (<-chan transport.Connection, error) {
+	return nil, transport.ErrUnrealizedMethod
+}
+
+func (t *mockTransport) Close() error {
+	return nil
+}
+
+func (t *mockTransport) setFailing(failing bool) {
+	t.mu.Lock()
+	t.failing = failing
+	t.mu.Unlock()
+}
+
+func testConfig() Config {
+	return Config{InitialBackoff: 5 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, Jitter: -1}
+}
+
+func waitDial(t *testing.T, tr *mockTransport) *mockConnection {
+	t.Helper()
+
+	select {
+	case conn := <-tr.dialed:
+		return conn
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for dial")
+		return nil
+	}
+}
+
+func heartbeat() *message.Envelope {
+	return &message.Envelope{Payload: &message.SensorHeartbeat{SensorID: 1}}
+}
+
+func TestConnection_Reconnects(t *testing.T) {
+	tr := newMockTransport()
+
+	var mu sync.Mutex
+	var states []transport.ConnectionState
+	var hooks int
+
+	config := testConfig()
+	config.OnConnect = func(conn transport.Connection) error {
+		mu.Lock()
+		hooks++
+		mu.Unlock()
+		return conn.Send(&message.Registration{SensorID: 1}, message.MsgTypeRegister)
+	}
+	config.OnStateChange = func(state transport.ConnectionState, err error) {
+		mu.Lock()
+		states = append(states, state)
+		mu.Unlock()
+	}
+
+	conn, err := Dial(tr, config)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	first := waitDial(t, tr)
+	_ = first.Close()
+
+	second := waitDial(t, tr)
+	second.in <- heartbeat()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := conn.ReceiveContext(ctx); err != nil {
+		t.Fatalf("ReceiveContext() error = %v", err)
+	}
+
+	if types := second.sentTypes(); len(types) != 1 || types[0] != message.MsgTypeRegister {
+		t.Errorf("Expected registration on the new link, got %v", types)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hooks != 2 {
+		t.Errorf("Expected OnConnect twice, got %d", hooks)
+	}
+	expected := []transport.ConnectionState{transport.StateConnected, transport.StateReconnecting, transport.StateConnected}
+	if len(states) != len(expected) {
+		t.Fatalf("Expected states %v, got %v", expected, states)
+	}
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Errorf("State %d: expected %v, got %v", i, expected[i], states[i])
+		}
+	}
+}
+
+// unpluggablePort is a serial port fed by the test. Once unplugged or closed every read
+// fails, as go.bug.st/serial reports a device that went away.
+type unpluggablePort struct {
+	data     chan []byte
+	timeout  atomic.Int64
+	gone     chan struct{}
+	goneOnce sync.Once
+}
+
+var errUnplugged = errors.New("port has been closed")
+
+func newUnpluggablePort() *unpluggablePort {
+	return &unpluggablePort{data: make(chan []byte, 16), gone: make(chan struct{})}
+}
+
+func (p *unpluggablePort) SetMode(mode *serial.Mode) error { return nil }
+
+func (p *unpluggablePort) Read(b []byte) (int, error) {
+	select {
+	case data := <-p.data:
+		return copy(b, data), nil
+	case <-p.gone:
+		return 0, errUnplugged
+	case <-time.After(time.Duration(p.timeout.Load())):
+		return 0, nil
+	}
+}
+
+func (p *unpluggablePort) Write(b []byte) (int, error) {
+	select {
+	case <-p.gone:
+		return 0, errUnplugged
+	default:
+		return len(b), nil
+	}
+}
+
+func (p *unpluggablePort) Drain() error                                         { return nil }
+func (p *unpluggablePort) ResetInputBuffer() error                              { return nil }
+func (p *unpluggablePort) ResetOutputBuffer() error                             { return nil }
+func (p *unpluggablePort) SetDTR(dtr bool) error                                { return nil }
+func (p *unpluggablePort) SetRTS(rts bool) error                                { return nil }
+func (p *unpluggablePort) GetModemStatusBits() (*serial.ModemStatusBits, error) { return nil, nil }
+func (p *unpluggablePort) Break(time.Duration) error                            { return nil }
+
+func (p *unpluggablePort) SetReadTimeout(t time.Duration) error {
+	p.timeout.Store(int64(t))
+	return nil
+}
+
+func (p *unpluggablePort) Close() error {
+	p.unplug()
+	return nil
+}
+
+func (p *unpluggablePort) unplug() {
+	p.goneOnce.Do(func() { close(p.gone) })
+}
+
+// portTransport dials serial connections over a new unpluggablePort each time.
+type portTransport struct {
+	dialed chan *unpluggablePort
+}
+
+func (t *portTransport) Connection() (transport.Connection, error) {
+	port := newUnpluggablePort()
+	t.dialed <- port
+	return serialTransport.NewConnection(port, context.Background(), 0, serialTransport.TransportCRC, serialTransport.MaxMsgSize), nil
+}
+
+func (t *portTransport) Listen() (<-chan transport.Connection, error) {
+	return nil, transport.ErrUnrealizedMethod
+}
+
+func (t *portTransport) Close() error {
+	return nil
+}
+
+func TestConnection_ReconnectsSerial(t *testing.T) {
+	tr := &portTransport{dialed: make(chan *unpluggablePort, 4)}
+	conn, err := Dial(tr, testConfig())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	frame, err := codec.Marshal(&message.SensorHeartbeat{SensorID: 1}, 1, message.MsgTypeHeartbeat, serialTransport.TransportCRC)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	dialed := func() *unpluggablePort {
+		select {
+		case port := <-tr.dialed:
+			return port
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for dial")
+			return nil
+		}
+	}
+
+	// The serial connection reports the unplugged port as a receive error, not as a
+	// closed connection; only its state tells that the link is gone.
+	dialed().unplug()
+
+	second := dialed()
+	second.data <- frame
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := conn.ReceiveContext(ctx); err != nil {
+		t.Fatalf("Expected a message from the redialed port, got %v", err)
+	}
+}
+
+func TestConnection_PeerIdentity(t *testing.T) {
+	tr := newMockTransport()
+
+	conn, err := Dial(tr, testConfig())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	first := waitDial(t, tr)
+	first.cert = &x509.Certificate{Subject: pkix.Name{CommonName: "first"}}
+	if cert, ok := transport.PeerIdentityOf(conn); !ok || cert != first.cert {
+		t.Errorf("Expected the identity of the first link, got %v", cert)
+	}
+
+	_ = first.Close()
+	second := waitDial(t, tr)
+	second.cert = &x509.Certificate{Subject: pkix.Name{CommonName: "second"}}
+	deadline := time.Now().Add(time.Second)
+	for {
+		cert, ok := conn.PeerIdentity()
+		if ok && cert == second.cert {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the identity of the second link, got %v", cert)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnection_QueuesWhileDisconnected(t *testing.T) {
+	tr := newMockTransport()
+
+	config := testConfig()
+	config.SendQueue = 2
+	disconnected := make(chan struct{}, 1)
+	config.OnStateChange = func(state transport.ConnectionState, err error) {
+		if state == transport.StateReconnecting {
+			disconnected <- struct{}{}
+		}
+	}
+
+	conn, err := Dial(tr, config)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	tr.setFailing(true)
+	_ = waitDial(t, tr).Close()
+	<-disconnected
+
+	if conn.State() != transport.StateReconnecting {
+		t.Errorf("Expected StateReconnecting, got %v", conn.State())
+	}
+	if err := conn.Send(&message.SensorCommand{SensorID: 1}, message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := conn.SendPacket(&message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat, 9); err != nil {
+		t.Fatalf("SendPacket() error = %v", err)
+	}
+	if err := conn.Send(&message.SensorCommand{SensorID: 1}, message.MsgTypeCommand); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	tr.setFailing(false)
+	second := waitDial(t, tr)
+
+	deadline := time.Now().Add(time.Second)
+	for conn.State() != transport.StateConnected && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	types := second.sentTypes()
+	if len(types) != 2 || types[0] != message.MsgTypeCommand || types[1] != message.MsgTypeHeartbeat {
+		t.Errorf("Expected queued messages in order, got %v", types)
+	}
+	if stats := conn.Stats(); stats.MessagesSent != 2 {
+		t.Errorf("Expected 2 messages sent across links, got %d", stats.MessagesSent)
+	}
+}
+
+func TestConnection_NoQueue(t *testing.T) {
+	tr := newMockTransport()
+	conn, err := Dial(tr, testConfig())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	tr.setFailing(true)
+	_ = waitDial(t, tr).Close()
+
+	deadline := time.Now().Add(time.Second)
+	for conn.State() != transport.StateReconnecting && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := conn.Send(&message.SensorCommand{SensorID: 1}, message.MsgTypeCommand); !errors.Is(err, transport.ErrSendFailed) {
+		t.Errorf("Expected ErrSendFailed, got %v", err)
+	}
+}
+
+func TestConnection_GivesUp(t *testing.T) {
+	tr := newMockTransport()
+	config := testConfig()
+	config.MaxAttempts = 3
+	conn, err := Dial(tr, config)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	tr.setFailing(true)
+	_ = waitDial(t, tr).Close()
+
+	_, err = conn.Receive()
+	if !errors.Is(err, ErrGaveUp) || !errors.Is(err, transport.ErrConnectionClosed) {
+		t.Errorf("Expected ErrGaveUp, got %v", err)
+	}
+	if state := conn.State(); state != transport.StateDisconnected {
+		t.Errorf("Expected StateDisconnected, got %v", state)
+	}
+	if n := tr.dials.Load(); n != 4 {
+		t.Errorf("Expected 4 dials, got %d", n)
+	}
+	if err := conn.Send(&message.SensorCommand{SensorID: 1}, message.MsgTypeCommand); !errors.Is(err, ErrGaveUp) {
+		t.Errorf("Expected ErrGaveUp on send, got %v", err)
+	}
+}
+
+func TestConnection_FirstDialFails(t *testing.T) {
+	tr := newMockTransport()
+	tr.setFailing(true)
+
+	if _, err := Dial(tr, testConfig()); !errors.Is(err, transport.ErrConn) {
+		t.Errorf("Expected ErrConn, got %v", err)
+	}
+}
+
+func TestConnection_Close(t *testing.T) {
+	tr := newMockTransport()
+	conn, err := Dial(tr, testConfig())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	link := waitDial(t, tr)
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := conn.Receive(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	if state := conn.State(); state != transport.StateDisconnected {
+		t.Errorf("Expected StateDisconnected, got %v", state)
+	}
+	if _, err := link.Receive(); !errors.Is(err, transport.ErrConnectionClosed) {
+		t.Error("Expected the link to be closed")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if n := tr.dials.Load(); n != 1 {
+		t.Errorf("Expected no redial after Close, got %d dials", n)
+	}
+}
+
+func TestConnection_CloseFromStateChange(t *testing.T) {
+	tr := newMockTransport()
+
+	var conn *Connection
+	closed := make(chan error, 1)
+	config := testConfig()
+	config.OnStateChange = func(state transport.ConnectionState, err error) {
+		if state == transport.StateReconnecting || state == transport.StateClosing {
+			closed <- conn.Close()
+		}
+	}
+
+	conn, err := Dial(tr, config)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	_ = waitDial(t, tr).Close()
+
+	for range 2 {
+		select {
+		case err := <-closed:
+			if err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Close called from OnStateChange did not return")
+		}
+	}
+	if _, err := conn.Receive(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestConfig_Backoff(t *testing.T) {
+	config := Config{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2, Jitter: 0.5}.withDefaults()
+
+	tests := []struct {
+		attempt int
+		random  float64
+		want    time.Duration
+	}{
+		{0, 0.5, 100 * time.Millisecond},
+		{1, 0.5, 200 * time.Millisecond},
+		{3, 0.5, 800 * time.Millisecond},
+		{4, 0.5, time.Second},
+		{50, 0.5, time.Second},
+		{0, 0, 50 * time.Millisecond},
+		{0, 1, 150 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := config.backoff(tt.attempt, tt.random); got != tt.want {
+			t.Errorf("backoff(%d, %v) = %v, want %v", tt.attempt, tt.random, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package reconnect
+
+import "errors"
+
+// Reconnect error definitions.
+var (
+	ErrDisconnected = errors.New("link is down")                   // Send while disconnected without a send queue
+	ErrQueueFull    = errors.New("send queue full")                // Send while disconnected with the send queue full
+	ErrGaveUp       = errors.New("reconnect attempts exhausted")   // MaxAttempts consecutive redials failed
+	ErrClosed       = errors.New("reconnecting connection closed") // Connection was closed by the caller
+)
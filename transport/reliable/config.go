@@ -0,0 +1,59 @@
+// Package reliable provides an optional Ack-based reliable delivery layer on top of any
+// transport.Connection. Outgoing messages of selected types are tracked by PacketID and
+// retransmitted with backoff until the peer acknowledges them; incoming messages of those
+// types are acknowledged automatically and delivered to the caller exactly once.
+package reliable
+
+import (
+	"kinetica-protocol/protocol/message"
+	"time"
+)
+
+// Reliable delivery defaults.
+const (
+	DefaultAckTimeout  = 500 * time.Millisecond // Initial wait for an Ack before retransmitting
+	DefaultMaxRetries  = 3                      // Retransmissions before giving up
+	DefaultBackoff     = 2.0                    // Multiplier applied to the wait after each retransmission
+	DefaultDedupWindow = 5 * time.Second        // How long received packets are remembered for duplicate detection
+	DefaultQueueSize   = 64                     // Incoming messages buffered for Receive
+)
+
+// DefaultTypes are the message types acknowledged when Config.Types is empty.
+var DefaultTypes = []message.MsgType{message.MsgTypeCommand, message.MsgTypeConfig}
+
+// Config defines reliable delivery parameters. Zero values are replaced with defaults.
+// Both peers must agree on Types so that every tracked message is acknowledged.
+type Config struct {
+	SensorID    uint8             // SensorID placed in Acks for messages that carry none
+	Types       []message.MsgType // Message types that require acknowledgment
+	AckTimeout  time.Duration     // Initial wait for an Ack before retransmitting
+	MaxRetries  int               // Retransmissions before giving up (negative for none)
+	Backoff     float64           // Multiplier applied to the wait after each retransmission
+	DedupWindow time.Duration     // How long received packets are remembered for duplicate detection
+	QueueSize   int               // Incoming messages buffered for Receive
+}
+
+// withDefaults returns a copy of the configuration with zero values replaced by defaults.
+func (c Config) withDefaults() Config {
+	if len(c.Types) == 0 {
+		c.Types = DefaultTypes
+	}
+	if c.AckTimeout <= 0 {
+		c.AckTimeout = DefaultAckTimeout
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultMaxRetries
+	} else if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.Backoff < 1 {
+		c.Backoff = DefaultBackoff
+	}
+	if c.DedupWindow <= 0 {
+		c.DedupWindow = DefaultDedupWindow
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	return c
+}
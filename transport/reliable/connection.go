@@ -0,0 +1,376 @@
+package reliable
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// seenPacket records a delivered packet for duplicate detection.
+type seenPacket struct {
+	raw []byte    // Raw bytes of the delivered packet
+	at  time.Time // Delivery time
+}
+
+// pending is a sent packet awaiting its Ack.
+type pending struct {
+	sensorID  uint8                  // Sensor the Ack must name
+	anySensor bool                   // Whether the message carries no sensor identifier
+	acks      chan message.AckStatus // Receives the status of the matching Ack
+}
+
+// Connection wraps a transport connection with Ack-based reliable delivery.
+// A background goroutine reads from the underlying connection, resolves Acks for
+// outstanding packets, acknowledges tracked incoming messages, and queues everything
+// else for Receive. The queue never blocks the read loop: untracked messages and
+// receive errors arriving while it is full are dropped, so a slow reader cannot delay
+// Acks.
+type Connection struct {
+	conn        transport.Connection     // Underlying transport connection
+	config      Config                   // Reliable delivery parameters
+	types       map[message.MsgType]bool // Message types that require acknowledgment
+	packetID    atomic.Uint32            // Atomic counter for all outgoing packet IDs
+	mu          sync.Mutex               // Guards outstanding and seen
+	outstanding map[uint8]*pending       // Packets awaiting an Ack by PacketID
+	seen        map[uint8]seenPacket     // Recently delivered tracked packets by PacketID
+	incoming    *transport.ReceiveQueue  // Messages and errors queued for Receive
+	done        chan struct{}            // Closed when the connection is closed
+	closeOnce   sync.Once                // Ensures Close runs once
+	now         func() time.Time         // Clock used for duplicate detection
+}
+
+// NewConnection wraps an existing connection with reliable delivery and starts its read loop.
+// The underlying connection must not be read by anyone else afterwards.
+func NewConnection(conn transport.Connection, config Config) *Connection {
+	config = config.withDefaults()
+
+	types := make(map[message.MsgType]bool, len(config.Types))
+	for _, t := range config.Types {
+		if t != message.MsgTypeAck {
+			types[t] = true
+		}
+	}
+
+	done := make(chan struct{})
+	c := &Connection{
+		conn:        conn,
+		config:      config,
+		types:       types,
+		outstanding: make(map[uint8]*pending),
+		seen:        make(map[uint8]seenPacket),
+		incoming:    transport.NewDroppingReceiveQueue(config.QueueSize, done),
+		done:        done,
+		now:         time.Now,
+	}
+
+	go c.readLoop()
+
+	return c
+}
+
+// Send transmits the message. Tracked message types block until the peer acknowledges
+// the packet, retransmitting with backoff on timeout; other types are sent directly.
+// All packets, including Acks, take their PacketID from the same counter.
+func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	return c.SendContext(context.Background(), msg, msgType)
+}
+
+// SendContext transmits the message like Send, giving up when ctx is canceled or its
+// deadline passes, including while waiting for an Ack. Untracked messages check the
+// context before sending because their PacketID is chosen here.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	if !c.types[msgType] {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+		}
+		return c.conn.SendPacket(msg, msgType, c.getNextPacketID())
+	}
+
+	for i := 0; i < 256; i++ {
+		packetID := c.getNextPacketID()
+		if ch, ok := c.track(msg, packetID); ok {
+			defer c.untrack(packetID)
+			return c.sendTracked(ctx, msg, msgType, packetID, ch)
+		}
+	}
+
+	return fmt.Errorf("%w: all packet IDs are awaiting acknowledgment", transport.ErrSendFailed)
+}
+
+// SendPacket transmits the message like Send using the given PacketID.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	if !c.types[msgType] {
+		return c.conn.SendPacket(msg, msgType, packetID)
+	}
+
+	ch, ok := c.track(msg, packetID)
+	if !ok {
+		return fmt.Errorf("%w: packet %d is already awaiting acknowledgment", transport.ErrSendFailed, packetID)
+	}
+	defer c.untrack(packetID)
+
+	return c.sendTracked(context.Background(), msg, msgType, packetID, ch)
+}
+
+// sendTracked transmits a tracked packet and waits for its Ack, retransmitting on timeout
+// and on AckInvalidCRC until the retry budget is exhausted.
+func (c *Connection) sendTracked(ctx context.Context, msg message.Message, msgType message.MsgType, packetID uint8, acks chan message.AckStatus) error {
+	timeout := c.config.AckTimeout
+	var last message.AckStatus
+
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+		}
+		if err := c.conn.SendPacket(msg, msgType, packetID); err != nil {
+			return err
+		}
+
+		timer := time.NewTimer(timeout)
+		select {
+		case status := <-acks:
+			timer.Stop()
+			switch status {
+			case message.AckOK:
+				return nil
+			case message.AckInvalidCRC:
+				last = status
+			default:
+				return &AckError{PacketID: packetID, Status: status}
+			}
+		case <-timer.C:
+		case <-c.done:
+			timer.Stop()
+			return fmt.Errorf("%w: %w", transport.ErrSendFailed, ErrClosed)
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", transport.ErrContextCanceled, ctx.Err())
+		}
+
+		timeout = time.Duration(float64(timeout) * c.config.Backoff)
+	}
+
+	if last == message.AckInvalidCRC {
+		return &AckError{PacketID: packetID, Status: last}
+	}
+
+	return fmt.Errorf("%w: packet %d after %d attempts", ErrAckTimeout, packetID, c.config.MaxRetries+1)
+}
+
+// Receive returns the next message delivered by the read loop.
+func (c *Connection) Receive() (message.Message, error) {
+	envelope, err := c.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveContext returns the next message like Receive, giving up when ctx is canceled
+// or its deadline passes.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	envelope, err := c.incoming.ReceiveContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveEnvelope returns the next message with its packet metadata.
+// Tracked messages are returned once even if the peer retransmits them, and
+// Acks consumed by outstanding sends are never returned.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	return c.incoming.Receive()
+}
+
+// Stats returns the statistics of the underlying connection. Retransmissions and
+// Acks are counted as separate packets.
+func (c *Connection) Stats() transport.Stats {
+	return transport.StatsOf(c.conn)
+}
+
+// Flush writes packets buffered by the underlying connection.
+func (c *Connection) Flush() error {
+	return transport.Flush(c.conn)
+}
+
+// State returns the state of the underlying connection, or disconnected after Close.
+func (c *Connection) State() transport.ConnectionState {
+	select {
+	case <-c.done:
+		return transport.StateDisconnected
+	default:
+		return c.conn.State()
+	}
+}
+
+// Subscribe registers fn for the state transitions of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	unsubscribe, _ = transport.SubscribeState(c.conn, fn)
+	return unsubscribe
+}
+
+// ObserveCodec registers fn for the codec operations of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	remove, _ = transport.ObserveCodec(c.conn, fn)
+	return remove
+}
+
+// PeerIdentity returns the verified certificate of the underlying connection's peer.
+func (c *Connection) PeerIdentity() (*x509.Certificate, bool) {
+	return transport.PeerIdentityOf(c.conn)
+}
+
+// Close stops the read loop, aborts outstanding sends, and closes the underlying connection.
+func (c *Connection) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// readLoop receives from the underlying connection until it fails or is closed.
+// The queue never blocks, so Acks keep being processed while the caller is not receiving.
+func (c *Connection) readLoop() {
+	err := c.incoming.ReadLoop(c.conn, c.handle, transport.IsTerminal)
+	if err == nil {
+		err = fmt.Errorf("%w: %w", transport.ErrConnectionClosed, ErrClosed)
+	}
+	c.incoming.Close(err)
+}
+
+// handle routes a received envelope. It always continues the read loop.
+func (c *Connection) handle(envelope *message.Envelope) bool {
+	if ack, ok := envelope.Payload.(*message.Ack); ok && c.resolve(ack) {
+		return true
+	}
+
+	if !c.types[envelope.Header.Type] {
+		c.incoming.Deliver(envelope, nil)
+		return true
+	}
+
+	if c.isDuplicate(envelope) {
+		c.ack(envelope, message.AckOK)
+		return true
+	}
+
+	if c.incoming.Deliver(envelope, nil) {
+		c.remember(envelope)
+		c.ack(envelope, message.AckOK)
+	} else {
+		c.ack(envelope, message.AckBufferFull)
+	}
+	return true
+}
+
+// ack acknowledges a received packet, naming the sensor of the acknowledged message so
+// the sender can match the Ack. Send errors are ignored because the peer retransmits
+// unacknowledged packets.
+func (c *Connection) ack(envelope *message.Envelope, status message.AckStatus) {
+	sensorID, ok := message.SensorIDOf(envelope.Payload)
+	if !ok {
+		sensorID = c.config.SensorID
+	}
+
+	_ = c.conn.SendPacket(&message.Ack{
+		SensorID:  sensorID,
+		MessageID: uint16(envelope.Header.PacketID),
+		Status:    status,
+	}, message.MsgTypeAck, c.getNextPacketID())
+}
+
+// resolve passes an Ack to the send waiting for it and reports whether one was waiting.
+// The Ack must name the PacketID and the sensor of the sent message.
+func (c *Connection) resolve(ack *message.Ack) bool {
+	if ack.MessageID > 0xFF {
+		return false
+	}
+
+	c.mu.Lock()
+	p, ok := c.outstanding[uint8(ack.MessageID)]
+	c.mu.Unlock()
+
+	if !ok || (!p.anySensor && p.sensorID != ack.SensorID) {
+		return false
+	}
+
+	select {
+	case p.acks <- ack.Status:
+	default:
+	}
+	return true
+}
+
+// track registers a packet as awaiting an Ack. It fails if the PacketID is already in use.
+func (c *Connection) track(msg message.Message, packetID uint8) (chan message.AckStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, busy := c.outstanding[packetID]; busy {
+		return nil, false
+	}
+
+	sensorID, ok := message.SensorIDOf(msg)
+	p := &pending{
+		sensorID:  sensorID,
+		anySensor: !ok,
+		acks:      make(chan message.AckStatus, 1),
+	}
+	c.outstanding[packetID] = p
+	return p.acks, true
+}
+
+// untrack removes a packet from the outstanding set.
+func (c *Connection) untrack(packetID uint8) {
+	c.mu.Lock()
+	delete(c.outstanding, packetID)
+	c.mu.Unlock()
+}
+
+// isDuplicate reports whether the packet was already delivered within the dedup window.
+// Retransmissions carry identical raw bytes, so a reused PacketID with different
+// content is treated as a new message.
+func (c *Connection) isDuplicate(envelope *message.Envelope) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok := c.seen[envelope.Header.PacketID]
+	if !ok || c.now().Sub(prev.at) > c.config.DedupWindow {
+		return false
+	}
+	return bytes.Equal(prev.raw, envelope.Raw)
+}
+
+// remember records a delivered packet and prunes entries outside the dedup window.
+func (c *Connection) remember(envelope *message.Envelope) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for id, p := range c.seen {
+		if now.Sub(p.at) > c.config.DedupWindow {
+			delete(c.seen, id)
+		}
+	}
+
+	c.seen[envelope.Header.PacketID] = seenPacket{
+		raw: append([]byte(nil), envelope.Raw...),
+		at:  now,
+	}
+}
+
+// getNextPacketID generates a packet ID for outgoing packets with wraparound.
+func (c *Connection) getNextPacketID() uint8 {
+	return uint8(c.packetID.Add(1) % 256)
+}
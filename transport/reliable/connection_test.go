@@ -0,0 +1,315 @@
+package reliable
+
+import (
+	"context"
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"kinetica-protocol/transport/internal/transporttest"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	return Config{AckTimeout: 20 * time.Millisecond, MaxRetries: 3, Backoff: 1.5}
+}
+
+func command() *message.SensorCommand {
+	return &message.SensorCommand{SensorID: 1, Command: 0x01}
+}
+
+func receiveWithin(t *testing.T, conn transport.Connection, d time.Duration) message.Message {
+	t.Helper()
+
+	ch := make(chan message.Message, 1)
+	go func() {
+		msg, err := conn.Receive()
+		if err != nil {
+			t.Errorf("Receive() error = %v", err)
+		}
+		ch <- msg
+	}()
+
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(d):
+		t.Fatal("Timed out waiting for message")
+		return nil
+	}
+}
+
+func TestConnection_SendAcknowledged(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, testConfig())
+	receiver := NewConnection(b, testConfig())
+	defer sender.Close()
+	defer receiver.Close()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if _, ok := receiveWithin(t, receiver, time.Second).(*message.SensorCommand); !ok {
+		t.Error("Expected Command to be delivered")
+	}
+	if n := a.SentOfType(message.MsgTypeCommand); n != 1 {
+		t.Errorf("Expected 1 transmission, got %d", n)
+	}
+}
+
+func TestConnection_RetransmitLostPacket(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	a.SetDrop(transporttest.DropFirst(message.MsgTypeCommand, 2))
+	sender := NewConnection(a, testConfig())
+	receiver := NewConnection(b, testConfig())
+	defer sender.Close()
+	defer receiver.Close()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if n := a.SentOfType(message.MsgTypeCommand); n != 3 {
+		t.Errorf("Expected 3 transmissions, got %d", n)
+	}
+	receiveWithin(t, receiver, time.Second)
+}
+
+func TestConnection_DuplicateSuppressed(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	b.SetDrop(transporttest.DropFirst(message.MsgTypeAck, 1))
+	sender := NewConnection(a, testConfig())
+	receiver := NewConnection(b, testConfig())
+	defer sender.Close()
+	defer receiver.Close()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if n := a.SentOfType(message.MsgTypeCommand); n != 2 {
+		t.Fatalf("Expected a retransmission after the lost Ack, got %d transmissions", n)
+	}
+
+	receiveWithin(t, receiver, time.Second)
+	if n := receiver.incoming.Len(); n != 0 {
+		t.Errorf("Expected retransmitted Command to be dropped, %d queued", n)
+	}
+	if n := b.SentOfType(message.MsgTypeAck); n != 2 {
+		t.Errorf("Expected duplicate to be acknowledged again, got %d Acks", n)
+	}
+}
+
+func TestConnection_AckTimeout(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, Config{AckTimeout: 5 * time.Millisecond, MaxRetries: 2})
+	defer sender.Close()
+	defer b.Close()
+
+	err := sender.Send(command(), message.MsgTypeCommand)
+	if !errors.Is(err, ErrAckTimeout) {
+		t.Fatalf("Expected ErrAckTimeout, got %v", err)
+	}
+	if n := a.SentOfType(message.MsgTypeCommand); n != 3 {
+		t.Errorf("Expected 3 transmissions, got %d", n)
+	}
+}
+
+func TestConnection_SendContextCanceledWhileWaiting(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, Config{AckTimeout: time.Second})
+	defer sender.Close()
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := sender.SendContext(ctx, command(), message.MsgTypeCommand)
+	if !errors.Is(err, transport.ErrContextCanceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected ErrContextCanceled with DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected SendContext to return at the deadline, took %v", elapsed)
+	}
+}
+
+func TestConnection_ReceiveContext(t *testing.T) {
+	a, _ := transporttest.NewPipe()
+	conn := NewConnection(a, testConfig())
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := conn.ReceiveContext(ctx); !errors.Is(err, transport.ErrContextCanceled) {
+		t.Errorf("Expected ErrContextCanceled, got %v", err)
+	}
+}
+
+func TestConnection_AckBufferFull(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, testConfig())
+	defer sender.Close()
+
+	go func() {
+		envelope, err := b.ReceiveEnvelope()
+		if err != nil {
+			return
+		}
+		b.Send(&message.Ack{
+			SensorID:  1,
+			MessageID: uint16(envelope.Header.PacketID),
+			Status:    message.AckBufferFull,
+		}, message.MsgTypeAck)
+	}()
+
+	err := sender.Send(command(), message.MsgTypeCommand)
+	if !errors.Is(err, ErrAckBufferFull) {
+		t.Fatalf("Expected ErrAckBufferFull, got %v", err)
+	}
+
+	var ackErr *AckError
+	if !errors.As(err, &ackErr) || ackErr.Status != message.AckBufferFull {
+		t.Errorf("Expected AckError with AckBufferFull, got %v", err)
+	}
+	if n := a.SentOfType(message.MsgTypeCommand); n != 1 {
+		t.Errorf("Expected no retransmission, got %d transmissions", n)
+	}
+}
+
+func TestConnection_ReceiverQueueFull(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	receiver := NewConnection(b, Config{QueueSize: 1})
+	defer receiver.Close()
+
+	a.Send(command(), message.MsgTypeCommand)
+	a.Send(command(), message.MsgTypeCommand)
+
+	var statuses []message.AckStatus
+	for len(statuses) < 2 {
+		select {
+		case envelope := <-a.Incoming():
+			statuses = append(statuses, envelope.Payload.(*message.Ack).Status)
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for Acks")
+		}
+	}
+
+	if statuses[0] != message.AckOK || statuses[1] != message.AckBufferFull {
+		t.Errorf("Expected [AckOK AckBufferFull], got %v", statuses)
+	}
+}
+
+func TestConnection_AckFromOtherSensorIgnored(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	config := testConfig()
+	config.MaxRetries = -1
+	sender := NewConnection(a, config)
+	defer sender.Close()
+
+	go func() {
+		envelope, err := b.ReceiveEnvelope()
+		if err != nil {
+			return
+		}
+		b.Send(&message.Ack{
+			SensorID:  2,
+			MessageID: uint16(envelope.Header.PacketID),
+			Status:    message.AckOK,
+		}, message.MsgTypeAck)
+	}()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); !errors.Is(err, ErrAckTimeout) {
+		t.Errorf("Expected ErrAckTimeout for an Ack naming another sensor, got %v", err)
+	}
+}
+
+func TestConnection_AcksWhileQueueFull(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, Config{AckTimeout: time.Second, QueueSize: 1})
+	receiver := NewConnection(b, testConfig())
+	defer sender.Close()
+	defer receiver.Close()
+
+	heartbeat := &message.SensorHeartbeat{SensorID: 1, Battery: 80, Status: message.Ok}
+	for i := 0; i < 3; i++ {
+		if err := receiver.Send(heartbeat, message.MsgTypeHeartbeat); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	go func() {
+		for {
+			if _, err := receiver.Receive(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Errorf("Expected the Ack to resolve behind a full receive queue, got %v", err)
+	}
+}
+
+func TestConnection_UntrackedPassThrough(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, testConfig())
+	receiver := NewConnection(b, testConfig())
+	defer sender.Close()
+	defer receiver.Close()
+
+	heartbeat := &message.SensorHeartbeat{SensorID: 1, Battery: 80, Status: message.Ok}
+	if err := sender.Send(heartbeat, message.MsgTypeHeartbeat); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if _, ok := receiveWithin(t, receiver, time.Second).(*message.SensorHeartbeat); !ok {
+		t.Error("Expected heartbeat to be delivered")
+	}
+	if n := b.SentOfType(message.MsgTypeAck); n != 0 {
+		t.Errorf("Expected no Acks for untracked types, got %d", n)
+	}
+}
+
+func TestConnection_UnregisteredType(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	config := testConfig()
+	config.Types = []message.MsgType{message.MsgTypeCommand, message.MsgTypeVendorMax}
+	receiver := NewConnection(b, config)
+	defer receiver.Close()
+
+	// A wrapped connection may pass on a frame whose type no decoder knows.
+	a.Inject(&message.Envelope{Header: message.Header{Type: message.MsgTypeVendorMax, PacketID: 5}, Raw: []byte{0x01}})
+
+	envelope, err := receiver.ReceiveEnvelope()
+	if err != nil {
+		t.Fatalf("ReceiveEnvelope() error = %v", err)
+	}
+	if envelope.Header.Type != message.MsgTypeVendorMax || envelope.Header.PacketID != 5 {
+		t.Errorf("Expected the vendor packet 5, got %+v", envelope.Header)
+	}
+
+	select {
+	case envelope := <-a.Incoming():
+		if ack, ok := envelope.Payload.(*message.Ack); !ok || ack.MessageID != 5 || ack.Status != message.AckOK {
+			t.Errorf("Expected AckOK for packet 5, got %+v", envelope.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the Ack")
+	}
+}
+
+func TestConnection_Close(t *testing.T) {
+	a, _ := transporttest.NewPipe()
+	conn := NewConnection(a, testConfig())
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := conn.Receive(); !errors.Is(err, transport.ErrConnectionClosed) {
+		t.Errorf("Expected ErrConnectionClosed, got %v", err)
+	}
+	if conn.State() != transport.StateDisconnected {
+		t.Errorf("Expected disconnected state, got %v", conn.State())
+	}
+}
@@ -0,0 +1,40 @@
+package reliable
+
+import (
+	"errors"
+	"fmt"
+	"kinetica-protocol/protocol/message"
+)
+
+// Reliable delivery error definitions for acknowledged transmissions.
+var (
+	ErrAckTimeout    = errors.New("acknowledgment timeout")     // No Ack received after all retransmissions
+	ErrAckBufferFull = errors.New("peer receive buffer full")   // Peer answered with AckBufferFull
+	ErrAckInvalidCRC = errors.New("peer reported invalid CRC")  // Peer answered with AckInvalidCRC on every attempt
+	ErrAckRejected   = errors.New("message rejected by peer")   // Peer answered with AckError or AckUnknownMessage
+	ErrClosed        = errors.New("reliable connection closed") // Connection was closed while waiting for an Ack
+)
+
+// AckError reports a negative acknowledgment received for a sent packet.
+// It unwraps to ErrAckBufferFull, ErrAckInvalidCRC, or ErrAckRejected depending on the status.
+type AckError struct {
+	PacketID uint8             // PacketID of the acknowledged packet
+	Status   message.AckStatus // Status code returned by the peer
+}
+
+// Error returns a description of the negative acknowledgment.
+func (e *AckError) Error() string {
+	return fmt.Sprintf("%v: packet %d, status 0x%02x", e.Unwrap(), e.PacketID, uint8(e.Status))
+}
+
+// Unwrap returns the sentinel error matching the Ack status.
+func (e *AckError) Unwrap() error {
+	switch e.Status {
+	case message.AckBufferFull:
+		return ErrAckBufferFull
+	case message.AckInvalidCRC:
+		return ErrAckInvalidCRC
+	default:
+		return ErrAckRejected
+	}
+}
@@ -0,0 +1,42 @@
+// Package secure provides optional authenticated encryption on top of any
+// transport.Connection. Every outgoing message is encrypted with AES-GCM under a
+// pre-shared per-sensor key and sent as a message.SecureMessage; incoming secure
+// messages are authenticated, checked against replays, and decrypted before delivery.
+//
+// The 12-byte nonce is the PacketID of the frame, the sender's random 56-bit session
+// identifier, and its 32-bit message counter. The top bit of the counter marks the
+// direction: it is set by the ReplyKey side of a link, so both ends of a link sharing a
+// key never produce the same nonce. A sender starts a new session before the remaining 31
+// bits wrap, so it never repeats a nonce; different senders under one key could only
+// collide by drawing the same random session. The key identifier, session, and counter
+// travel in the clear and are authenticated together with the PacketID.
+//
+// Replay state is kept in memory by the Keyring and covers the MaxSessions most recently
+// active sessions of each key. A session that was forgotten, because the receiver
+// restarted or the session was evicted, is accepted again, and so is captured traffic
+// from it. Where replays across restarts matter, install fresh keys on every restart,
+// for example keys derived from the pre-shared secret and a boot counter shared with the
+// sensors.
+package secure
+
+// DefaultQueueSize is the number of incoming messages buffered for Receive when
+// Config.QueueSize is zero.
+const DefaultQueueSize = 64
+
+// Config defines the keys and policy of a secure connection.
+// Connections sharing one Keyring share replay protection.
+type Config struct {
+	Keyring        *Keyring // Pre-shared keys and replay state
+	KeyID          uint8    // Key used for sending (a sensor uses its own key)
+	ReplyKey       bool     // Send with the key of the last authenticated message received instead of KeyID (servers)
+	AllowPlaintext bool     // Deliver unencrypted messages instead of rejecting them with ErrPlaintext
+	QueueSize      int      // Incoming messages buffered for Receive
+}
+
+// withDefaults returns a copy of the configuration with zero values replaced by defaults.
+func (c Config) withDefaults() Config {
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	return c
+}
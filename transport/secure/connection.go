@@ -0,0 +1,331 @@
+package secure
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"sync"
+	"sync/atomic"
+)
+
+// Session and counter layout. A session fits the seven nonce bytes after the PacketID.
+// The top bit of a counter marks the direction of the message and the remaining bits
+// count the messages of a session.
+const (
+	maxSession   = 1<<56 - 1        // Largest session identifier
+	directionBit = 1 << 31          // Set on messages sent by the ReplyKey side of a link
+	maxCounter   = directionBit - 1 // Last counter of a session before a new one starts
+)
+
+// Connection wraps a transport connection with authenticated encryption.
+// A background goroutine reads from the underlying connection, verifies and decrypts
+// secure messages, and queues the results for Receive.
+type Connection struct {
+	conn       transport.Connection    // Underlying transport connection
+	config     Config                  // Keys and policy
+	packetID   atomic.Uint32           // Atomic counter for packet IDs
+	mu         sync.Mutex              // Guards session, counter, peerKeyID, and hasPeerKey
+	session    uint64                  // Random identifier of the current send session
+	counter    uint32                  // Last counter used in the session, without the direction bit
+	peerKeyID  uint8                   // Key of the last authenticated message received
+	hasPeerKey bool                    // Whether any message has been authenticated
+	incoming   *transport.ReceiveQueue // Messages and errors queued for Receive
+	done       chan struct{}           // Closed when the connection is closed
+	closeOnce  sync.Once               // Ensures Close runs once
+}
+
+// NewConnection wraps an existing connection with authenticated encryption and starts
+// its read loop. The underlying connection must not be read by anyone else afterwards.
+func NewConnection(conn transport.Connection, config Config) *Connection {
+	config = config.withDefaults()
+
+	done := make(chan struct{})
+	c := &Connection{
+		conn:     conn,
+		config:   config,
+		session:  newSession(),
+		incoming: transport.NewReceiveQueue(config.QueueSize, done),
+		done:     done,
+	}
+
+	go c.readLoop()
+
+	return c
+}
+
+// newSession returns a random 56-bit session identifier.
+func newSession() uint64 {
+	var b [8]byte
+	_, _ = rand.Read(b[1:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// getNextPacketID generates a packet ID using atomic increment with wraparound.
+func (c *Connection) getNextPacketID() uint8 {
+	return uint8(c.packetID.Add(1))
+}
+
+// Send encrypts and transmits the message.
+func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	return c.SendPacket(msg, msgType, c.getNextPacketID())
+}
+
+// SendContext encrypts and transmits the message like Send. The context is checked
+// before sending because the PacketID, which is authenticated with the message, must be
+// chosen here.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+	return c.SendPacket(msg, msgType, c.getNextPacketID())
+}
+
+// SendPacket encrypts and transmits the message using the given PacketID.
+// Retransmissions under the same PacketID are encrypted with a fresh counter.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	secure, err := c.seal(msg, msgType, packetID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrSendFailed, err)
+	}
+	return c.conn.SendPacket(secure, message.MsgTypeSecure, packetID)
+}
+
+// seal encrypts a message into a SecureMessage for the given PacketID.
+func (c *Connection) seal(msg message.Message, msgType message.MsgType, packetID uint8) (*message.SecureMessage, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("%w: message is nil", transport.ErrInvalidMessageSize)
+	}
+
+	keyID, err := c.sendKeyID()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.config.Keyring.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := codec.MarshalPayload(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	secure := &message.SecureMessage{KeyID: keyID}
+	secure.Session, secure.Counter = c.nextCounter()
+
+	plaintext := make([]byte, 0, 1+len(payload))
+	plaintext = append(plaintext, byte(msgType))
+	plaintext = append(plaintext, payload...)
+
+	secure.Ciphertext = aead.Seal(nil, nonce(secure, packetID), plaintext, additionalData(secure, packetID))
+	return secure, nil
+}
+
+// sendKeyID returns the key used for sending.
+func (c *Connection) sendKeyID() (uint8, error) {
+	if !c.config.ReplyKey {
+		return c.config.KeyID, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.hasPeerKey {
+		return 0, ErrNoPeerKey
+	}
+	return c.peerKeyID, nil
+}
+
+// nextCounter returns the session and counter for the next message, starting a new
+// session before the counter reaches the direction bit so nonces are never reused.
+func (c *Connection) nextCounter() (uint64, uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counter == maxCounter {
+		c.session = newSession()
+		c.counter = 0
+	}
+	c.counter++
+
+	if c.config.ReplyKey {
+		return c.session, c.counter | directionBit
+	}
+	return c.session, c.counter
+}
+
+// open verifies and decrypts a received secure message.
+func (c *Connection) open(envelope *message.Envelope) (*message.Envelope, error) {
+	secure, ok := envelope.Payload.(*message.SecureMessage)
+	if !ok {
+		if c.config.AllowPlaintext {
+			return envelope, nil
+		}
+		return nil, fmt.Errorf("%w: type 0x%02x", ErrPlaintext, uint8(envelope.Header.Type))
+	}
+
+	if secure.Session > maxSession {
+		return nil, fmt.Errorf("%w: session 0x%x exceeds 56 bits", ErrAuthFailed, secure.Session)
+	}
+
+	aead, err := c.config.Keyring.aead(secure.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, nonce(secure, envelope.Header.PacketID), secure.Ciphertext, additionalData(secure, envelope.Header.PacketID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: key 0x%02x, packet %d", ErrAuthFailed, secure.KeyID, envelope.Header.PacketID)
+	}
+
+	if err := c.config.Keyring.accept(secure.KeyID, secure.Session, secure.Counter); err != nil {
+		return nil, err
+	}
+
+	if len(plaintext) == 0 {
+		return nil, fmt.Errorf("%w: missing message type", ErrInvalidData)
+	}
+	msgType := message.MsgType(plaintext[0])
+	msg, err := codec.UnmarshalPayload(msgType, plaintext[1:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidData, err)
+	}
+
+	c.mu.Lock()
+	c.peerKeyID = secure.KeyID
+	c.hasPeerKey = true
+	c.mu.Unlock()
+
+	header := envelope.Header
+	header.Type = msgType
+	header.Length = uint16(len(plaintext) - 1)
+
+	return &message.Envelope{
+		Header:     header,
+		Payload:    msg,
+		Footer:     envelope.Footer,
+		ReceivedAt: envelope.ReceivedAt,
+		Raw:        envelope.Raw,
+	}, nil
+}
+
+// nonce builds the 12-byte AES-GCM nonce from the PacketID of the frame, the 56-bit
+// session, and the counter, including its direction bit.
+func nonce(secure *message.SecureMessage, packetID uint8) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[0:8], secure.Session)
+	n[0] = packetID
+	binary.BigEndian.PutUint32(n[8:12], secure.Counter)
+	return n
+}
+
+// additionalData returns the clear fields and the PacketID of the frame, which are
+// authenticated along with the ciphertext.
+func additionalData(secure *message.SecureMessage, packetID uint8) []byte {
+	ad := make([]byte, 14)
+	ad[0] = secure.KeyID
+	binary.BigEndian.PutUint64(ad[1:9], secure.Session)
+	binary.BigEndian.PutUint32(ad[9:13], secure.Counter)
+	ad[13] = packetID
+	return ad
+}
+
+// Receive returns the next decrypted message.
+func (c *Connection) Receive() (message.Message, error) {
+	envelope, err := c.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveContext returns the next decrypted message like Receive, giving up when ctx
+// is canceled or its deadline passes.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	envelope, err := c.incoming.ReceiveContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Payload, nil
+}
+
+// ReceiveEnvelope returns the next decrypted message with its packet metadata. The header
+// describes the inner message; Raw holds the encrypted packet as received. Messages that
+// fail verification are reported as errors wrapping ErrAuthFailed, ErrReplay, ErrUnknownKey,
+// or ErrPlaintext, and the connection stays usable.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	return c.incoming.Receive()
+}
+
+// Stats returns the statistics of the underlying connection.
+func (c *Connection) Stats() transport.Stats {
+	return transport.StatsOf(c.conn)
+}
+
+// Flush writes packets buffered by the underlying connection.
+func (c *Connection) Flush() error {
+	return transport.Flush(c.conn)
+}
+
+// State returns the state of the underlying connection, or disconnected after Close.
+func (c *Connection) State() transport.ConnectionState {
+	select {
+	case <-c.done:
+		return transport.StateDisconnected
+	default:
+		return c.conn.State()
+	}
+}
+
+// Subscribe registers fn for the state transitions of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	unsubscribe, _ = transport.SubscribeState(c.conn, fn)
+	return unsubscribe
+}
+
+// ObserveCodec registers fn for the codec operations of the underlying connection and
+// returns a function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	remove, _ = transport.ObserveCodec(c.conn, fn)
+	return remove
+}
+
+// PeerIdentity returns the verified certificate of the underlying connection's peer.
+func (c *Connection) PeerIdentity() (*x509.Certificate, bool) {
+	return transport.PeerIdentityOf(c.conn)
+}
+
+// Close stops the read loop and closes the underlying connection.
+func (c *Connection) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// readLoop receives from the underlying connection until it fails or is closed.
+func (c *Connection) readLoop() {
+	err := c.incoming.ReadLoop(c.conn, c.handle, transport.IsTerminal)
+	if err == nil {
+		err = fmt.Errorf("%w: secure connection closed", transport.ErrConnectionClosed)
+	}
+	c.incoming.Close(err)
+}
+
+// handle verifies and decrypts a received envelope and queues the result for Receive,
+// blocking until there is room. It reports false once the connection is closed.
+func (c *Connection) handle(envelope *message.Envelope) bool {
+	opened, err := c.open(envelope)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", transport.ErrReceiveFailed, err)
+	}
+	return c.incoming.Deliver(opened, err)
+}
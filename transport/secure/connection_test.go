@@ -0,0 +1,372 @@
+package secure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"kinetica-protocol/transport/internal/transporttest"
+	"testing"
+	"time"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 16)
+
+func testKeyring(t *testing.T, keyIDs ...uint8) *Keyring {
+	t.Helper()
+
+	k := NewKeyring()
+	for _, id := range keyIDs {
+		if err := k.Add(id, testKey); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	return k
+}
+
+func command() *message.SensorCommand {
+	return &message.SensorCommand{SensorID: 1, Command: 0x01}
+}
+
+func receiveWithin(t *testing.T, conn transport.Connection, d time.Duration) (message.Message, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return conn.ReceiveContext(ctx)
+}
+
+func TestConnection_RoundTrip(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, Config{Keyring: testKeyring(t, 1), KeyID: 1})
+	receiver := NewConnection(b, Config{Keyring: testKeyring(t, 1)})
+	defer sender.Close()
+	defer receiver.Close()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	wire := a.LastSent()
+	if wire.Header.Type != message.MsgTypeSecure {
+		t.Errorf("Expected wire type 0x%02x, got 0x%02x", message.MsgTypeSecure, wire.Header.Type)
+	}
+	if session := wire.Payload.(*message.SecureMessage).Session; session > maxSession {
+		t.Errorf("Expected a 56-bit session, got 0x%x", session)
+	}
+
+	envelope, err := receiver.ReceiveEnvelope()
+	if err != nil {
+		t.Fatalf("ReceiveEnvelope() error = %v", err)
+	}
+	if envelope.Header.Type != message.MsgTypeCommand {
+		t.Errorf("Expected header type 0x%02x, got 0x%02x", message.MsgTypeCommand, envelope.Header.Type)
+	}
+	if envelope.Header.PacketID != wire.Header.PacketID {
+		t.Errorf("Expected PacketID %d, got %d", wire.Header.PacketID, envelope.Header.PacketID)
+	}
+	cmd, ok := envelope.Payload.(*message.SensorCommand)
+	if !ok || cmd.SensorID != 1 || cmd.Command != 0x01 {
+		t.Errorf("Unexpected payload %+v", envelope.Payload)
+	}
+}
+
+func TestConnection_Tampered(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	a.SetDrop(transporttest.DropAll)
+	sender := NewConnection(a, Config{Keyring: testKeyring(t, 1), KeyID: 1})
+	receiver := NewConnection(b, Config{Keyring: testKeyring(t, 1)})
+	defer sender.Close()
+	defer receiver.Close()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	wire := a.LastSent()
+	secure := *wire.Payload.(*message.SecureMessage)
+
+	tampered := secure
+	tampered.Ciphertext = bytes.Clone(secure.Ciphertext)
+	tampered.Ciphertext[0] ^= 0xFF
+	a.Inject(&message.Envelope{Header: wire.Header, Payload: &tampered})
+
+	if _, err := receiveWithin(t, receiver, time.Second); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for modified ciphertext, got %v", err)
+	}
+
+	moved := secure
+	moved.Counter++
+	a.Inject(&message.Envelope{Header: wire.Header, Payload: &moved})
+
+	if _, err := receiveWithin(t, receiver, time.Second); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for modified counter, got %v", err)
+	}
+
+	header := wire.Header
+	header.PacketID++
+	a.Inject(&message.Envelope{Header: header, Payload: &secure})
+
+	if _, err := receiveWithin(t, receiver, time.Second); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for modified PacketID, got %v", err)
+	}
+
+	wide := secure
+	wide.Session |= 1 << 60
+	a.Inject(&message.Envelope{Header: wire.Header, Payload: &wide})
+
+	if _, err := receiveWithin(t, receiver, time.Second); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for a session wider than 56 bits, got %v", err)
+	}
+}
+
+func TestNonce_Layout(t *testing.T) {
+	secure := &message.SecureMessage{KeyID: 1, Session: 0x00A1B2C3D4E5F607, Counter: directionBit | 9}
+
+	want := []byte{0x2A, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0xF6, 0x07, 0x80, 0x00, 0x00, 0x09}
+	if got := nonce(secure, 0x2A); !bytes.Equal(got, want) {
+		t.Errorf("nonce = %x, want %x", got, want)
+	}
+}
+
+func TestConnection_Replay(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, Config{Keyring: testKeyring(t, 1), KeyID: 1})
+	receiver := NewConnection(b, Config{Keyring: testKeyring(t, 1)})
+	defer sender.Close()
+	defer receiver.Close()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if _, err := receiveWithin(t, receiver, time.Second); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+
+	a.Inject(a.LastSent())
+
+	if _, err := receiveWithin(t, receiver, time.Second); !errors.Is(err, ErrReplay) {
+		t.Errorf("Expected ErrReplay, got %v", err)
+	}
+}
+
+func TestConnection_SharedKeySessions(t *testing.T) {
+	keyring := testKeyring(t, 1)
+	a1, b1 := transporttest.NewPipe()
+	a2, b2 := transporttest.NewPipe()
+	first := NewConnection(a1, Config{Keyring: testKeyring(t, 1), KeyID: 1})
+	second := NewConnection(a2, Config{Keyring: testKeyring(t, 1), KeyID: 1})
+	r1 := NewConnection(b1, Config{Keyring: keyring})
+	r2 := NewConnection(b2, Config{Keyring: keyring})
+	defer first.Close()
+	defer second.Close()
+	defer r1.Close()
+	defer r2.Close()
+
+	exchange := func(sender, receiver *Connection) {
+		t.Helper()
+		if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if _, err := receiveWithin(t, receiver, time.Second); err != nil {
+			t.Fatalf("Receive() error = %v", err)
+		}
+	}
+
+	exchange(first, r1)
+	replayed := a1.LastSent()
+	exchange(second, r2)
+	exchange(first, r1)
+	exchange(second, r2)
+
+	a1.Inject(replayed)
+	if _, err := receiveWithin(t, r1, time.Second); !errors.Is(err, ErrReplay) {
+		t.Errorf("Expected ErrReplay, got %v", err)
+	}
+}
+
+func TestConnection_Reordered(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	a.SetDrop(transporttest.DropAll)
+	sender := NewConnection(a, Config{Keyring: testKeyring(t, 1), KeyID: 1})
+	receiver := NewConnection(b, Config{Keyring: testKeyring(t, 1)})
+	defer sender.Close()
+	defer receiver.Close()
+
+	var sent []*message.Envelope
+	for range 3 {
+		if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		sent = append(sent, a.LastSent())
+	}
+
+	for i := len(sent) - 1; i >= 0; i-- {
+		a.Inject(sent[i])
+	}
+
+	for i := range sent {
+		if _, err := receiveWithin(t, receiver, time.Second); err != nil {
+			t.Errorf("Receive() %d error = %v", i, err)
+		}
+	}
+}
+
+func TestConnection_UnknownKey(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sender := NewConnection(a, Config{Keyring: testKeyring(t, 2), KeyID: 2})
+	receiver := NewConnection(b, Config{Keyring: testKeyring(t, 1)})
+	defer sender.Close()
+	defer receiver.Close()
+
+	if err := sender.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if _, err := receiveWithin(t, receiver, time.Second); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+
+	if err := receiver.Send(command(), message.MsgTypeCommand); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey on send, got %v", err)
+	}
+}
+
+func TestConnection_Plaintext(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	strict := NewConnection(b, Config{Keyring: testKeyring(t, 1)})
+	defer strict.Close()
+
+	if err := a.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if _, err := receiveWithin(t, strict, time.Second); !errors.Is(err, ErrPlaintext) {
+		t.Errorf("Expected ErrPlaintext, got %v", err)
+	}
+
+	c, d := transporttest.NewPipe()
+	lenient := NewConnection(d, Config{Keyring: testKeyring(t, 1), AllowPlaintext: true})
+	defer lenient.Close()
+
+	if err := c.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	msg, err := receiveWithin(t, lenient, time.Second)
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if _, ok := msg.(*message.SensorCommand); !ok {
+		t.Errorf("Expected SensorCommand, got %T", msg)
+	}
+}
+
+func TestConnection_ReplyKey(t *testing.T) {
+	a, b := transporttest.NewPipe()
+	sensor := NewConnection(a, Config{Keyring: testKeyring(t, 7), KeyID: 7})
+	server := NewConnection(b, Config{Keyring: testKeyring(t, 1, 7), ReplyKey: true})
+	defer sensor.Close()
+	defer server.Close()
+
+	if err := server.Send(command(), message.MsgTypeCommand); !errors.Is(err, ErrNoPeerKey) {
+		t.Errorf("Expected ErrNoPeerKey before first message, got %v", err)
+	}
+
+	if err := sensor.Send(command(), message.MsgTypeCommand); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if _, err := receiveWithin(t, server, time.Second); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+
+	if err := server.Send(&message.Ack{SensorID: 1, Status: message.AckOK}, message.MsgTypeAck); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	reply := b.LastSent().Payload.(*message.SecureMessage)
+	if reply.KeyID != 7 {
+		t.Errorf("Expected reply under key 7, got %d", reply.KeyID)
+	}
+	if reply.Counter&directionBit == 0 {
+		t.Errorf("Expected direction bit on reply counter %08x", reply.Counter)
+	}
+	if counter := a.LastSent().Payload.(*message.SecureMessage).Counter; counter&directionBit != 0 {
+		t.Errorf("Unexpected direction bit on sensor counter %08x", counter)
+	}
+	if _, err := receiveWithin(t, sensor, time.Second); err != nil {
+		t.Errorf("Receive() error = %v", err)
+	}
+}
+
+func TestConnection_Close(t *testing.T) {
+	a, _ := transporttest.NewPipe()
+	conn := NewConnection(a, Config{Keyring: testKeyring(t, 1), KeyID: 1})
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := conn.Receive(); !errors.Is(err, transport.ErrConnectionClosed) {
+		t.Errorf("Expected ErrConnectionClosed, got %v", err)
+	}
+	if state := conn.State(); state != transport.StateDisconnected {
+		t.Errorf("Expected StateDisconnected, got %v", state)
+	}
+}
+
+func TestKeyring_InvalidKey(t *testing.T) {
+	if err := NewKeyring().Add(1, make([]byte, 15)); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestKeyring_ReplayWindow(t *testing.T) {
+	k := testKeyring(t, 1)
+
+	steps := []struct {
+		session uint64
+		counter uint32
+		replay  bool
+	}{
+		{1, 10, false},
+		{1, 10, true},
+		{1, 5, false},
+		{1, 5, true},
+		{1, 10 + ReplayWindow, false},
+		{1, 10, true},
+		{2, 1, false},
+		{1, 100, false},
+		{2, 2, false},
+		{2, 1, true},
+		{1, 100, true},
+	}
+
+	for i, s := range steps {
+		err := k.accept(1, s.session, s.counter)
+		if s.replay != errors.Is(err, ErrReplay) {
+			t.Errorf("Step %d (session %d, counter %d): error = %v, want replay %v", i, s.session, s.counter, err, s.replay)
+		}
+	}
+}
+
+func TestKeyring_SessionEviction(t *testing.T) {
+	k := testKeyring(t, 1)
+
+	for session := range uint64(MaxSessions) {
+		if err := k.accept(1, session, 1); err != nil {
+			t.Fatalf("accept(session %d) error = %v", session, err)
+		}
+	}
+	if err := k.accept(1, 0, 2); err != nil {
+		t.Fatalf("accept(session 0, counter 2) error = %v", err)
+	}
+	if err := k.accept(1, MaxSessions, 1); err != nil {
+		t.Fatalf("accept(new session) error = %v", err)
+	}
+
+	if n := len(k.replay[1]); n != MaxSessions {
+		t.Errorf("Tracked %d sessions, want %d", n, MaxSessions)
+	}
+	if _, ok := k.replay[1][1]; ok {
+		t.Error("Least recently active session was not evicted")
+	}
+	if err := k.accept(1, 0, 2); !errors.Is(err, ErrReplay) {
+		t.Errorf("Expected ErrReplay for recently active session, got %v", err)
+	}
+}
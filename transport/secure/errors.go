@@ -0,0 +1,14 @@
+package secure
+
+import "errors"
+
+// Secure connection error definitions.
+var (
+	ErrInvalidKey  = errors.New("invalid key size")              // Key is not 16, 24, or 32 bytes long
+	ErrUnknownKey  = errors.New("unknown key")                   // No key with the message's key ID
+	ErrNoPeerKey   = errors.New("no peer key")                   // ReplyKey is set but no message has been authenticated yet
+	ErrAuthFailed  = errors.New("message authentication failed") // Ciphertext or clear fields were tampered with or the key is wrong
+	ErrReplay      = errors.New("replayed message")              // Session and counter were already seen or are outside the replay window
+	ErrPlaintext   = errors.New("unencrypted message rejected")  // Message was not a SecureMessage and AllowPlaintext is false
+	ErrInvalidData = errors.New("invalid decrypted data")        // Decrypted data does not hold a message
+)
@@ -0,0 +1,149 @@
+package secure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"sync"
+)
+
+// ReplayWindow is the number of most recent counters tracked per session, so messages
+// reordered by up to this many positions are still accepted once.
+const ReplayWindow = 64
+
+// MaxSessions is the number of sender sessions whose replay state is kept per key. Once
+// a key has this many, the least recently active session is forgotten to make room for a
+// new one, so concurrent senders sharing a key do not disturb each other. Messages of a
+// forgotten session are no longer recognized as replays.
+const MaxSessions = 64
+
+// replayState tracks the accepted counters of one sender session.
+type replayState struct {
+	highest  uint32 // Highest counter accepted in the session
+	window   uint64 // Bit i set if counter highest-i has been accepted
+	lastSeen uint64 // Keyring tick of the last accepted message, for eviction
+}
+
+// Keyring holds pre-shared AES keys by key ID together with the replay state of the
+// sessions received under each key. Replay state tracks the messages an endpoint
+// receives, so the two ends of a link need separate keyrings. It lives only in memory
+// and starts empty, so keys should be replaced when a process restarts if replays of
+// earlier traffic must be rejected. It is safe for concurrent use.
+type Keyring struct {
+	mu     sync.Mutex                        // Guards keys, replay, and tick
+	keys   map[uint8]cipher.AEAD             // AES-GCM instances by key ID
+	replay map[uint8]map[uint64]*replayState // Replay state by key ID and session
+	tick   uint64                            // Incremented on every accepted message
+}
+
+// NewKeyring creates an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys:   make(map[uint8]cipher.AEAD),
+		replay: make(map[uint8]map[uint64]*replayState),
+	}
+}
+
+// Add installs a 16, 24, or 32 byte AES key under keyID, replacing any previous key
+// and resetting its replay state.
+func (k *Keyring) Add(keyID uint8, key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("%w: %d bytes", ErrInvalidKey, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[keyID] = aead
+	delete(k.replay, keyID)
+	return nil
+}
+
+// Remove deletes the key with keyID and its replay state.
+func (k *Keyring) Remove(keyID uint8) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, keyID)
+	delete(k.replay, keyID)
+}
+
+// aead returns the cipher for keyID.
+func (k *Keyring) aead(keyID uint8) (cipher.AEAD, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: 0x%02x", ErrUnknownKey, keyID)
+	}
+	return aead, nil
+}
+
+// accept records an authenticated session and counter of keyID, or returns ErrReplay
+// if they were accepted before or fall behind the replay window of the session. A new
+// session never affects the others; beyond MaxSessions per key, the least recently active
+// session is forgotten.
+func (k *Keyring) accept(keyID uint8, session uint64, counter uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.tick++
+
+	sessions, ok := k.replay[keyID]
+	if !ok {
+		sessions = make(map[uint64]*replayState)
+		k.replay[keyID] = sessions
+	}
+
+	state, ok := sessions[session]
+	if !ok {
+		if len(sessions) >= MaxSessions {
+			evictOldest(sessions)
+		}
+		sessions[session] = &replayState{highest: counter, window: 1, lastSeen: k.tick}
+		return nil
+	}
+
+	if counter > state.highest {
+		shift := counter - state.highest
+		if shift >= ReplayWindow {
+			state.window = 0
+		} else {
+			state.window <<= shift
+		}
+		state.window |= 1
+		state.highest = counter
+		state.lastSeen = k.tick
+		return nil
+	}
+
+	offset := state.highest - counter
+	if offset >= ReplayWindow || state.window&(1<<offset) != 0 {
+		return fmt.Errorf("%w: session %016x counter %d", ErrReplay, session, counter)
+	}
+	state.window |= 1 << offset
+	state.lastSeen = k.tick
+	return nil
+}
+
+// evictOldest forgets the least recently active session.
+func evictOldest(sessions map[uint64]*replayState) {
+	var oldest uint64
+	var found *replayState
+	for session, state := range sessions {
+		if found == nil || state.lastSeen < found.lastSeen {
+			oldest, found = session, state
+		}
+	}
+	delete(sessions, oldest)
+}
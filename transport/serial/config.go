@@ -5,6 +5,7 @@ package serial
 
 import (
 	s "go.bug.st/serial"
+	"kinetica-protocol/protocol/message"
 	"time"
 )
 
@@ -23,4 +24,16 @@ type Config struct {
 
 	// Protocol timeouts
 	ReadTimeout time.Duration // Timeout for read operations
+
+	// Frame validation
+	CRC     message.TransportCRC // Footer type matching the device firmware (0 = TransportCRC)
+	MACKeys *message.MACKeyring  // Keys of HMAC footers, required when CRC is TransportHMAC64 or TransportHMAC128
+}
+
+// transportCRC returns the configured footer type, or TransportCRC if none is set.
+func (c Config) transportCRC() message.TransportCRC {
+	if c.CRC == 0 {
+		return TransportCRC
+	}
+	return c.CRC
 }
@@ -20,25 +20,48 @@ import (
 type Connection struct {
 	conn           serial.Port              // Serial port interface
 	ctx            context.Context          // Context for operation cancellation
+	port           *portReader              // Cancelable reader over the serial port
 	reader         *bufio.Reader            // Buffered reader for efficient message parsing
+	frames         *codec.FrameReader       // Resynchronizing frame reader over the buffered stream
 	readTimeout    time.Duration            // Timeout for read operations
 	packetID       atomic.Uint32            // Atomic counter for unique packet IDs
 	transportCRC   message.TransportCRC     // CRC type for this transport
+	macKeys        *message.MACKeyring      // Keys of HMAC footers (nil for none)
 	maxMessageSize int                      // Maximum message size for this transport
+	stats          transport.Counters       // Traffic and error counters
+	observers      transport.CodecObservers // Observers of encode and decode operations
+	state          transport.StateTracker   // Connection state updated by I/O outcomes
+	stopWatch      func() bool              // Stops watching ctx for cancellation
 }
 
 // NewConnection creates a new serial connection wrapper with protocol support.
 // It configures timeouts, CRC validation, and message size limits for the serial port.
 func NewConnection(conn serial.Port, ctx context.Context, readTimeout time.Duration, transportCRC message.TransportCRC, maxMessageSize int) *Connection {
-	return &Connection{
+	port := newPortReader(conn, ctx)
+	reader := bufio.NewReaderSize(port, codec.FrameBufferSize(maxMessageSize))
+	c := &Connection{
 		conn:           conn,
 		ctx:            ctx,
-		reader:         bufio.NewReader(conn),
+		port:           port,
+		reader:         reader,
+		frames:         codec.NewFrameReader(reader, transportCRC, maxMessageSize),
 		readTimeout:    readTimeout,
 		packetID:       atomic.Uint32{},
 		transportCRC:   transportCRC,
 		maxMessageSize: maxMessageSize,
 	}
+	c.stopWatch = context.AfterFunc(ctx, func() {
+		c.state.Set(transport.StateDisconnected, ctx.Err())
+	})
+	return c
+}
+
+// useMACKeys sets the keys used to compute and verify HMAC footers and returns c.
+// It must be called before the connection is used.
+func (c *Connection) useMACKeys(keys *message.MACKeyring) *Connection {
+	c.macKeys = keys
+	c.frames.SetMACKeyring(keys)
+	return c
 }
 
 // getNextPacketID generates a unique packet ID using atomic increment with wraparound.
@@ -48,13 +71,33 @@ func (c *Connection) getNextPacketID() uint8 {
 }
 
 // Send encodes and transmits a protocol message over the serial connection.
-// It validates message size and handles partial writes for serial communication.
+// A new PacketID is taken from the connection's counter.
 func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
+	return c.send(context.Background(), msg, msgType, c.getNextPacketID())
+}
+
+// SendContext encodes and transmits a protocol message like Send, failing if ctx is
+// already done. Serial writes cannot be interrupted once started.
+func (c *Connection) SendContext(ctx context.Context, msg message.Message, msgType message.MsgType) error {
+	return c.send(ctx, msg, msgType, c.getNextPacketID())
+}
+
+// SendPacket encodes and transmits a protocol message over the serial connection
+// using the given PacketID.
+func (c *Connection) SendPacket(msg message.Message, msgType message.MsgType, packetID uint8) error {
+	return c.send(context.Background(), msg, msgType, packetID)
+}
+
+// send encodes and writes a message with the given PacketID.
+// It validates message size and handles partial writes for serial communication.
+func (c *Connection) send(ctx context.Context, msg message.Message, msgType message.MsgType, packetID uint8) error {
 	if msg == nil {
 		return fmt.Errorf("%w: message is nil", transport.ErrInvalidMessageSize)
 	}
 
-	binaryMsg, err := codec.Marshal(msg, c.getNextPacketID(), msgType, c.transportCRC)
+	start := time.Now()
+	binaryMsg, err := codec.MarshalKeyed(msg, packetID, msgType, c.transportCRC, c.macKeys)
+	c.observers.Notify(transport.CodecEncode, start, err)
 	if err != nil {
 		return fmt.Errorf("%w: failed to marshal message: %w", transport.ErrSendFailed, err)
 	}
@@ -63,111 +106,171 @@ func (c *Connection) Send(msg message.Message, msgType message.MsgType) error {
 		return fmt.Errorf("%w: message size %d exceeds maximum %d bytes", transport.ErrMsgLarge, len(binaryMsg), c.maxMessageSize)
 	}
 
-	select {
-	case <-c.ctx.Done():
-		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, c.ctx.Err())
-	default:
+	if err := c.contextErr(ctx); err != nil {
+		return err
 	}
 
 	n, err := c.conn.Write(binaryMsg)
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
+			c.stats.AddTimeout()
 			return fmt.Errorf("%w: %w", transport.ErrWriteTimeout, err)
 		}
 		if errors.Is(err, net.ErrClosed) {
-			return fmt.Errorf("%w: %w", transport.ErrConnectionClosed, err)
+			err = fmt.Errorf("%w: %w", transport.ErrConnectionClosed, err)
+		} else {
+			err = fmt.Errorf("%w: failed to write %d bytes: %w", transport.ErrSendFailed, len(binaryMsg), err)
 		}
-		return fmt.Errorf("%w: failed to write %d bytes: %w", transport.ErrSendFailed, len(binaryMsg), err)
+		c.state.Set(transport.StateDisconnected, err)
+		return err
 	}
 
 	if n != len(binaryMsg) {
 		return fmt.Errorf("%w: partial write: wrote %d of %d bytes", transport.ErrSendFailed, n, len(binaryMsg))
 	}
 
+	c.stats.AddSent(n)
 	return nil
 }
 
-// Receive reads and decodes a protocol message from the serial connection.
-// It handles timeouts and validates message integrity with CRC checking.
+// Receive reads and decodes a protocol message, discarding the packet metadata.
 func (c *Connection) Receive() (message.Message, error) {
-	select {
-	case <-c.ctx.Done():
-		return nil, fmt.Errorf("%w: %w", transport.ErrContextCanceled, c.ctx.Err())
-	default:
-	}
-
-	if c.readTimeout > 0 {
-		if err := c.conn.SetReadTimeout(c.readTimeout); err != nil {
-			return nil, fmt.Errorf("%w: failed to set read deadline: %w", transport.ErrReceiveFailed, err)
-		}
+	envelope, err := c.ReceiveEnvelope()
+	if err != nil {
+		return nil, err
 	}
+	return envelope.Payload, nil
+}
 
-	headerBuf := make([]byte, message.HeaderSize)
-	n, err := io.ReadFull(c.reader, headerBuf)
+// ReceiveContext reads and decodes a protocol message like Receive, returning early
+// when ctx is canceled or its deadline passes. The read timeout still applies.
+// A partially received frame stays buffered for the next call.
+func (c *Connection) ReceiveContext(ctx context.Context) (message.Message, error) {
+	envelope, err := c.receive(ctx)
 	if err != nil {
-		if err == io.EOF {
-			return nil, fmt.Errorf("%w: connection closed by peer", transport.ErrConnectionClosed)
-		}
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			return nil, fmt.Errorf("%w: %w", transport.ErrReadTimeout, err)
-		}
-		return nil, fmt.Errorf("%w: failed to read header (%d/%d bytes): %w", transport.ErrReceiveFailed, n, message.HeaderSize, err)
+		return nil, err
 	}
+	return envelope.Payload, nil
+}
 
-	payloadLength := headerBuf[5]
-	if int(payloadLength) > c.maxMessageSize {
-		return nil, fmt.Errorf("%w: payload length %d exceeds maximum %d", transport.ErrMsgLarge, payloadLength, c.maxMessageSize)
-	}
+// ReceiveEnvelope reads and decodes a protocol message from the serial connection.
+// The returned envelope includes the packet header, footer, raw bytes, and receive time.
+func (c *Connection) ReceiveEnvelope() (*message.Envelope, error) {
+	return c.receive(context.Background())
+}
 
-	footerSize := message.GetFooterSize(c.transportCRC)
-	remainingSize := int(payloadLength) + footerSize
-	remainingBuf := make([]byte, remainingSize)
+// receive reads the next frame and decodes it into an envelope.
+// It handles timeouts and cancellation and validates message integrity with CRC checking.
+func (c *Connection) receive(ctx context.Context) (*message.Envelope, error) {
+	if err := c.contextErr(ctx); err != nil {
+		return nil, err
+	}
 
-	n, err = io.ReadFull(c.reader, remainingBuf)
+	c.port.begin(ctx, deadline(ctx, c.readTimeout))
+	frame, err := c.frames.ReadFrame()
 	if err != nil {
-		if err == io.EOF {
-			return nil, fmt.Errorf("%w: connection closed while reading payload", transport.ErrConnectionClosed)
+		if ctxErr := c.contextErr(ctx); ctxErr != nil {
+			return nil, ctxErr
 		}
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			return nil, fmt.Errorf("%w: %w", transport.ErrReadTimeout, err)
-		}
-		return nil, fmt.Errorf("%w: failed to read payload (%d/%d bytes): %w", transport.ErrReceiveFailed, n, remainingSize, err)
+		return nil, c.readError(err)
 	}
 
-	fullMessage := append(headerBuf, remainingBuf...)
-	msg, err := codec.Unmarshal(fullMessage, c.transportCRC)
+	start := time.Now()
+	envelope, err := codec.UnmarshalFrameKeyed(frame, c.transportCRC, c.macKeys)
+	c.observers.Notify(transport.CodecDecode, start, err)
 	if err != nil {
+		c.stats.AddDecodeError()
 		return nil, fmt.Errorf("%w: failed to unmarshal message: %w", transport.ErrReceiveFailed, err)
 	}
 
-	return msg, nil
+	c.stats.AddReceived(len(frame), envelope.Header.PacketID)
+	envelope.ReceivedAt = time.Now()
+	return envelope, nil
 }
 
-// State returns the current connection state by checking context and port status.
-func (c *Connection) State() transport.ConnectionState {
-	select {
-	case <-c.ctx.Done():
-		return transport.StateDisconnected
-	default:
+// contextErr returns ErrContextCanceled if either the call context or the
+// connection context is done, and nil otherwise.
+func (c *Connection) contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
 	}
+	if err := c.ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", transport.ErrContextCanceled, err)
+	}
+	return nil
+}
 
-	_, err := c.reader.Peek(1)
-	if err == nil {
-		return transport.StateConnected
+// deadline returns the earlier of the context deadline and now plus timeout.
+// A zero time, meaning no deadline, is returned if neither is set.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var d time.Time
+	if timeout > 0 {
+		d = time.Now().Add(timeout)
 	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (d.IsZero() || ctxDeadline.Before(d)) {
+		d = ctxDeadline
+	}
+	return d
+}
 
+// readError maps a frame reader error to the matching transport error.
+// Errors other than timeouts mean the port is gone and disconnect the connection.
+func (c *Connection) readError(err error) error {
 	var netErr net.Error
 	if errors.As(err, &netErr) && netErr.Timeout() {
-		return transport.StateConnected
+		c.stats.AddTimeout()
+		return fmt.Errorf("%w: %w", transport.ErrReadTimeout, err)
+	}
+
+	if err == io.EOF {
+		err = fmt.Errorf("%w: connection closed by peer", transport.ErrConnectionClosed)
+	} else {
+		err = fmt.Errorf("%w: failed to read frame: %w", transport.ErrReceiveFailed, err)
+	}
+	c.state.Set(transport.StateDisconnected, err)
+	return err
+}
+
+// SkippedBytes returns the number of received bytes discarded while resynchronizing
+// the stream after corrupt or misaligned input.
+func (c *Connection) SkippedBytes() uint64 {
+	return c.frames.Skipped()
+}
+
+// Stats returns a snapshot of the connection's traffic and error counters.
+func (c *Connection) Stats() transport.Stats {
+	return c.stats.Snapshot(c.frames.CRCErrors(), c.frames.Skipped())
+}
+
+// State returns the connection state. It is StateDisconnected once the context ends,
+// the connection is closed, or a read or write on the port fails.
+func (c *Connection) State() transport.ConnectionState {
+	if c.ctx.Err() != nil {
+		return transport.StateDisconnected
 	}
+	return c.state.State()
+}
+
+// Subscribe registers fn to be called on every state transition and returns a function
+// that removes it.
+func (c *Connection) Subscribe(fn func(transport.StateChange)) (unsubscribe func()) {
+	return c.state.Subscribe(fn)
+}
 
-	return transport.StateDisconnected
+// ObserveCodec registers fn to be called after every encode and decode and returns a
+// function that removes it.
+func (c *Connection) ObserveCodec(fn func(transport.CodecEvent)) (remove func()) {
+	return c.observers.Observe(fn)
 }
 
 // Close terminates the serial connection and releases the port.
 func (c *Connection) Close() error {
+	c.state.Set(transport.StateClosing, nil)
+	defer c.state.Set(transport.StateDisconnected, nil)
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+
 	return c.conn.Close()
 }
@@ -0,0 +1,74 @@
+package serial
+
+import (
+	"context"
+	"go.bug.st/serial"
+	"time"
+)
+
+// pollInterval bounds each blocking port read so cancellation is noticed promptly.
+const pollInterval = 50 * time.Millisecond
+
+// timeoutError is returned by portReader when the read deadline passes.
+// It implements net.Error so it is reported as a read timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "serial read timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// portReader adapts a serial port to io.Reader with cancellation and an overall deadline.
+// The serial library reports an expired port timeout as a zero-byte read without error,
+// so the reader polls the port in short intervals and checks its contexts in between.
+type portReader struct {
+	port     serial.Port     // Serial port interface
+	ctx      context.Context // Connection lifetime context
+	call     context.Context // Context of the receive in progress
+	deadline time.Time       // Deadline of the receive in progress (zero for none)
+}
+
+// newPortReader creates a reader over the port bound to the connection context.
+func newPortReader(port serial.Port, ctx context.Context) *portReader {
+	return &portReader{
+		port: port,
+		ctx:  ctx,
+		call: context.Background(),
+	}
+}
+
+// begin sets the context and deadline for the next receive.
+func (r *portReader) begin(call context.Context, deadline time.Time) {
+	r.call = call
+	r.deadline = deadline
+}
+
+// Read implements io.Reader, returning when data arrives, a context is done,
+// or the deadline passes.
+func (r *portReader) Read(p []byte) (int, error) {
+	for {
+		if err := r.call.Err(); err != nil {
+			return 0, err
+		}
+		if err := r.ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		wait := pollInterval
+		if !r.deadline.IsZero() {
+			remaining := time.Until(r.deadline)
+			if remaining <= 0 {
+				return 0, timeoutError{}
+			}
+			wait = min(wait, remaining)
+		}
+
+		if err := r.port.SetReadTimeout(wait); err != nil {
+			return 0, err
+		}
+
+		n, err := r.port.Read(p)
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"go.bug.st/serial"
+	"kinetica-protocol/protocol/codec"
+	"kinetica-protocol/protocol/message"
+	"kinetica-protocol/transport"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockPort emulates a serial port whose reads return (0, nil) once the read timeout expires.
+type mockPort struct {
+	mu      sync.Mutex
+	data    []byte
+	timeout time.Duration
+	written []byte
+}
+
+func (m *mockPort) SetMode(mode *serial.Mode) error { return nil }
+
+func (m *mockPort) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	if len(m.data) > 0 {
+		n := copy(p, m.data)
+		m.data = m.data[n:]
+		m.mu.Unlock()
+		return n, nil
+	}
+	timeout := m.timeout
+	m.mu.Unlock()
+
+	time.Sleep(timeout)
+	return 0, nil
+}
+
+func (m *mockPort) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.written = append(m.written, p...)
+	return len(p), nil
+}
+
+func (m *mockPort) Drain() error                                         { return nil }
+func (m *mockPort) ResetInputBuffer() error                              { return nil }
+func (m *mockPort) ResetOutputBuffer() error                             { return nil }
+func (m *mockPort) SetDTR(dtr bool) error                                { return nil }
+func (m *mockPort) SetRTS(rts bool) error                                { return nil }
+func (m *mockPort) GetModemStatusBits() (*serial.ModemStatusBits, error) { return nil, nil }
+func (m *mockPort) Close() error                                         { return nil }
+func (m *mockPort) Break(time.Duration) error                            { return nil }
+
+func (m *mockPort) SetReadTimeout(t time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeout = t
+	return nil
+}
+
+func TestConnection_ReceiveContext(t *testing.T) {
+	frame, err := codec.Marshal(&message.SensorHeartbeat{SensorID: 3, Battery: 70, Status: message.Ok}, 1, message.MsgTypeHeartbeat, TransportCRC)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	conn := NewConnection(&mockPort{data: frame}, context.Background(), time.Second, TransportCRC, MaxMsgSize)
+
+	msg, err := conn.ReceiveContext(context.Background())
+	if err != nil {
+		t.Fatalf("ReceiveContext() error = %v", err)
+	}
+	if hb, ok := msg.(*message.SensorHeartbeat); !ok || hb.SensorID != 3 {
+		t.Errorf("Expected heartbeat from sensor 3, got %+v", msg)
+	}
+}
+
+func TestConnection_ReceiveContext_Canceled(t *testing.T) {
+	conn := NewConnection(&mockPort{}, context.Background(), 0, TransportCRC, MaxMsgSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := conn.ReceiveContext(ctx)
+	if !errors.Is(err, transport.ErrContextCanceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected ErrContextCanceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond+2*pollInterval {
+		t.Errorf("Expected cancellation within one poll interval, took %v", elapsed)
+	}
+}
+
+func TestConnection_Receive_Timeout(t *testing.T) {
+	conn := NewConnection(&mockPort{}, context.Background(), 30*time.Millisecond, TransportCRC, MaxMsgSize)
+
+	if _, err := conn.Receive(); !errors.Is(err, transport.ErrReadTimeout) {
+		t.Errorf("Expected ErrReadTimeout, got %v", err)
+	}
+	if timeouts := conn.Stats().Timeouts; timeouts != 1 {
+		t.Errorf("Expected 1 timeout to be counted, got %d", timeouts)
+	}
+}
+
+func TestConnection_SendContext_Canceled(t *testing.T) {
+	port := &mockPort{}
+	conn := NewConnection(port, context.Background(), 0, TransportCRC, MaxMsgSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := conn.SendContext(ctx, &message.SensorHeartbeat{SensorID: 1}, message.MsgTypeHeartbeat)
+	if !errors.Is(err, transport.ErrContextCanceled) {
+		t.Fatalf("Expected ErrContextCanceled, got %v", err)
+	}
+	if len(port.written) != 0 {
+		t.Errorf("Expected nothing written, got %d bytes", len(port.written))
+	}
+}
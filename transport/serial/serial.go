@@ -10,7 +10,7 @@ import (
 
 // Serial transport constants for protocol configuration.
 const (
-	TransportCRC = message.TransportCRC8 // 8-bit CRC for serial communication reliability
+	TransportCRC = message.TransportCRC8 // Default 8-bit CRC for serial communication reliability
 	MaxMsgSize   = 4 * 1024              // Maximum message size (4KB) for serial buffers
 )
 
@@ -51,7 +51,7 @@ func (t *Transport) Connection() (transport.Connection, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%w: can't open Port: %v: %w", transport.ErrConn, t.config.Port, err)
 	}
-	return NewConnection(port, t.ctx, t.config.ReadTimeout, TransportCRC, MaxMsgSize), nil
+	return NewConnection(port, t.ctx, t.config.ReadTimeout, t.config.transportCRC(), MaxMsgSize).useMACKeys(t.config.MACKeys), nil
 }
 
 // Listen opens a serial port for server mode and returns a single connection.
@@ -63,7 +63,7 @@ func (t *Transport) Listen() (<-chan transport.Connection, error) {
 	}
 
 	ch := make(chan transport.Connection)
-	ch <- NewConnection(port, t.ctx, t.config.ReadTimeout, TransportCRC, MaxMsgSize)
+	ch <- NewConnection(port, t.ctx, t.config.ReadTimeout, t.config.transportCRC(), MaxMsgSize).useMACKeys(t.config.MACKeys)
 	close(ch)
 
 	return ch, nil
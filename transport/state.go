@@ -0,0 +1,158 @@
+package transport
+
+import (
+	"sync"
+	"time"
+)
+
+// String returns the name of the connection state.
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosing:
+		return "closing"
+	default:
+		return "unknown"
+	}
+}
+
+// StateChange describes a connection state transition.
+type StateChange struct {
+	From ConnectionState // State before the transition
+	To   ConnectionState // State after the transition
+	Err  error           // Error that caused the transition (nil for orderly ones)
+	At   time.Time       // Time of the transition
+}
+
+// StateNotifier is implemented by connections that report state transitions.
+// Every built-in transport connection and connection wrapper implements it.
+type StateNotifier interface {
+	// Subscribe registers fn to be called on every state transition and returns
+	// a function that removes it. Callbacks run in the order of the transitions
+	// without holding any lock of the connection, so they may close it; the
+	// transitions they cause are delivered after they return. Callbacks should not
+	// block, as they hold back the notifications that follow.
+	Subscribe(fn func(StateChange)) (unsubscribe func())
+}
+
+// SubscribeState registers fn for the state transitions of conn. It reports false,
+// and returns a no-op unsubscribe function, if conn does not provide notifications.
+func SubscribeState(conn Connection, fn func(StateChange)) (unsubscribe func(), ok bool) {
+	if n, ok := conn.(StateNotifier); ok {
+		return n.Subscribe(fn), true
+	}
+	return func() {}, false
+}
+
+// StateTracker holds the state of a connection and notifies subscribers of transitions.
+// Transports update it from the outcome of actual reads and writes, so reading the
+// state never touches the underlying link. It is safe for concurrent use; the zero
+// value is ready to use and starts in StateConnected, the state of a new connection.
+type StateTracker struct {
+	mu        sync.Mutex                   // Guards all fields
+	state     ConnectionState              // Current state (zero for StateConnected)
+	err       error                        // Error that caused the last transition
+	subs      map[uint64]func(StateChange) // Subscribers by registration ID
+	nextID    uint64                       // ID of the next subscriber
+	pending   []StateChange                // Transitions not yet delivered to subscribers
+	notifying bool                         // Whether a Set call is delivering pending transitions
+}
+
+// NewStateTracker creates a tracker in the given initial state.
+func NewStateTracker(initial ConnectionState) *StateTracker {
+	return &StateTracker{state: initial}
+}
+
+// State returns the current state without blocking on I/O.
+func (t *StateTracker) State() ConnectionState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current()
+}
+
+// Err returns the error that caused the last transition, or nil.
+func (t *StateTracker) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Set moves to state, recording err as the cause, and notifies subscribers.
+// It reports whether the state changed; setting the current state again does nothing.
+// StateDisconnected is final: a connection that has ended never comes back, so later
+// transitions, such as StateClosing when a dead connection is closed, are ignored.
+//
+// Subscribers are called after the tracker's lock is released. Transitions are queued
+// and delivered in order by the Set call that finds no delivery in progress, so a Set
+// made from a callback, or concurrently with one, returns at once and its transition
+// is delivered after the running callback returns.
+func (t *StateTracker) Set(state ConnectionState, err error) bool {
+	t.mu.Lock()
+	from := t.current()
+	if from == state || from == StateDisconnected {
+		t.mu.Unlock()
+		return false
+	}
+	t.state = state
+	t.err = err
+	t.pending = append(t.pending, StateChange{From: from, To: state, Err: err, At: time.Now()})
+	if t.notifying {
+		t.mu.Unlock()
+		return true
+	}
+	t.notifying = true
+
+	for len(t.pending) > 0 {
+		change := t.pending[0]
+		t.pending = t.pending[1:]
+		subs := make([]func(StateChange), 0, len(t.subs))
+		for _, fn := range t.subs {
+			subs = append(subs, fn)
+		}
+		t.mu.Unlock()
+
+		for _, fn := range subs {
+			fn(change)
+		}
+		t.mu.Lock()
+	}
+
+	t.notifying = false
+	t.mu.Unlock()
+	return true
+}
+
+// Subscribe registers fn to be called on every transition and returns a function
+// that removes it. Callbacks run as described for Set.
+func (t *StateTracker) Subscribe(fn func(StateChange)) (unsubscribe func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.subs == nil {
+		t.subs = make(map[uint64]func(StateChange))
+	}
+	id := t.nextID
+	t.nextID++
+	t.subs[id] = fn
+
+	return func() {
+		t.mu.Lock()
+		delete(t.subs, id)
+		t.mu.Unlock()
+	}
+}
+
+// current returns the state, mapping the zero value to StateConnected. The caller holds mu.
+func (t *StateTracker) current() ConnectionState {
+	if t.state == 0 {
+		return StateConnected
+	}
+	return t.state
+}
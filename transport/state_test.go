@@ -0,0 +1,95 @@
+package transport
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStateTracker_Transitions(t *testing.T) {
+	var tracker StateTracker
+	if tracker.State() != StateConnected {
+		t.Fatalf("Expected zero value to be connected, got %v", tracker.State())
+	}
+
+	var changes []StateChange
+	unsubscribe := tracker.Subscribe(func(c StateChange) { changes = append(changes, c) })
+
+	errLost := errors.New("link lost")
+	if !tracker.Set(StateReconnecting, errLost) {
+		t.Error("Expected transition to reconnecting")
+	}
+	if tracker.Set(StateReconnecting, nil) {
+		t.Error("Expected setting the same state to do nothing")
+	}
+	tracker.Set(StateConnected, nil)
+	tracker.Set(StateDisconnected, errLost)
+	if tracker.Set(StateClosing, nil) {
+		t.Error("Expected disconnected to be final")
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 notifications, got %d", len(changes))
+	}
+	if changes[0].From != StateConnected || changes[0].To != StateReconnecting || changes[0].Err != errLost {
+		t.Errorf("Unexpected first change %+v", changes[0])
+	}
+	if tracker.State() != StateDisconnected || tracker.Err() != errLost {
+		t.Errorf("Expected disconnected with cause, got %v, %v", tracker.State(), tracker.Err())
+	}
+
+	unsubscribe()
+	other := NewStateTracker(StateConnecting)
+	other.Subscribe(func(c StateChange) {
+		if c.From != StateConnecting {
+			t.Errorf("Expected transition from connecting, got %v", c.From)
+		}
+	})
+	other.Set(StateConnected, nil)
+}
+
+func TestStateTracker_SetFromCallback(t *testing.T) {
+	var tracker StateTracker
+
+	var changes []StateChange
+	tracker.Subscribe(func(c StateChange) {
+		changes = append(changes, c)
+		if c.To == StateReconnecting {
+			if !tracker.Set(StateConnected, nil) {
+				t.Error("Expected transition from a callback to succeed")
+			}
+			if len(changes) != 1 {
+				t.Error("Expected the nested transition to be delivered after the callback")
+			}
+		}
+	})
+
+	tracker.Set(StateReconnecting, nil)
+
+	if len(changes) != 2 || changes[1].From != StateReconnecting || changes[1].To != StateConnected {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+}
+
+func TestSubscribeState_Unsupported(t *testing.T) {
+	unsubscribe, ok := SubscribeState(nil, func(StateChange) {})
+	if ok {
+		t.Error("Expected no notifications for a connection without StateNotifier")
+	}
+	unsubscribe()
+}
+
+func TestConnectionState_String(t *testing.T) {
+	states := map[ConnectionState]string{
+		StateConnected:        "connected",
+		StateDisconnected:     "disconnected",
+		StateConnecting:       "connecting",
+		StateReconnecting:     "reconnecting",
+		StateClosing:          "closing",
+		ConnectionState(0x7F): "unknown",
+	}
+	for state, name := range states {
+		if state.String() != name {
+			t.Errorf("Expected %q, got %q", name, state.String())
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package transport
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of per-connection traffic and error counters.
+type Stats struct {
+	BytesSent        uint64 // Bytes of encoded packets written
+	BytesReceived    uint64 // Bytes of valid frames received
+	MessagesSent     uint64 // Packets written successfully
+	MessagesReceived uint64 // Packets received and decoded successfully
+	CRCErrors        uint64 // Candidate frames discarded because the footer did not match
+	DecodeErrors     uint64 // Frames with a valid footer whose payload failed to decode
+	Timeouts         uint64 // Read and write operations that timed out
+	SkippedBytes     uint64 // Bytes discarded while resynchronizing the stream
+	PacketIDGaps     uint64 // Packets missing from the peer's PacketID sequence (loss estimate)
+}
+
+// Add returns the field-wise sum of two statistics snapshots, for example to combine
+// the counters of connections that replaced each other.
+func (s Stats) Add(other Stats) Stats {
+	return Stats{
+		BytesSent:        s.BytesSent + other.BytesSent,
+		BytesReceived:    s.BytesReceived + other.BytesReceived,
+		MessagesSent:     s.MessagesSent + other.MessagesSent,
+		MessagesReceived: s.MessagesReceived + other.MessagesReceived,
+		CRCErrors:        s.CRCErrors + other.CRCErrors,
+		DecodeErrors:     s.DecodeErrors + other.DecodeErrors,
+		Timeouts:         s.Timeouts + other.Timeouts,
+		SkippedBytes:     s.SkippedBytes + other.SkippedBytes,
+		PacketIDGaps:     s.PacketIDGaps + other.PacketIDGaps,
+	}
+}
+
+// StatsProvider is implemented by connections that keep traffic statistics.
+// Every built-in transport connection and connection wrapper implements it.
+type StatsProvider interface {
+	// Stats returns a snapshot of the connection counters.
+	Stats() Stats
+}
+
+// StatsOf returns the statistics of conn, or zero Stats if it does not provide any.
+func StatsOf(conn Connection) Stats {
+	if sp, ok := conn.(StatsProvider); ok {
+		return sp.Stats()
+	}
+	return Stats{}
+}
+
+// Counters accumulates connection statistics for transport implementations.
+// It is safe for concurrent use; the zero value is ready to use.
+type Counters struct {
+	bytesSent        atomic.Uint64 // Bytes of encoded packets written
+	bytesReceived    atomic.Uint64 // Bytes of valid frames received
+	messagesSent     atomic.Uint64 // Packets written successfully
+	messagesReceived atomic.Uint64 // Packets received and decoded successfully
+	decodeErrors     atomic.Uint64 // Frames that failed to decode
+	timeouts         atomic.Uint64 // Timed out operations
+	packetIDGaps     atomic.Uint64 // Estimated lost packets
+	mu               sync.Mutex    // Guards lastPacketID and seenPacket
+	lastPacketID     uint8         // PacketID of the last received packet
+	seenPacket       bool          // Whether any packet has been received
+}
+
+// maxPacketIDGap is the largest forward jump in PacketID counted as loss. Larger jumps
+// are treated as reordering, duplicates, or a peer restart.
+const maxPacketIDGap = 127
+
+// AddSent records a packet of n bytes written to the peer.
+func (c *Counters) AddSent(n int) {
+	c.bytesSent.Add(uint64(n))
+	c.messagesSent.Add(1)
+}
+
+// AddReceived records a decoded packet of n bytes and updates the PacketID gap estimate.
+func (c *Counters) AddReceived(n int, packetID uint8) {
+	c.bytesReceived.Add(uint64(n))
+	c.messagesReceived.Add(1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seenPacket {
+		if gap := packetID - c.lastPacketID - 1; gap > 0 && gap <= maxPacketIDGap {
+			c.packetIDGaps.Add(uint64(gap))
+		}
+	}
+	c.lastPacketID = packetID
+	c.seenPacket = true
+}
+
+// AddDecodeError records a frame that failed to decode.
+func (c *Counters) AddDecodeError() {
+	c.decodeErrors.Add(1)
+}
+
+// AddTimeout records a timed out read or write.
+func (c *Counters) AddTimeout() {
+	c.timeouts.Add(1)
+}
+
+// Snapshot returns the current counters. Stream-level counters kept by the frame
+// reader are passed in by the caller.
+func (c *Counters) Snapshot(crcErrors, skippedBytes uint64) Stats {
+	return Stats{
+		BytesSent:        c.bytesSent.Load(),
+		BytesReceived:    c.bytesReceived.Load(),
+		MessagesSent:     c.messagesSent.Load(),
+		MessagesReceived: c.messagesReceived.Load(),
+		CRCErrors:        crcErrors,
+		DecodeErrors:     c.decodeErrors.Load(),
+		Timeouts:         c.timeouts.Load(),
+		SkippedBytes:     skippedBytes,
+		PacketIDGaps:     c.packetIDGaps.Load(),
+	}
+}
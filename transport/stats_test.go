@@ -0,0 +1,45 @@
+package transport
+
+import "testing"
+
+func TestCounters_PacketIDGaps(t *testing.T) {
+	var c Counters
+
+	for _, id := range []uint8{1, 2, 5, 6, 6, 4, 255, 0, 2} {
+		c.AddReceived(10, id)
+	}
+
+	// 2→5 misses 3,4; the duplicate 6 and the step back to 4 are not loss;
+	// 4→255 is too large a jump to count; 255→0 wraps; 0→2 misses 1.
+	stats := c.Snapshot(0, 0)
+	if stats.PacketIDGaps != 3 {
+		t.Errorf("Expected 3 missing packets, got %d", stats.PacketIDGaps)
+	}
+	if stats.MessagesReceived != 9 || stats.BytesReceived != 90 {
+		t.Errorf("Expected 9 messages and 90 bytes, got %d and %d", stats.MessagesReceived, stats.BytesReceived)
+	}
+}
+
+func TestCounters_Snapshot(t *testing.T) {
+	var c Counters
+	c.AddSent(12)
+	c.AddSent(8)
+	c.AddDecodeError()
+	c.AddTimeout()
+
+	stats := c.Snapshot(2, 17)
+	expected := Stats{BytesSent: 20, MessagesSent: 2, DecodeErrors: 1, Timeouts: 1, CRCErrors: 2, SkippedBytes: 17}
+	if stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestStats_Add(t *testing.T) {
+	a := Stats{BytesSent: 10, MessagesSent: 1, CRCErrors: 2, PacketIDGaps: 3}
+	b := Stats{BytesSent: 5, MessagesReceived: 4, CRCErrors: 1, SkippedBytes: 7}
+
+	expected := Stats{BytesSent: 15, MessagesSent: 1, MessagesReceived: 4, CRCErrors: 3, SkippedBytes: 7, PacketIDGaps: 3}
+	if sum := a.Add(b); sum != expected {
+		t.Errorf("Expected %+v, got %+v", expected, sum)
+	}
+}